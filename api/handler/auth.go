@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"city2city/phone"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// authErrors maps the errors of the auth service to their status and code
+var authErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{auth.ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+	{auth.ErrTokenExpired, http.StatusUnauthorized, "token_expired"},
+	{auth.ErrInvalidCode, http.StatusUnauthorized, "invalid_code"},
+	{auth.ErrCodeExpired, http.StatusUnauthorized, "code_expired"},
+	{auth.ErrTooManyAttempts, http.StatusTooManyRequests, "too_many_attempts"},
+	{auth.ErrCodeTooSoon, http.StatusTooManyRequests, "code_too_soon"},
+}
+
+// handleAuthError writes the response of an error returned by the auth
+// service, other errors are left to handleError
+func handleAuthError(w http.ResponseWriter, err error) {
+	for _, authErr := range authErrors {
+		if errors.Is(err, authErr.err) {
+			handleResponse(w, authErr.status, models.Error{
+				Code:    authErr.code,
+				Message: authErr.err.Error(),
+			})
+			return
+		}
+	}
+
+	handleError(w, err)
+}
+
+// Authenticate lets through only the requests with a valid access token in
+// the Authorization header, the principal of the token is put in the
+// request context
+func (h Handler) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			handleResponse(w, http.StatusUnauthorized, models.Error{
+				Code:    "unauthorized",
+				Message: "access token is missing",
+			})
+			return
+		}
+
+		principal, err := h.auth.Authenticate(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			handleAuthError(w, err)
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func (h Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	login := models.AdminLogin{}
+
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, login) {
+		return
+	}
+
+	tokens, err := h.auth.AdminLogin(r.Context(), login)
+	if err != nil {
+		handleAuthError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, tokens)
+}
+
+// RequestOTP sends a login code to a customer or driver phone
+func (h Handler) RequestOTP(w http.ResponseWriter, r *http.Request) {
+	req := models.RequestOTP{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, req) {
+		return
+	}
+
+	req.Phone, _ = phone.Normalize(req.Phone)
+
+	if err := h.auth.RequestOTP(r.Context(), req); err != nil {
+		handleAuthError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusAccepted, "code is sent")
+}
+
+func (h Handler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
+	req := models.VerifyOTP{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, req) {
+		return
+	}
+
+	req.Phone, _ = phone.Normalize(req.Phone)
+
+	tokens, err := h.auth.VerifyOTP(r.Context(), req)
+	if err != nil {
+		handleAuthError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, tokens)
+}
+
+func (h Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	req := models.RefreshToken{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, req) {
+		return
+	}
+
+	tokens, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		handleAuthError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, tokens)
+}
+
+// Me returns the principal of the access token
+func (h Handler) Me(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	handleResponse(w, http.StatusOK, principal)
+}
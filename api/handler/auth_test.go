@@ -0,0 +1,173 @@
+package handler_test
+
+import (
+	"city2city/api"
+	"city2city/api/handler"
+	"city2city/api/models"
+	"city2city/auth"
+	"city2city/config"
+	"city2city/payment"
+	"city2city/sms"
+	"city2city/storage"
+	"city2city/storage/memory"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "secret"
+
+// server is the api on a memory store
+type server struct {
+	store  storage.IStorage
+	router http.Handler
+	tokens auth.Tokens
+}
+
+func newServer(t *testing.T) server {
+	t.Helper()
+
+	store := memory.New(config.Config{
+		RefundFullBeforeHours:     24,
+		RefundPartialPercent:      50,
+		PlatformCommissionPercent: 10,
+	})
+	tokens := auth.NewTokens(secret, time.Minute, time.Hour)
+	authService := auth.NewService(store, tokens, sms.Console{Out: io.Discard}, time.Minute)
+	payments := payment.NewProviders(payment.Cash{})
+
+	return server{
+		store:  store,
+		router: api.New(handler.New(store, payments, authService, 0)),
+		tokens: tokens,
+	}
+}
+
+// token returns an access token of the principal
+func (s server) token(t *testing.T, principal auth.Principal) string {
+	t.Helper()
+
+	access, _, err := s.tokens.Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return access
+}
+
+// response is the body the handlers write
+type response struct {
+	StatusCode int
+	Data       json.RawMessage
+}
+
+// do sends the request with the Authorization header when it is not empty
+// and returns the status and the error code of the response
+func (s server) do(t *testing.T, method, path, authorization, body string) (*httptest.ResponseRecorder, models.Error) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	resp := response{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: %v, body %s", method, path, err, w.Body.String())
+	}
+
+	apiErr := models.Error{}
+	if w.Code >= http.StatusBadRequest {
+		if err := json.Unmarshal(resp.Data, &apiErr); err != nil {
+			t.Fatalf("%s %s: %v, body %s", method, path, err, w.Body.String())
+		}
+	}
+
+	return w, apiErr
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newServer(t)
+	principal := auth.Principal{ID: "c6f1b5c2-6c8f-4d59-9a4b-0e0d5c1f7a11", Role: models.RoleCustomer}
+
+	access, refresh, err := s.tokens.Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, _, err := auth.NewTokens(secret, -time.Second, time.Hour).Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, _, err := auth.NewTokens("other", time.Minute, time.Hour).Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		code          string
+		challenge     string
+	}{
+		{name: "no header", status: http.StatusUnauthorized, code: "unauthorized", challenge: "Bearer"},
+		{name: "not bearer", authorization: "Basic " + access, status: http.StatusUnauthorized, code: "unauthorized", challenge: "Bearer"},
+		{name: "empty bearer", authorization: "Bearer ", status: http.StatusUnauthorized, code: "unauthorized", challenge: "Bearer"},
+		{name: "refresh token", authorization: "Bearer " + refresh, status: http.StatusUnauthorized, code: "invalid_token", challenge: `Bearer error="invalid_token"`},
+		{name: "forged", authorization: "Bearer " + forged, status: http.StatusUnauthorized, code: "invalid_token", challenge: `Bearer error="invalid_token"`},
+		{name: "expired", authorization: "Bearer " + expired, status: http.StatusUnauthorized, code: "token_expired", challenge: `Bearer error="invalid_token"`},
+		{name: "valid", authorization: "Bearer " + access, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, apiErr := s.do(t, http.MethodGet, "/v1/auth/me", tt.authorization, "")
+			if w.Code != tt.status {
+				t.Fatalf("want status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if apiErr.Code != tt.code {
+				t.Fatalf("want code %q, got %q", tt.code, apiErr.Code)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge != tt.challenge {
+				t.Fatalf("want WWW-Authenticate %q, got %q", tt.challenge, challenge)
+			}
+		})
+	}
+
+	w, _ := s.do(t, http.MethodGet, "/v1/auth/me", "Bearer "+access, "")
+	resp := response{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	got := auth.Principal{}
+	if err := json.Unmarshal(resp.Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != principal {
+		t.Fatalf("want principal %+v, got %+v", principal, got)
+	}
+}
+
+func TestPublicRoutes(t *testing.T) {
+	s := newServer(t)
+
+	// logging in needs no token, a wrong password is refused by the service
+	w, apiErr := s.do(t, http.MethodPost, "/v1/auth/admin/login", "", `{"login":"root","password":"password"}`)
+	if w.Code != http.StatusUnauthorized || apiErr.Code != "invalid_credentials" {
+		t.Fatalf("want 401 invalid_credentials, got %d %q", w.Code, apiErr.Code)
+	}
+
+	w, _ = s.do(t, http.MethodPost, "/v1/auth/otp/request", "", `{"phone":"+998901234567","role":"customer"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -3,47 +3,26 @@ package handler
 import (
 	"city2city/api/models"
 	"encoding/json"
-	"errors"
 	"net/http"
 )
 
-func (h Handler) Car(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateCar(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		if _, ok := values["id"]; !ok {
-			h.GetCarList(w)
-		} else {
-			h.GetCarByID(w, r)
-		}
-	case http.MethodPut:
-		{
-			h.UpdateCar(w, r)
-		}
-	case http.MethodDelete:
-		h.DeleteCar(w, r)
-	}
-}
-
 func (h Handler) CreateCar(w http.ResponseWriter, r *http.Request) {
 	createCar := models.CreateCar{}
 
 	if err := json.NewDecoder(r.Body).Decode(&createCar); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id, err := h.storage.Car().Create(createCar)
+	id, err := h.storage.Car().Create(r.Context(), createCar)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
-	car, err := h.storage.Car().Get(id)
+	car, err := h.storage.Car().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -52,17 +31,12 @@ func (h Handler) CreateCar(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetCarByID(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusInternalServerError, errors.New("id is required"))
-		return
-	}
-	id := values["id"][0]
+	id := r.PathValue("id")
 	var err error
 
-	car, err := h.storage.Car().Get(id)
+	car, err := h.storage.Car().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -70,20 +44,26 @@ func (h Handler) GetCarByID(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h Handler) GetCarList(w http.ResponseWriter) {
-
-	var (
-		page, limit = 1, 50
-		err         error
-	)
+func (h Handler) GetCarList(w http.ResponseWriter, r *http.Request) {
+	req := listRequest(r, 50)
+
+	if wantsCSV(r) {
+		header := []string{"id", "model", "brand", "number", "status", "seats", "driver_id", "driver_name", "created_at"}
+		streamCSV(w, "cars", header, func(write func([]string) error) error {
+			return h.storage.Car().Stream(r.Context(), req.ListQuery, func(car models.Car) error {
+				return write([]string{
+					car.ID, car.Model, car.Brand, car.Number, car.Status, itoa(car.Seats),
+					car.DriverID, car.DriverData.FullName, car.CreatedAt,
+				})
+			})
+		})
+		return
+	}
 
-	response, err := h.storage.Car().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
-	})
+	response, err := h.storage.Car().GetList(r.Context(), req)
 
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -99,15 +79,21 @@ func (h Handler) UpdateCar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Car().Update(updateCar)
+	updateCar.ID = r.PathValue("id")
+
+	if !validate(w, updateCar) {
+		return
+	}
+
+	id, err := h.storage.Car().Update(r.Context(), updateCar)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	car, err := h.storage.Car().Get(id)
+	car, err := h.storage.Car().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -116,16 +102,10 @@ func (h Handler) UpdateCar(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) DeleteCar(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
+	id := r.PathValue("id")
 
-	id := values["id"][0]
-
-	if err := h.storage.Car().Delete(id); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.storage.Car().Delete(r.Context(), id); err != nil {
+		handleError(w, err)
 		return
 	}
 
@@ -141,13 +121,14 @@ func (h Handler) UpdateCarStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(updateCarStatus.ID) == 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
+	updateCarStatus.ID = r.PathValue("id")
+
+	if !validate(w, updateCarStatus) {
 		return
 	}
 
-	if err := h.storage.Car().UpdateCarStatus(updateCarStatus); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.storage.Car().UpdateCarStatus(r.Context(), updateCarStatus); err != nil {
+		handleError(w, err)
 		return
 	}
 
@@ -3,28 +3,9 @@ package handler
 import (
 	"city2city/api/models"
 	"encoding/json"
-	"errors"
 	"net/http"
 )
 
-func (h Handler) City(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateCity(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		if _, ok := values["id"]; !ok {
-			h.GetCityList(w)
-		} else {
-			h.GetCityByID(w, r)
-		}
-	case http.MethodPut:
-		h.UpdateCity(w, r)
-	case http.MethodDelete:
-		h.DeleteCity(w, r)
-	}
-}
-
 func (h Handler) CreateCity(w http.ResponseWriter, r *http.Request) {
 
 	createCity := models.CreateCity{}
@@ -34,16 +15,20 @@ func (h Handler) CreateCity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.City().Create(createCity)
+	if !validate(w, createCity) {
+		return
+	}
+
+	id, err := h.storage.City().Create(r.Context(), createCity)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
-	city, err := h.storage.City().Get(id)
+	city, err := h.storage.City().Get(r.Context(), id)
 
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -52,18 +37,12 @@ func (h Handler) CreateCity(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetCityByID(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 	var err error
 
-	city, err := h.storage.City().Get(id)
+	city, err := h.storage.City().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -71,18 +50,21 @@ func (h Handler) GetCityByID(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h Handler) GetCityList(w http.ResponseWriter) {
-	var (
-		page, limit = 1, 50
-		err         error
-	)
+func (h Handler) GetCityList(w http.ResponseWriter, r *http.Request) {
+	req := listRequest(r, 50)
 
-	resp, err := h.storage.City().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
-	})
+	if wantsCSV(r) {
+		streamCSV(w, "cities", []string{"id", "name", "created_at"}, func(write func([]string) error) error {
+			return h.storage.City().Stream(r.Context(), req.ListQuery, func(city models.City) error {
+				return write([]string{city.ID, city.Name, city.CreatedAt})
+			})
+		})
+		return
+	}
+
+	resp, err := h.storage.City().GetList(r.Context(), req)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -98,13 +80,19 @@ func (h Handler) UpdateCity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.City().Update(updateCity)
+	updateCity.ID = r.PathValue("id")
+
+	if !validate(w, updateCity) {
+		return
+	}
+
+	id, err := h.storage.City().Update(r.Context(), updateCity)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	city, err := h.storage.City().Get(id)
+	city, err := h.storage.City().Get(r.Context(), id)
 
 	if err != nil {
 		handleResponse(w, http.StatusOK, city)
@@ -112,16 +100,10 @@ func (h Handler) UpdateCity(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) DeleteCity(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 
-	if err := h.storage.City().Delete(id); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.storage.City().Delete(r.Context(), id); err != nil {
+		handleError(w, err)
 		return
 	}
 
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// csvFlushEvery is how many rows are written before they are flushed to the client
+const csvFlushEvery = 100
+
+// streamCSV writes the header and then every row stream passes to write,
+// rows are flushed as they come so big lists are never held in memory
+func streamCSV(w http.ResponseWriter, name string, header []string, stream func(write func([]string) error) error) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+
+	flusher, _ := w.(http.Flusher)
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(header); err != nil {
+		fmt.Println("error while writing csv header", err.Error())
+		return
+	}
+
+	written := 0
+	err := stream(func(row []string) error {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+
+		written++
+		if written%csvFlushEvery == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		return writer.Error()
+	})
+
+	// the header row is still in the buffer of the writer when the stream
+	// fails before its first row, e.g. on a filter the list does not have,
+	// so the client can get the error instead of an empty csv
+	if err != nil && written == 0 {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Disposition")
+		handleError(w, err)
+		return
+	}
+
+	// the status is already sent, so a failed stream can only be logged
+	// and the client gets a cut csv
+	if err != nil {
+		fmt.Println("error while streaming csv", err.Error())
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Println("error while flushing csv", err.Error())
+	}
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
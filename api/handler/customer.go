@@ -2,30 +2,11 @@ package handler
 
 import (
 	"city2city/api/models"
+	"city2city/phone"
 	"encoding/json"
-	"errors"
 	"net/http"
 )
 
-func (h Handler) Customer(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateCustomer(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		_, ok := values["id"]
-		if !ok {
-			h.GetCustomerList(w, r)
-		} else {
-			h.GetCustomerByID(w, r)
-		}
-	case http.MethodPut:
-		h.UpdateCustomer(w, r)
-	case http.MethodDelete:
-		h.DeleteCustomer(w, r)
-	}
-}
-
 func (h Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	createCustomer := models.CreateCustomer{}
 
@@ -34,15 +15,22 @@ func (h Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Customer().Create(createCustomer)
+	if !validate(w, createCustomer) {
+		return
+	}
+
+	// the phone is valid already, it is only brought to one format here
+	createCustomer.Phone, _ = phone.Normalize(createCustomer.Phone)
+
+	id, err := h.storage.Customer().Create(r.Context(), createCustomer)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
-	customer, err := h.storage.Customer().Get(id)
+	customer, err := h.storage.Customer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -51,18 +39,12 @@ func (h Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetCustomerByID(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 	var err error
 
-	customer, err := h.storage.Customer().Get(id)
+	customer, err := h.storage.Customer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -71,17 +53,20 @@ func (h Handler) GetCustomerByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetCustomerList(w http.ResponseWriter, r *http.Request) {
-	var (
-		page, limit = 1, 50
-		err         error
-	)
-
-	resp, err := h.storage.Customer().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
-	})
+	req := listRequest(r, 50)
+
+	if wantsCSV(r) {
+		streamCSV(w, "customers", []string{"id", "full_name", "phone", "email", "created_at"}, func(write func([]string) error) error {
+			return h.storage.Customer().Stream(r.Context(), req.ListQuery, func(customer models.Customer) error {
+				return write([]string{customer.ID, customer.FullName, customer.Phone, customer.Email, customer.CreatedAt})
+			})
+		})
+		return
+	}
+
+	resp, err := h.storage.Customer().GetList(r.Context(), req)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -97,15 +82,24 @@ func (h Handler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Customer().Update(updateCustomer)
+	updateCustomer.ID = r.PathValue("id")
+
+	if !validate(w, updateCustomer) {
+		return
+	}
+
+	// the phone is valid already, it is only brought to one format here
+	updateCustomer.Phone, _ = phone.Normalize(updateCustomer.Phone)
+
+	id, err := h.storage.Customer().Update(r.Context(), updateCustomer)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	user, err := h.storage.Customer().Get(id)
+	user, err := h.storage.Customer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -114,16 +108,10 @@ func (h Handler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 
-	if err := h.storage.Customer().Delete(id); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+	if err := h.storage.Customer().Delete(r.Context(), id); err != nil {
+		handleError(w, err)
 		return
 	}
 
@@ -2,31 +2,12 @@ package handler
 
 import (
 	"city2city/api/models"
-	"city2city/check"
+	"city2city/phone"
 	"encoding/json"
-	"errors"
 	"net/http"
+	"time"
 )
 
-func (h Handler) Driver(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateDriver(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		_, ok := values["id"]
-		if !ok {
-			h.GetDriverList(w, r)
-		} else {
-			h.GetDriverByID(w, r)
-		}
-	case http.MethodPut:
-		h.UpdateDriver(w, r)
-	case http.MethodDelete:
-		h.DeleteDriver(w, r)
-	}
-}
-
 func (h Handler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 	createDriver := models.CreateDriver{}
 
@@ -35,22 +16,24 @@ func (h Handler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !check.PhoneNumber(createDriver.Phone) {
-		handleResponse(w, http.StatusBadRequest, errors.New("phone number is not correct!"))
+	if !validate(w, createDriver) {
 		return
 	}
 
-	id, err := h.storage.Driver().Create(createDriver)
+	// the phone is valid already, it is only brought to one format here
+	createDriver.Phone, _ = phone.Normalize(createDriver.Phone)
+
+	id, err := h.storage.Driver().Create(r.Context(), createDriver)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
-	driver, err := h.storage.Driver().Get(models.PrimaryKey{
+	driver, err := h.storage.Driver().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -62,20 +45,14 @@ func (h Handler) CreateDriver(w http.ResponseWriter, r *http.Request) {
 
 func (h Handler) GetDriverByID(w http.ResponseWriter, r *http.Request) {
 
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 	var err error
 
-	user, err := h.storage.Driver().Get(models.PrimaryKey{
+	user, err := h.storage.Driver().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -86,17 +63,26 @@ func (h Handler) GetDriverByID(w http.ResponseWriter, r *http.Request) {
 // TASK 6
 
 func (h Handler) GetDriverList(w http.ResponseWriter, r *http.Request) {
-	var (
-		page, limit = 1, 50
-		err         error
-	)
+	req := listRequest(r, 50)
+
+	if wantsCSV(r) {
+		header := []string{"id", "full_name", "phone", "from_city_id", "from_city", "to_city_id", "to_city", "created_at"}
+		streamCSV(w, "drivers", header, func(write func([]string) error) error {
+			return h.storage.Driver().Stream(r.Context(), req.ListQuery, func(driver models.Driver) error {
+				return write([]string{
+					driver.ID, driver.FullName, driver.Phone,
+					driver.FromCityID, driver.FromCityData.Name,
+					driver.ToCityID, driver.ToCityData.Name,
+					driver.CreatedAt,
+				})
+			})
+		})
+		return
+	}
 
-	resp, err := h.storage.Driver().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
-	})
+	resp, err := h.storage.Driver().GetList(r.Context(), req)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -111,17 +97,26 @@ func (h Handler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Driver().Update(updateDriver)
+	updateDriver.ID = r.PathValue("id")
+
+	if !validate(w, updateDriver) {
+		return
+	}
+
+	// the phone is valid already, it is only brought to one format here
+	updateDriver.Phone, _ = phone.Normalize(updateDriver.Phone)
+
+	id, err := h.storage.Driver().Update(r.Context(), updateDriver)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	driver, err := h.storage.Driver().Get(models.PrimaryKey{
+	driver, err := h.storage.Driver().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -131,22 +126,98 @@ func (h Handler) UpdateDriver(w http.ResponseWriter, r *http.Request) {
 
 func (h Handler) DeleteDriver(w http.ResponseWriter, r *http.Request) {
 
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
+	id := r.PathValue("id")
+
+	err := h.storage.Driver().Delete(r.Context(), models.PrimaryKey{
+		ID: id,
+	})
+	if err != nil {
+		handleError(w, err)
 		return
 	}
 
-	id := values["id"][0]
+	handleResponse(w, http.StatusOK, "data successfully deleted")
 
-	err := h.storage.Driver().Delete(models.PrimaryKey{
-		ID: id,
+}
+
+func (h Handler) GetDriverBalance(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	balance, err := h.storage.DriverLedger().GetBalance(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, balance)
+}
+
+// GetDriverStatement returns the ledger of the driver between the from and
+// to dates (YYYY-MM-DD, both included)
+func (h Handler) GetDriverStatement(w http.ResponseWriter, r *http.Request) {
+	var (
+		id     = r.PathValue("id")
+		values = r.URL.Query()
+	)
+
+	from, err := time.Parse("2006-01-02", values.Get("from"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "from must be in YYYY-MM-DD format")
+		return
+	}
+
+	to, err := time.Parse("2006-01-02", values.Get("to"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "to must be in YYYY-MM-DD format")
+		return
+	}
+
+	if to.Before(from) {
+		handleResponse(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	statement, err := h.storage.DriverLedger().GetStatement(r.Context(), models.GetStatementRequest{
+		DriverID: id,
+		From:     from.Format("2006-01-02"),
+		To:       to.AddDate(0, 0, 1).Format("2006-01-02"),
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	handleResponse(w, http.StatusOK, "data successfully deleted")
+	// the storage takes the day after to as the end of the range, the
+	// statement shows the dates that were asked for
+	statement.To = to.Format("2006-01-02")
+
+	handleResponse(w, http.StatusOK, statement)
+}
+
+func (h Handler) CreateDriverPayout(w http.ResponseWriter, r *http.Request) {
+	payout := models.CreatePayout{}
+
+	if err := json.NewDecoder(r.Body).Decode(&payout); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payout.DriverID = r.PathValue("id")
+
+	if !validate(w, payout) {
+		return
+	}
+
+	if _, err := h.storage.DriverLedger().CreatePayout(r.Context(), payout); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	balance, err := h.storage.DriverLedger().GetBalance(r.Context(), payout.DriverID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
 
+	handleResponse(w, http.StatusCreated, balance)
 }
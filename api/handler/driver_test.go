@@ -0,0 +1,29 @@
+package handler_test
+
+import (
+	"city2city/api/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDriverStatementDates(t *testing.T) {
+	s := newServer(t)
+	f := s.fixture(t)
+
+	w, _ := s.do(t, http.MethodGet, f.expand("/v1/drivers/{driver}/statement?from=2030-01-01&to=2030-01-31"), "Bearer "+s.token(t, f.principals[admin]), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	resp := struct {
+		Data models.DriverStatement
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Data.From != "2030-01-01" || resp.Data.To != "2030-01-31" {
+		t.Fatalf("want the dates that were asked for, got %s - %s", resp.Data.From, resp.Data.To)
+	}
+}
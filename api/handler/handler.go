@@ -2,25 +2,172 @@ package handler
 
 import (
 	"city2city/api/models"
+	"city2city/auth"
+	"city2city/payment"
 	"city2city/storage"
+	"city2city/validation"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type Handler struct {
-	storage storage.IStorage
+	storage      storage.IStorage
+	payments     payment.Providers
+	auth         auth.Service
+	queryTimeout time.Duration
 }
 
-func New(store storage.IStorage) Handler {
+func New(store storage.IStorage, payments payment.Providers, authService auth.Service, queryTimeout time.Duration) Handler {
 	return Handler{
-		storage: store,
+		storage:      store,
+		payments:     payments,
+		auth:         authService,
+		queryTimeout: queryTimeout,
 	}
 }
 
+// Deadline cancels the queries of a request which runs longer than the
+// query timeout, the request gets 504 then. CSV exports stream every row
+// and are only cancelled when the client goes away.
+func (h Handler) Deadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.queryTimeout <= 0 || wantsCSV(r) {
+			next(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), h.queryTimeout)
+		defer cancel()
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// errorStatus maps the kinds of storage errors to status codes
+var errorStatus = map[storage.ErrorKind]int{
+	storage.KindNotFound:   http.StatusNotFound,
+	storage.KindConflict:   http.StatusConflict,
+	storage.KindValidation: http.StatusUnprocessableEntity,
+	storage.KindForeignKey: http.StatusUnprocessableEntity,
+	storage.KindTimeout:    http.StatusGatewayTimeout,
+}
+
+// handleError writes the response of an error returned by the storage or
+// the services. Errors that are not storage errors are internal, their
+// details are only logged.
+func handleError(w http.ResponseWriter, err error) {
+	var storageErr *storage.Error
+	if errors.As(err, &storageErr) {
+		if code, ok := errorStatus[storageErr.Kind]; ok {
+			handleResponse(w, code, models.Error{
+				Code:    storageErr.Code,
+				Message: storageErr.Message,
+			})
+			return
+		}
+	}
+
+	fmt.Println("internal error", err.Error())
+	handleResponse(w, http.StatusInternalServerError, models.Error{
+		Code:    "internal",
+		Message: "internal server error",
+	})
+}
+
+// validate writes 422 with every field that is not valid and returns false,
+// the handler must stop then. checks are the checks of the request which
+// look at more than one field, their field errors are written together with
+// the ones of the validate tags.
+func validate(w http.ResponseWriter, req interface{}, checks ...error) bool {
+	var errs validation.Errors
+	if err := validation.Struct(req); err != nil {
+		errs = err.(validation.Errors)
+	}
+
+	failed := map[string]bool{}
+	for _, fieldErr := range errs {
+		failed[fieldErr.Field] = true
+	}
+
+	for _, err := range checks {
+		var checkErrs validation.Errors
+		if !errors.As(err, &checkErrs) {
+			if err != nil {
+				handleResponse(w, http.StatusBadRequest, err)
+				return false
+			}
+			continue
+		}
+
+		// a field the tags already rejected would only be reported twice
+		for _, fieldErr := range checkErrs {
+			if !failed[fieldErr.Field] {
+				errs = append(errs, fieldErr)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return true
+	}
+
+	handleResponse(w, http.StatusUnprocessableEntity, models.Error{
+		Code:    "validation",
+		Message: "request is not valid",
+		Fields:  errs,
+	})
+	return false
+}
+
+// statusCodes are the codes of error responses written with a message only
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusPaymentRequired:       "payment_declined",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnprocessableEntity:   "validation",
+	http.StatusBadGateway:            "provider_error",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+
+	if status >= http.StatusInternalServerError {
+		return "internal"
+	}
+	return "bad_request"
+}
+
 func handleResponse(w http.ResponseWriter, statuscode int, data interface{}) {
 	resp := models.Response{}
 
+	// errors have no exported fields and would be marshalled as {}, error
+	// messages get the code of their status so clients can check it
+	switch value := data.(type) {
+	case error:
+		data = models.Error{
+			Code:    statusCode(statuscode),
+			Message: value.Error(),
+		}
+	case string:
+		if statuscode >= http.StatusBadRequest {
+			data = models.Error{
+				Code:    statusCode(statuscode),
+				Message: value,
+			}
+		}
+	}
+
 	switch code := statuscode; {
 	case code < 400:
 		resp.Description = "succes"
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/importer"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxImportSize limits the csv files of one import
+const maxImportSize = 10 << 20
+
+// Import creates cities, drivers and cars from csv files in one transaction.
+// The files are sent as multipart form files named cities, drivers and cars,
+// or a single csv is sent as the body with its kind in the kind query
+// parameter. With dry_run=true nothing is saved, only the report is returned.
+// When a row fails the report is the details of a 422 error.
+func (h Handler) Import(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	files := map[string]io.Reader{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			handleResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		for kind, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			if err != nil {
+				handleResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			defer file.Close()
+
+			files[kind] = file
+		}
+	} else {
+		files[r.URL.Query().Get("kind")] = r.Body
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	result, err := importer.Run(r.Context(), h.storage, files, dryRun)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		handleResponse(w, http.StatusUnprocessableEntity, models.Error{
+			Code:    "import_failed",
+			Message: "some rows can not be imported, nothing is saved",
+			Details: result,
+		})
+	case dryRun:
+		handleResponse(w, http.StatusOK, result)
+	default:
+		handleResponse(w, http.StatusCreated, result)
+	}
+}
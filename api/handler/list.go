@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// listParams are the query parameters of a list which are not filters
+var listParams = map[string]bool{
+	"page":   true,
+	"limit":  true,
+	"sort":   true,
+	"search": true,
+	"format": true,
+}
+
+// listRequest reads the page, the sort, the search and the filters of a
+// list from the query string, e.g.
+//
+//	?page=2&limit=20&sort=-price,created_at&search=chevrolet&status=false
+//
+// A missing or not valid page or limit falls back to the first page and
+// limit. Every other non empty parameter filters the field of its name,
+// the storage rejects fields the list does not have.
+func listRequest(r *http.Request, limit int) models.GetListRequest {
+	values := r.URL.Query()
+
+	req := models.GetListRequest{
+		Page:  1,
+		Limit: limit,
+		ListQuery: models.ListQuery{
+			Filters: map[string]string{},
+			Search:  strings.TrimSpace(values.Get("search")),
+		},
+	}
+
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 0 {
+		req.Page = page
+	}
+
+	if limit, err := strconv.Atoi(values.Get("limit")); err == nil && limit > 0 {
+		req.Limit = limit
+	}
+
+	for _, field := range strings.Split(values.Get("sort"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		name, desc := strings.CutPrefix(field, "-")
+		req.Sort = append(req.Sort, models.SortField{Field: name, Desc: desc})
+	}
+
+	for name := range values {
+		if value := values.Get(name); !listParams[name] && value != "" {
+			req.Filters[name] = value
+		}
+	}
+
+	return req
+}
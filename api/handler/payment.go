@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// CreatePayment authorizes the fare of a booking with the chosen provider.
+// Declined payments are stored as failed so they can be seen later.
+func (h Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	pay := models.PayTripCustomer{}
+
+	if err := json.NewDecoder(r.Body).Decode(&pay); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, pay) {
+		return
+	}
+
+	provider, err := h.payments.Get(pay.Provider)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	booking, err := h.storage.TripCustomer().Get(r.Context(), pay.TripCustomerID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !actsForSelf(w, r, models.RoleCustomer, booking.CustomerID, "the booking is not yours") {
+		return
+	}
+
+	if booking.Status == models.BookingStatusCancelled {
+		handleError(w, storage.ErrBookingCancelled)
+		return
+	}
+
+	if booking.PaymentStatus != models.BookingUnpaid {
+		handleResponse(w, http.StatusConflict, models.Error{
+			Code:    "booking_already_paid",
+			Message: "booking is already " + booking.PaymentStatus,
+		})
+		return
+	}
+
+	// an authorized payment leaves the booking unpaid until it is captured,
+	// checked before the provider authorizes the fare a second time. The
+	// storage checks it again when the payment is created.
+	for _, status := range []string{models.PaymentStatusAuthorized, models.PaymentStatusCapturing} {
+		payments, err := h.storage.Payment().GetList(r.Context(), models.GetPaymentListRequest{
+			Page:           1,
+			Limit:          1,
+			TripCustomerID: booking.ID,
+			Status:         status,
+		})
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		if payments.Count > 0 {
+			handleError(w, storage.ErrPaymentExists)
+			return
+		}
+	}
+
+	createPayment := models.CreatePayment{
+		TripCustomerID: booking.ID,
+		Provider:       provider.Name(),
+		Amount:         booking.Fare,
+		Currency:       booking.Currency,
+		Status:         models.PaymentStatusAuthorized,
+	}
+
+	reference, authErr := provider.Authorize(booking.Fare, booking.Currency)
+	if authErr != nil {
+		createPayment.Status = models.PaymentStatusFailed
+	}
+	createPayment.Reference = reference
+
+	id, err := h.storage.Payment().Create(r.Context(), createPayment)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if authErr != nil {
+		handleResponse(w, http.StatusPaymentRequired, authErr.Error())
+		return
+	}
+
+	payment, err := h.storage.Payment().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, payment)
+}
+
+func (h Handler) GetPaymentByID(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	payment, err := h.storage.Payment().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, payment)
+}
+
+func (h Handler) GetPaymentList(w http.ResponseWriter, r *http.Request) {
+	var (
+		page, limit = 1, 10
+		err         error
+	)
+	values := r.URL.Query()
+	if len(values["page"]) > 0 {
+		page, err = strconv.Atoi(values["page"][0])
+		if err != nil {
+			page = 1
+		}
+	}
+
+	if len(values["limit"]) > 0 {
+		limit, err = strconv.Atoi(values["limit"][0])
+		if err != nil {
+			limit = 10
+		}
+	}
+
+	resp, err := h.storage.Payment().GetList(r.Context(), models.GetPaymentListRequest{
+		Page:           page,
+		Limit:          limit,
+		TripCustomerID: values.Get("trip_customer_id"),
+		Status:         values.Get("status"),
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// CapturePayment takes the authorized money, for cash it means the driver collected it
+func (h Handler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	payment, ok := h.loadPayment(w, r)
+	if !ok {
+		return
+	}
+
+	if payment.Status != models.PaymentStatusAuthorized {
+		handleResponse(w, http.StatusConflict, models.Error{
+			Code:    storage.ErrInvalidPaymentStatus.Code,
+			Message: "only authorized payments can be captured",
+		})
+		return
+	}
+
+	provider, err := h.payments.Get(payment.Provider)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !h.claimPayment(w, r, payment, models.PaymentStatusCapturing) {
+		return
+	}
+
+	if err := provider.Capture(payment.Reference, payment.Amount); err != nil {
+		h.releasePayment(r, payment, models.PaymentStatusCapturing)
+		handleResponse(w, http.StatusPaymentRequired, err.Error())
+		return
+	}
+
+	h.updatePaymentStatus(w, r, models.UpdatePaymentStatus{
+		ID:     payment.ID,
+		From:   models.PaymentStatusCapturing,
+		Status: models.PaymentStatusCaptured,
+	})
+}
+
+// RefundPayment returns the refund of a cancelled booking, or the whole
+// amount if the booking is still active
+func (h Handler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	payment, ok := h.loadPayment(w, r)
+	if !ok {
+		return
+	}
+
+	if payment.Status != models.PaymentStatusCaptured {
+		handleResponse(w, http.StatusConflict, models.Error{
+			Code:    storage.ErrInvalidPaymentStatus.Code,
+			Message: "only captured payments can be refunded",
+		})
+		return
+	}
+
+	booking, err := h.storage.TripCustomer().Get(r.Context(), payment.TripCustomerID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	amount := payment.Amount
+	if booking.Status == models.BookingStatusCancelled {
+		amount = booking.RefundAmount
+	}
+
+	if amount <= 0 {
+		handleResponse(w, http.StatusConflict, models.Error{
+			Code:    "nothing_to_refund",
+			Message: "nothing to refund",
+		})
+		return
+	}
+
+	provider, err := h.payments.Get(payment.Provider)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !h.claimPayment(w, r, payment, models.PaymentStatusRefunding) {
+		return
+	}
+
+	if err := provider.Refund(payment.Reference, amount); err != nil {
+		h.releasePayment(r, payment, models.PaymentStatusRefunding)
+		handleResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.updatePaymentStatus(w, r, models.UpdatePaymentStatus{
+		ID:             payment.ID,
+		From:           models.PaymentStatusRefunding,
+		Status:         models.PaymentStatusRefunded,
+		RefundedAmount: amount,
+	})
+}
+
+func (h Handler) loadPayment(w http.ResponseWriter, r *http.Request) (models.Payment, bool) {
+	payment, err := h.storage.Payment().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		handleError(w, err)
+		return models.Payment{}, false
+	}
+
+	return payment, true
+}
+
+// claimPayment moves the payment to the status of a provider call before
+// the provider is called. The move is conditional on the status the payment
+// was loaded with, so of two concurrent requests only one calls the provider.
+func (h Handler) claimPayment(w http.ResponseWriter, r *http.Request, payment models.Payment, status string) bool {
+	if err := h.storage.Payment().UpdateStatus(r.Context(), models.UpdatePaymentStatus{
+		ID:     payment.ID,
+		From:   payment.Status,
+		Status: status,
+	}); err != nil {
+		handleError(w, err)
+		return false
+	}
+
+	return true
+}
+
+// releasePayment moves a claimed payment back to the status it had when the
+// provider call failed. It runs even when the client is gone, otherwise the
+// payment would stay claimed.
+func (h Handler) releasePayment(r *http.Request, payment models.Payment, claimed string) {
+	if err := h.storage.Payment().UpdateStatus(context.WithoutCancel(r.Context()), models.UpdatePaymentStatus{
+		ID:             payment.ID,
+		From:           claimed,
+		Status:         payment.Status,
+		RefundedAmount: payment.RefundedAmount,
+	}); err != nil {
+		fmt.Println("error while releasing payment", payment.ID, err.Error())
+	}
+}
+
+// updatePaymentStatus settles a claimed payment once the provider moved the
+// money, so it does not stop when the client is gone
+func (h Handler) updatePaymentStatus(w http.ResponseWriter, r *http.Request, req models.UpdatePaymentStatus) {
+	ctx := context.WithoutCancel(r.Context())
+
+	if err := h.storage.Payment().UpdateStatus(ctx, req); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	payment, err := h.storage.Payment().Get(ctx, req.ID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, payment)
+}
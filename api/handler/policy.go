@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// rule decides whether the principal may call a route. A rule that does
+// not allow returns the reason when it has a more precise one than the
+// role of the principal, like "the car is not yours".
+type rule func(h Handler, r *http.Request, principal auth.Principal) (bool, string, error)
+
+// roles allows the principals of the roles
+func roles(names ...string) rule {
+	return func(h Handler, r *http.Request, principal auth.Principal) (bool, string, error) {
+		for _, name := range names {
+			if principal.Role == name {
+				return true, "", nil
+			}
+		}
+
+		return false, "", nil
+	}
+}
+
+// anyOf allows when one of the rules allows
+func anyOf(rules ...rule) rule {
+	return func(h Handler, r *http.Request, principal auth.Principal) (bool, string, error) {
+		reason := ""
+		for _, rule := range rules {
+			allowed, ruleReason, err := rule(h, r, principal)
+			if err != nil || allowed {
+				return allowed, "", err
+			}
+
+			if reason == "" {
+				reason = ruleReason
+			}
+		}
+
+		return false, reason, nil
+	}
+}
+
+// owner allows the principals of the role who own the resource of the
+// route, ownerOf returns the id of the owner
+func owner(role, reason string, ownerOf func(ctx context.Context, h Handler, id string) (string, error)) rule {
+	return func(h Handler, r *http.Request, principal auth.Principal) (bool, string, error) {
+		if principal.Role != role {
+			return false, "", nil
+		}
+
+		ownerID, err := ownerOf(r.Context(), h, r.PathValue("id"))
+		if err != nil {
+			return false, "", err
+		}
+
+		if ownerID != principal.ID {
+			return false, reason, nil
+		}
+
+		return true, "", nil
+	}
+}
+
+// self allows the customer or driver the route is about
+func self(role string) rule {
+	return owner(role, fmt.Sprintf("a %s can only access their own account", role), func(ctx context.Context, h Handler, id string) (string, error) {
+		return id, nil
+	})
+}
+
+var (
+	everyone = roles(models.RoleAdmin, models.RoleDispatcher, models.RoleDriver, models.RoleCustomer)
+	staff    = roles(models.RoleAdmin, models.RoleDispatcher)
+	admin    = roles(models.RoleAdmin)
+
+	carDriver = owner(models.RoleDriver, "the car is not yours", func(ctx context.Context, h Handler, id string) (string, error) {
+		car, err := h.storage.Car().Get(ctx, id)
+		return car.DriverID, err
+	})
+
+	tripDriver = owner(models.RoleDriver, "the trip is not yours", func(ctx context.Context, h Handler, id string) (string, error) {
+		trip, err := h.storage.Trip().Get(ctx, models.PrimaryKey{ID: id})
+		return trip.DriverID, err
+	})
+
+	bookingCustomer = owner(models.RoleCustomer, "the booking is not yours", func(ctx context.Context, h Handler, id string) (string, error) {
+		booking, err := h.storage.TripCustomer().Get(ctx, id)
+		return booking.CustomerID, err
+	})
+
+	paymentCustomer = owner(models.RoleCustomer, "the payment is not yours", func(ctx context.Context, h Handler, id string) (string, error) {
+		payment, err := h.storage.Payment().Get(ctx, id)
+		if err != nil {
+			return "", err
+		}
+
+		booking, err := h.storage.TripCustomer().Get(ctx, payment.TripCustomerID)
+		return booking.CustomerID, err
+	})
+)
+
+// policies are the rules of the routes which need an access token, keyed
+// by the pattern the route is registered with. A route missing here is
+// forbidden to everyone.
+//
+// Admins manage everything, dispatchers manage trips and bookings, drivers
+// see and run their own trips and car, customers book and cancel their own
+// trips. Creating a booking or a payment is checked against the body by
+// the handler.
+var policies = map[string]rule{
+	"GET /v1/auth/me": everyone,
+
+	"GET /v1/cities":         everyone,
+	"POST /v1/cities":        admin,
+	"GET /v1/cities/{id}":    everyone,
+	"PUT /v1/cities/{id}":    admin,
+	"DELETE /v1/cities/{id}": admin,
+
+	"GET /v1/customers":         staff,
+	"GET /v1/customers/{id}":    anyOf(staff, self(models.RoleCustomer)),
+	"PUT /v1/customers/{id}":    anyOf(admin, self(models.RoleCustomer)),
+	"DELETE /v1/customers/{id}": admin,
+
+	"GET /v1/drivers":                staff,
+	"POST /v1/drivers":               admin,
+	"GET /v1/drivers/{id}":           anyOf(staff, self(models.RoleDriver)),
+	"PUT /v1/drivers/{id}":           admin,
+	"DELETE /v1/drivers/{id}":        admin,
+	"GET /v1/drivers/{id}/balance":   anyOf(admin, self(models.RoleDriver)),
+	"GET /v1/drivers/{id}/statement": anyOf(admin, self(models.RoleDriver)),
+	"POST /v1/drivers/{id}/payouts":  admin,
+
+	"GET /v1/cars":             staff,
+	"POST /v1/cars":            admin,
+	"GET /v1/cars/{id}":        anyOf(staff, carDriver),
+	"PUT /v1/cars/{id}":        admin,
+	"DELETE /v1/cars/{id}":     admin,
+	"PUT /v1/cars/{id}/status": anyOf(staff, carDriver),
+
+	// drivers get only their own trips in the list
+	"GET /v1/trips":                       anyOf(staff, roles(models.RoleDriver)),
+	"POST /v1/trips":                      staff,
+	"GET /v1/trips/search":                everyone,
+	"GET /v1/trips/{id}":                  anyOf(staff, roles(models.RoleCustomer), tripDriver),
+	"PUT /v1/trips/{id}":                  staff,
+	"DELETE /v1/trips/{id}":               staff,
+	"POST /v1/trips/{id}/board":           anyOf(staff, tripDriver),
+	"POST /v1/trips/{id}/start":           anyOf(staff, tripDriver),
+	"POST /v1/trips/{id}/complete":        anyOf(staff, tripDriver),
+	"POST /v1/trips/{id}/cancel":          staff,
+	"GET /v1/trips/{id}/customers":        anyOf(staff, tripDriver),
+	"GET /v1/trips/{id}/customers/unpaid": anyOf(staff, tripDriver),
+	"GET /v1/trips/{id}/totals":           anyOf(staff, tripDriver),
+
+	"GET /v1/trip_templates":                staff,
+	"POST /v1/trip_templates":               staff,
+	"GET /v1/trip_templates/{id}":           staff,
+	"PUT /v1/trip_templates/{id}":           staff,
+	"DELETE /v1/trip_templates/{id}":        staff,
+	"POST /v1/trip_templates/{id}/generate": staff,
+
+	"GET /v1/route_tariffs":        everyone,
+	"POST /v1/route_tariffs":       admin,
+	"GET /v1/route_tariffs/active": everyone,
+	"GET /v1/route_tariffs/{id}":   everyone,
+
+	"GET /v1/price_rules":         staff,
+	"POST /v1/price_rules":        admin,
+	"GET /v1/price_rules/{id}":    staff,
+	"PUT /v1/price_rules/{id}":    admin,
+	"DELETE /v1/price_rules/{id}": admin,
+
+	"GET /v1/trip_customers":              staff,
+	"POST /v1/trip_customers":             anyOf(staff, roles(models.RoleCustomer)),
+	"GET /v1/trip_customers/{id}":         anyOf(staff, bookingCustomer),
+	"PUT /v1/trip_customers/{id}":         staff,
+	"DELETE /v1/trip_customers/{id}":      staff,
+	"POST /v1/trip_customers/{id}/cancel": anyOf(staff, bookingCustomer),
+
+	"GET /v1/payments":               staff,
+	"POST /v1/payments":              anyOf(staff, roles(models.RoleCustomer)),
+	"GET /v1/payments/{id}":          anyOf(staff, paymentCustomer),
+	"POST /v1/payments/{id}/capture": staff,
+	"POST /v1/payments/{id}/refund":  admin,
+
+	"GET /v1/reports": admin,
+	"POST /v1/import": admin,
+}
+
+// Authorize lets through only the requests the policy of their route
+// allows, it must be wrapped by Authenticate
+func (h Handler) Authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			handleResponse(w, http.StatusUnauthorized, models.Error{
+				Code:    "unauthorized",
+				Message: "access token is missing",
+			})
+			return
+		}
+
+		rule, ok := policies[r.Pattern]
+		if !ok {
+			forbid(w, "route has no access policy")
+			return
+		}
+
+		allowed, reason, err := rule(h, r, principal)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		if !allowed {
+			if reason == "" {
+				reason = fmt.Sprintf("role %s can not access %s", principal.Role, r.Pattern)
+			}
+			forbid(w, reason)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// actsForSelf writes 403 and returns false when a principal of the role
+// sends a request on behalf of someone else, ownerID is the customer or
+// driver the request is for
+func actsForSelf(w http.ResponseWriter, r *http.Request, role, ownerID, reason string) bool {
+	principal, _ := auth.FromContext(r.Context())
+	if principal.Role != role || principal.ID == ownerID {
+		return true
+	}
+
+	forbid(w, reason)
+	return false
+}
+
+func forbid(w http.ResponseWriter, reason string) {
+	handleResponse(w, http.StatusForbidden, models.Error{
+		Code:    "forbidden",
+		Message: reason,
+	})
+}
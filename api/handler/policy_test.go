@@ -0,0 +1,339 @@
+package handler_test
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"city2city/payment"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the principals of the policy tests, driver and customer own the
+// resources of the fixture, the others own nothing in it
+const (
+	admin         = "admin"
+	dispatcher    = "dispatcher"
+	driver        = "driver"
+	otherDriver   = "other driver"
+	customer      = "customer"
+	otherCustomer = "other customer"
+)
+
+var principals = []string{admin, dispatcher, driver, otherDriver, customer, otherCustomer}
+
+// fixture is a trip of driver booked and paid by customer, and the same
+// for otherDriver and otherCustomer
+type fixture struct {
+	principals map[string]auth.Principal
+
+	city, car, trip, booking, payment string
+}
+
+func (s server) fixture(t *testing.T) fixture {
+	t.Helper()
+
+	f := fixture{principals: map[string]auth.Principal{
+		admin:      {ID: "0b0c6a55-0c53-4a43-9d1e-5d2c2f0b7a01", Role: models.RoleAdmin},
+		dispatcher: {ID: "0b0c6a55-0c53-4a43-9d1e-5d2c2f0b7a02", Role: models.RoleDispatcher},
+	}}
+
+	from := must(t)(s.store.City().Create(t.Context(), models.CreateCity{Name: "Tashkent"}))
+	to := must(t)(s.store.City().Create(t.Context(), models.CreateCity{Name: "Samarkand"}))
+	f.city = from
+
+	departure := time.Now().Add(48 * time.Hour)
+	for i, names := range [][2]string{{driver, customer}, {otherDriver, otherCustomer}} {
+		phone := "+99890123456" + string(rune('0'+i))
+
+		driverID := must(t)(s.store.Driver().Create(t.Context(), models.CreateDriver{
+			FullName:   names[0],
+			Phone:      phone,
+			FromCityID: from,
+			ToCityID:   to,
+		}))
+		carID := must(t)(s.store.Car().Create(t.Context(), models.CreateCar{
+			Model:    "Cobalt",
+			Brand:    "Chevrolet",
+			Number:   "01A00" + string(rune('0'+i)),
+			Seats:    3,
+			DriverID: driverID,
+		}))
+		tripID := must(t)(s.store.Trip().Create(t.Context(), models.CreateTrip{
+			FromCityID:  from,
+			ToCityID:    to,
+			DriverID:    driverID,
+			Price:       100000,
+			DepartureAt: departure.Format("2006-01-02 15:04:05"),
+			ArrivalAt:   departure.Add(4 * time.Hour).Format("2006-01-02 15:04:05"),
+		}))
+		customerID := must(t)(s.store.Customer().Create(t.Context(), models.CreateCustomer{
+			FullName: names[1],
+			Phone:    "+99891123456" + string(rune('0'+i)),
+			Email:    "customer" + string(rune('0'+i)) + "@example.com",
+		}))
+		bookingID := must(t)(s.store.TripCustomer().Create(t.Context(), models.CreateTripCustomer{
+			TripID:     tripID,
+			CustomerID: customerID,
+			Seats:      1,
+		}))
+		paymentID := must(t)(s.store.Payment().Create(t.Context(), models.CreatePayment{
+			TripCustomerID: bookingID,
+			Provider:       payment.CashProvider,
+			Amount:         100000,
+			Currency:       "UZS",
+			Status:         models.PaymentStatusAuthorized,
+		}))
+
+		f.principals[names[0]] = auth.Principal{ID: driverID, Role: models.RoleDriver}
+		f.principals[names[1]] = auth.Principal{ID: customerID, Role: models.RoleCustomer}
+
+		// the routes are called on the resources of driver and customer
+		if i == 0 {
+			f.car, f.trip, f.booking, f.payment = carID, tripID, bookingID, paymentID
+		}
+	}
+
+	return f
+}
+
+// expand puts the ids of the fixture in place of the placeholders
+func (f fixture) expand(s string) string {
+	return strings.NewReplacer(
+		"{city}", f.city,
+		"{driver}", f.principals[driver].ID,
+		"{customer}", f.principals[customer].ID,
+		"{car}", f.car,
+		"{trip}", f.trip,
+		"{booking}", f.booking,
+		"{payment}", f.payment,
+		"{missing}", "7d2b8a4e-3c1f-4e55-8a90-1f6b2d3c4e5f",
+	).Replace(s)
+}
+
+func must(t *testing.T) func(id string, err error) string {
+	return func(id string, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	var (
+		everyone       = principals
+		staff          = []string{admin, dispatcher}
+		adminOnly      = []string{admin}
+		staffAndDriver = []string{admin, dispatcher, driver}
+		adminAndDriver = []string{admin, driver}
+	)
+
+	tests := []struct {
+		route   string
+		body    string
+		allowed []string
+	}{
+		{route: "GET /v1/auth/me", allowed: everyone},
+
+		{route: "GET /v1/cities", allowed: everyone},
+		{route: "POST /v1/cities", body: `{"name":"Bukhara"}`, allowed: adminOnly},
+		{route: "GET /v1/cities/{city}", allowed: everyone},
+		{route: "PUT /v1/cities/{city}", body: `{}`, allowed: adminOnly},
+		{route: "DELETE /v1/cities/{city}", allowed: adminOnly},
+
+		{route: "GET /v1/customers", allowed: staff},
+		{route: "GET /v1/customers/{customer}", allowed: []string{admin, dispatcher, customer}},
+		{route: "PUT /v1/customers/{customer}", body: `{}`, allowed: []string{admin, customer}},
+		{route: "DELETE /v1/customers/{customer}", allowed: adminOnly},
+
+		{route: "GET /v1/drivers", allowed: staff},
+		{route: "POST /v1/drivers", body: `{}`, allowed: adminOnly},
+		{route: "GET /v1/drivers/{driver}", allowed: staffAndDriver},
+		{route: "PUT /v1/drivers/{driver}", body: `{}`, allowed: adminOnly},
+		{route: "DELETE /v1/drivers/{driver}", allowed: adminOnly},
+		{route: "GET /v1/drivers/{driver}/balance", allowed: adminAndDriver},
+		{route: "GET /v1/drivers/{driver}/statement?from=2030-01-01&to=2030-01-31", allowed: adminAndDriver},
+		{route: "POST /v1/drivers/{driver}/payouts", body: `{}`, allowed: adminOnly},
+
+		{route: "GET /v1/cars", allowed: staff},
+		{route: "POST /v1/cars", body: `{}`, allowed: adminOnly},
+		{route: "GET /v1/cars/{car}", allowed: staffAndDriver},
+		{route: "PUT /v1/cars/{car}", body: `{}`, allowed: adminOnly},
+		{route: "DELETE /v1/cars/{car}", allowed: adminOnly},
+		{route: "PUT /v1/cars/{car}/status", body: `{"status":"active"}`, allowed: staffAndDriver},
+
+		{route: "GET /v1/trips", allowed: []string{admin, dispatcher, driver, otherDriver}},
+		{route: "POST /v1/trips", body: `{}`, allowed: staff},
+		{route: "GET /v1/trips/search", allowed: everyone},
+		{route: "GET /v1/trips/{trip}", allowed: []string{admin, dispatcher, driver, customer, otherCustomer}},
+		{route: "PUT /v1/trips/{trip}", body: `{}`, allowed: staff},
+		{route: "DELETE /v1/trips/{trip}", allowed: staff},
+		{route: "POST /v1/trips/{trip}/board", allowed: staffAndDriver},
+		{route: "POST /v1/trips/{trip}/start", allowed: staffAndDriver},
+		{route: "POST /v1/trips/{trip}/complete", allowed: staffAndDriver},
+		{route: "POST /v1/trips/{trip}/cancel", allowed: staff},
+		{route: "GET /v1/trips/{trip}/customers", allowed: staffAndDriver},
+		{route: "GET /v1/trips/{trip}/customers/unpaid", allowed: staffAndDriver},
+		{route: "GET /v1/trips/{trip}/totals", allowed: staffAndDriver},
+
+		{route: "GET /v1/trip_templates", allowed: staff},
+		{route: "POST /v1/trip_templates", body: `{}`, allowed: staff},
+		{route: "GET /v1/trip_templates/{missing}", allowed: staff},
+		{route: "PUT /v1/trip_templates/{missing}", body: `{}`, allowed: staff},
+		{route: "DELETE /v1/trip_templates/{missing}", allowed: staff},
+		{route: "POST /v1/trip_templates/{missing}/generate", body: `{}`, allowed: staff},
+
+		{route: "GET /v1/route_tariffs", allowed: everyone},
+		{route: "POST /v1/route_tariffs", body: `{}`, allowed: adminOnly},
+		{route: "GET /v1/route_tariffs/active", allowed: everyone},
+		{route: "GET /v1/route_tariffs/{missing}", allowed: everyone},
+
+		{route: "GET /v1/price_rules", allowed: staff},
+		{route: "POST /v1/price_rules", body: `{}`, allowed: adminOnly},
+		{route: "GET /v1/price_rules/{missing}", allowed: staff},
+		{route: "PUT /v1/price_rules/{missing}", body: `{}`, allowed: adminOnly},
+		{route: "DELETE /v1/price_rules/{missing}", allowed: adminOnly},
+
+		{route: "GET /v1/trip_customers", allowed: staff},
+		// a customer can only book for themselves, the body is checked by the handler
+		{route: "POST /v1/trip_customers", body: `{"trip_id":"{trip}","customer_id":"{customer}","seats":1}`, allowed: []string{admin, dispatcher, customer}},
+		{route: "GET /v1/trip_customers/{booking}", allowed: []string{admin, dispatcher, customer}},
+		{route: "PUT /v1/trip_customers/{booking}", body: `{}`, allowed: staff},
+		{route: "DELETE /v1/trip_customers/{booking}", allowed: staff},
+		{route: "POST /v1/trip_customers/{booking}/cancel", body: `{}`, allowed: []string{admin, dispatcher, customer}},
+
+		{route: "GET /v1/payments", allowed: staff},
+		{route: "POST /v1/payments", body: `{"trip_customer_id":"{booking}","provider":"cash"}`, allowed: []string{admin, dispatcher, customer}},
+		{route: "GET /v1/payments/{payment}", allowed: []string{admin, dispatcher, customer}},
+		{route: "POST /v1/payments/{payment}/capture", allowed: staff},
+		{route: "POST /v1/payments/{payment}/refund", body: `{}`, allowed: adminOnly},
+
+		{route: "GET /v1/reports?from=2030-01-01&to=2030-02-01", allowed: adminOnly},
+		{route: "POST /v1/import", allowed: adminOnly},
+	}
+
+	for _, tt := range tests {
+		for _, name := range principals {
+			t.Run(tt.route+" as "+name, func(t *testing.T) {
+				s := newServer(t)
+				f := s.fixture(t)
+
+				method, path, _ := strings.Cut(f.expand(tt.route), " ")
+				w, apiErr := s.do(t, method, path, "Bearer "+s.token(t, f.principals[name]), f.expand(tt.body))
+
+				allowed := false
+				for _, allowedName := range tt.allowed {
+					allowed = allowed || allowedName == name
+				}
+
+				switch {
+				case allowed && (w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized):
+					t.Fatalf("want the request allowed, got %d: %s", w.Code, w.Body.String())
+				case !allowed && (w.Code != http.StatusForbidden || apiErr.Code != "forbidden" || apiErr.Message == ""):
+					t.Fatalf("want 403 forbidden with a reason, got %d: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+// TestOwnerReasons checks the reasons of the ownership rules, and that a
+// resource which does not exist is not found rather than forbidden
+func TestOwnerReasons(t *testing.T) {
+	tests := []struct {
+		route     string
+		principal string
+		status    int
+		message   string
+	}{
+		{route: "GET /v1/cars/{car}", principal: otherDriver, status: http.StatusForbidden, message: "the car is not yours"},
+		{route: "GET /v1/trips/{trip}", principal: otherDriver, status: http.StatusForbidden, message: "the trip is not yours"},
+		{route: "GET /v1/trip_customers/{booking}", principal: otherCustomer, status: http.StatusForbidden, message: "the booking is not yours"},
+		{route: "GET /v1/payments/{payment}", principal: otherCustomer, status: http.StatusForbidden, message: "the payment is not yours"},
+		{route: "GET /v1/drivers/{driver}", principal: otherDriver, status: http.StatusForbidden, message: "a driver can only access their own account"},
+		{route: "GET /v1/customers/{customer}", principal: otherCustomer, status: http.StatusForbidden, message: "a customer can only access their own account"},
+		{route: "GET /v1/cities", principal: "", status: http.StatusUnauthorized, message: "access token is missing"},
+		{route: "GET /v1/reports", principal: dispatcher, status: http.StatusForbidden, message: "role dispatcher can not access GET /v1/reports"},
+
+		{route: "GET /v1/cars/{missing}", principal: driver, status: http.StatusNotFound},
+		{route: "GET /v1/trips/{missing}", principal: driver, status: http.StatusNotFound},
+		{route: "GET /v1/trip_customers/{missing}", principal: customer, status: http.StatusNotFound},
+		{route: "GET /v1/payments/{missing}", principal: customer, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route+" as "+tt.principal, func(t *testing.T) {
+			s := newServer(t)
+			f := s.fixture(t)
+
+			authorization := ""
+			if tt.principal != "" {
+				authorization = "Bearer " + s.token(t, f.principals[tt.principal])
+			}
+
+			method, path, _ := strings.Cut(f.expand(tt.route), " ")
+			w, apiErr := s.do(t, method, path, authorization, "")
+			if w.Code != tt.status {
+				t.Fatalf("want status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.message != "" && apiErr.Message != tt.message {
+				t.Fatalf("want message %q, got %q", tt.message, apiErr.Message)
+			}
+		})
+	}
+}
+
+// TestTripListOfDriver checks that drivers only get their own trips
+// whatever driver_id they ask for, while staff can filter by any driver
+func TestTripListOfDriver(t *testing.T) {
+	s := newServer(t)
+	f := s.fixture(t)
+
+	tests := []struct {
+		principal string
+		driverID  string
+		want      string
+	}{
+		{principal: driver, want: f.principals[driver].ID},
+		{principal: driver, driverID: f.principals[otherDriver].ID, want: f.principals[driver].ID},
+		{principal: otherDriver, driverID: f.principals[driver].ID, want: f.principals[otherDriver].ID},
+		{principal: dispatcher, driverID: f.principals[otherDriver].ID, want: f.principals[otherDriver].ID},
+		{principal: admin, driverID: f.principals[driver].ID, want: f.principals[driver].ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.principal, func(t *testing.T) {
+			w, _ := s.do(t, http.MethodGet, "/v1/trips?driver_id="+tt.driverID, "Bearer "+s.token(t, f.principals[tt.principal]), "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("want 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			resp := struct {
+				Data models.TripsResponse
+			}{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Data.Count != 1 || len(resp.Data.Trips) != 1 || resp.Data.Trips[0].DriverID != tt.want {
+				t.Fatalf("want the one trip of driver %s, got %+v", tt.want, resp.Data)
+			}
+		})
+	}
+
+	// staff without a filter get the trips of every driver
+	w, _ := s.do(t, http.MethodGet, "/v1/trips", "Bearer "+s.token(t, f.principals[dispatcher]), "")
+	resp := struct {
+		Data models.TripsResponse
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Count != 2 {
+		t.Fatalf("want 2 trips, got %d", resp.Data.Count)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/validation"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) CreatePriceRule(w http.ResponseWriter, r *http.Request) {
+	createRule := models.CreatePriceRule{}
+
+	if err := json.NewDecoder(r.Body).Decode(&createRule); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, createRule, checkPriceRule(createRule.Kind, createRule.Threshold, createRule.Percent)) {
+		return
+	}
+
+	id, err := h.storage.PriceRule().Create(r.Context(), createRule)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rule, err := h.storage.PriceRule().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, rule)
+}
+
+func (h Handler) GetPriceRuleByID(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	rule, err := h.storage.PriceRule().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, rule)
+}
+
+func (h Handler) GetPriceRuleList(w http.ResponseWriter, r *http.Request) {
+	var (
+		page, limit = 1, 10
+		err         error
+	)
+	values := r.URL.Query()
+	if len(values["page"]) > 0 {
+		page, err = strconv.Atoi(values["page"][0])
+		if err != nil {
+			page = 1
+		}
+	}
+
+	if len(values["limit"]) > 0 {
+		limit, err = strconv.Atoi(values["limit"][0])
+		if err != nil {
+			limit = 10
+		}
+	}
+
+	resp, err := h.storage.PriceRule().GetList(r.Context(), models.GetListRequest{
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (h Handler) UpdatePriceRule(w http.ResponseWriter, r *http.Request) {
+	updateRule := models.PriceRule{}
+
+	if err := json.NewDecoder(r.Body).Decode(&updateRule); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updateRule.ID = r.PathValue("id")
+
+	if !validate(w, updateRule, checkPriceRule(updateRule.Kind, updateRule.Threshold, updateRule.Percent)) {
+		return
+	}
+
+	id, err := h.storage.PriceRule().Update(r.Context(), updateRule)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	rule, err := h.storage.PriceRule().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, rule)
+}
+
+func (h Handler) DeletePriceRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := h.storage.PriceRule().Delete(r.Context(), id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, "data successfully deleted")
+}
+
+// checkPriceRule checks what the validate tags of the model can not, the
+// meaning of threshold and percent depends on the kind
+func checkPriceRule(kind string, threshold, percent int) error {
+	var errs validation.Errors
+
+	if kind == models.PriceRuleOccupancy && threshold > 100 {
+		errs = append(errs, models.FieldError{Field: "threshold", Message: "is a percent for occupancy rules and must be at most 100"})
+	}
+
+	// last minute rules make the seats left shortly before departure cheaper
+	if kind == models.PriceRuleLastMinute && percent > 0 {
+		errs = append(errs, models.FieldError{Field: "percent", Message: "must not be positive for last_minute rules"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/report"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Report returns trip statistics as JSON, or as CSV when format=csv is
+// given or the client accepts text/csv
+func (h Handler) Report(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	req := models.ReportRequest{
+		GroupBy: values.Get("group_by"),
+		Period:  values.Get("period"),
+		From:    values.Get("from"),
+		To:      values.Get("to"),
+	}
+
+	if req.GroupBy == "" {
+		req.GroupBy = models.ReportByRoute
+	}
+
+	if req.Period == "" {
+		req.Period = "day"
+	}
+
+	resp, err := report.Build(r.Context(), h.storage, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	if !wantsCSV(r) {
+		handleResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report_%s_%s_%s_%s.csv"`, resp.GroupBy, resp.Period, resp.From, resp.To))
+
+	if err := report.WriteCSV(w, resp); err != nil {
+		fmt.Println("error while writing csv report", err.Error())
+	}
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
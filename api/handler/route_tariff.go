@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) CreateRouteTariff(w http.ResponseWriter, r *http.Request) {
+	createTariff := models.CreateRouteTariff{}
+
+	if err := json.NewDecoder(r.Body).Decode(&createTariff); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, createTariff) {
+		return
+	}
+
+	id, err := h.storage.RouteTariff().Create(r.Context(), createTariff)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	tariff, err := h.storage.RouteTariff().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, tariff)
+}
+
+func (h Handler) GetRouteTariffByID(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	tariff, err := h.storage.RouteTariff().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, tariff)
+}
+
+func (h Handler) GetActiveRouteTariff(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	at := ""
+	if value := values.Get("at"); value != "" {
+		var err error
+		if at, err = parseDateTime(value); err != nil {
+			handleResponse(w, http.StatusBadRequest, "at is not correct: "+err.Error())
+			return
+		}
+	}
+
+	tariff, err := h.storage.RouteTariff().GetActive(r.Context(), values.Get("from_city_id"), values.Get("to_city_id"), at)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, tariff)
+}
+
+func (h Handler) GetRouteTariffList(w http.ResponseWriter, r *http.Request) {
+	var (
+		page, limit = 1, 10
+		err         error
+	)
+	values := r.URL.Query()
+	if len(values["page"]) > 0 {
+		page, err = strconv.Atoi(values["page"][0])
+		if err != nil {
+			page = 1
+		}
+	}
+
+	if len(values["limit"]) > 0 {
+		limit, err = strconv.Atoi(values["limit"][0])
+		if err != nil {
+			limit = 10
+		}
+	}
+
+	resp, err := h.storage.RouteTariff().GetList(r.Context(), models.GetRouteTariffListRequest{
+		Page:       page,
+		Limit:      limit,
+		FromCityID: values.Get("from_city_id"),
+		ToCityID:   values.Get("to_city_id"),
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
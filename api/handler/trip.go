@@ -2,29 +2,15 @@ package handler
 
 import (
 	"city2city/api/models"
+	"city2city/auth"
+	"city2city/check"
+	"city2city/storage"
 	"encoding/json"
-	"errors"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-func (h Handler) Trip(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateTrip(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		if _, ok := values["id"]; !ok {
-			h.GetTripList(w)
-		} else {
-			h.GetTripByID(w, r)
-		}
-	case http.MethodPut:
-		h.UpdateTrip(w, r)
-	case http.MethodDelete:
-		h.DeleteTrip(w, r)
-	}
-}
-
 func (h Handler) CreateTrip(w http.ResponseWriter, r *http.Request) {
 
 	createTrip := models.CreateTrip{}
@@ -34,17 +20,45 @@ func (h Handler) CreateTrip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Trip().Create(createTrip)
+	if !validate(w, createTrip, check.TripTime(createTrip.DepartureAt, createTrip.ArrivalAt)) {
+		return
+	}
+
+	for _, passenger := range createTrip.Passengers {
+		if !validate(w, passenger) {
+			return
+		}
+	}
+
+	var id string
+	err := h.storage.WithTx(r.Context(), func(tx storage.IStorage) error {
+		var err error
+		if id, err = tx.Trip().Create(r.Context(), createTrip); err != nil {
+			return err
+		}
+
+		for _, passenger := range createTrip.Passengers {
+			if _, err := tx.TripCustomer().Create(r.Context(), models.CreateTripCustomer{
+				TripID:     id,
+				CustomerID: passenger.CustomerID,
+				Seats:      passenger.Seats,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
-	user, err := h.storage.Trip().Get(models.PrimaryKey{
+	user, err := h.storage.Trip().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -56,20 +70,14 @@ func (h Handler) CreateTrip(w http.ResponseWriter, r *http.Request) {
 
 func (h Handler) GetTripByID(w http.ResponseWriter, r *http.Request) {
 
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
+	id := r.PathValue("id")
 	var err error
 
-	user, err := h.storage.Trip().Get(models.PrimaryKey{
+	user, err := h.storage.Trip().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -79,18 +87,53 @@ func (h Handler) GetTripByID(w http.ResponseWriter, r *http.Request) {
 
 // TASK 10
 
-func (h Handler) GetTripList(w http.ResponseWriter) {
-	var (
-		page, limit = 1, 10
-		err         error
-	)
+func (h Handler) GetTripList(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	list := listRequest(r, 10)
+
+	// status and driver_id are checked here, they are not filters of the
+	// list query
+	delete(list.Filters, "status")
+	delete(list.Filters, "driver_id")
+
+	status := values.Get("status")
+	if status != "" && !storage.IsTripStatus(status) {
+		handleError(w, storage.ErrUnknownTripStatus)
+		return
+	}
+
+	// drivers only see their own trips
+	driverID := values.Get("driver_id")
+	if principal, _ := auth.FromContext(r.Context()); principal.Role == models.RoleDriver {
+		driverID = principal.ID
+	}
+
+	if wantsCSV(r) {
+		header := []string{
+			"id", "trip_number_id", "from_city", "to_city", "driver", "price", "seats", "free_seats",
+			"status", "departure_at", "arrival_at", "created_at",
+		}
+		streamCSV(w, "trips", header, func(write func([]string) error) error {
+			return h.storage.Trip().Stream(r.Context(), models.GetTripListRequest{Status: status, DriverID: driverID, ListQuery: list.ListQuery}, func(trip models.Trip) error {
+				return write([]string{
+					trip.ID, trip.TripNumberID, trip.FromCityData.Name, trip.ToCityData.Name, trip.DriverData.FullName,
+					itoa(trip.Price), itoa(trip.Seats), itoa(trip.FreeSeats),
+					trip.Status, trip.DepartureAt, trip.ArrivalAt, trip.CreatedAt,
+				})
+			})
+		})
+		return
+	}
 
-	resp, err := h.storage.Trip().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
+	resp, err := h.storage.Trip().GetList(r.Context(), models.GetTripListRequest{
+		Page:      list.Page,
+		Limit:     list.Limit,
+		Status:    status,
+		DriverID:  driverID,
+		ListQuery: list.ListQuery,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -106,17 +149,23 @@ func (h Handler) UpdateTrip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.Trip().Update(updateTrip)
+	updateTrip.ID = r.PathValue("id")
+
+	if !validate(w, updateTrip, check.TripTime(updateTrip.DepartureAt, updateTrip.ArrivalAt)) {
+		return
+	}
+
+	id, err := h.storage.Trip().Update(r.Context(), updateTrip)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	trip, err := h.storage.Trip().Get(models.PrimaryKey{
+	trip, err := h.storage.Trip().Get(r.Context(), models.PrimaryKey{
 		ID: id,
 	})
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err)
+		handleError(w, err)
 		return
 	}
 
@@ -124,22 +173,123 @@ func (h Handler) UpdateTrip(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h Handler) DeleteTrip(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
+// TripTransition returns a handler that moves the trip given by id to the status
+func (h Handler) TripTransition(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		if err := h.storage.Trip().UpdateStatus(r.Context(), models.UpdateTripStatus{
+			ID:     id,
+			Status: status,
+		}); err != nil {
+			handleError(w, err)
+			return
+		}
+
+		trip, err := h.storage.Trip().Get(r.Context(), models.PrimaryKey{
+			ID: id,
+		})
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+
+		handleResponse(w, http.StatusOK, trip)
 	}
+}
 
-	id := values["id"][0]
+func (h Handler) DeleteTrip(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
 
-	if err := h.storage.Trip().Delete(models.PrimaryKey{
+	if err := h.storage.Trip().Delete(r.Context(), models.PrimaryKey{
 		ID: id,
 	}); err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
 	handleResponse(w, http.StatusOK, "data successfully deleted")
 
 }
+
+func (h Handler) SearchTrip(w http.ResponseWriter, r *http.Request) {
+	var (
+		values = r.URL.Query()
+		req    = models.SearchTripRequest{
+			FromCityID:    values.Get("from_city_id"),
+			ToCityID:      values.Get("to_city_id"),
+			DepartureFrom: time.Now().Format(time.RFC3339),
+			MinFreeSeats:  1,
+			Page:          1,
+			Limit:         10,
+		}
+		err error
+	)
+
+	// date selects the whole day, departure_from/departure_to narrow it down to a time window
+	if date := values.Get("date"); date != "" {
+		day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			handleResponse(w, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+			return
+		}
+		req.DepartureFrom = day.Format(time.RFC3339)
+		req.DepartureTo = day.AddDate(0, 0, 1).Format(time.RFC3339)
+	}
+
+	if from := values.Get("departure_from"); from != "" {
+		if req.DepartureFrom, err = parseDateTime(from); err != nil {
+			handleResponse(w, http.StatusBadRequest, "departure_from is not correct: "+err.Error())
+			return
+		}
+	}
+
+	if to := values.Get("departure_to"); to != "" {
+		if req.DepartureTo, err = parseDateTime(to); err != nil {
+			handleResponse(w, http.StatusBadRequest, "departure_to is not correct: "+err.Error())
+			return
+		}
+	}
+
+	if maxPrice := values.Get("max_price"); maxPrice != "" {
+		if req.MaxPrice, err = strconv.Atoi(maxPrice); err != nil {
+			handleResponse(w, http.StatusBadRequest, "max_price must be a number")
+			return
+		}
+	}
+
+	if minFreeSeats := values.Get("min_free_seats"); minFreeSeats != "" {
+		if req.MinFreeSeats, err = strconv.Atoi(minFreeSeats); err != nil {
+			handleResponse(w, http.StatusBadRequest, "min_free_seats must be a number")
+			return
+		}
+	}
+
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 0 {
+		req.Page = page
+	}
+
+	if limit, err := strconv.Atoi(values.Get("limit")); err == nil && limit > 0 {
+		req.Limit = limit
+	}
+
+	resp, err := h.storage.Trip().Search(r.Context(), req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// parseDateTime accepts RFC3339 or "2006-01-02 15:04" and returns the value in RFC3339
+func parseDateTime(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01-02 15:04", value, time.Local); err != nil {
+			return "", err
+		}
+	}
+
+	return t.Format(time.RFC3339), nil
+}
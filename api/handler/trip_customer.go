@@ -3,29 +3,9 @@ package handler
 import (
 	"city2city/api/models"
 	"encoding/json"
-	"errors"
 	"net/http"
-	"strconv"
 )
 
-func (h Handler) TripCustomer(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		h.CreateTripCustomer(w, r)
-	case http.MethodGet:
-		values := r.URL.Query()
-		if _, ok := values["id"]; !ok {
-			h.GetTripCustomerList(w, r)
-		} else {
-			h.GetTripCustomerByID(w, r)
-		}
-	case http.MethodPut:
-		h.UpdateTripCustomer(w, r)
-	case http.MethodDelete:
-		h.DeleteTripCustomer(w, r)
-	}
-}
-
 func (h Handler) CreateTripCustomer(w http.ResponseWriter, r *http.Request) {
 	tripCustomer := models.CreateTripCustomer{}
 
@@ -34,15 +14,23 @@ func (h Handler) CreateTripCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.TripCustomer().Create(tripCustomer)
+	if !validate(w, tripCustomer) {
+		return
+	}
+
+	if !actsForSelf(w, r, models.RoleCustomer, tripCustomer.CustomerID, "customers can only book for themselves") {
+		return
+	}
+
+	id, err := h.storage.TripCustomer().Create(r.Context(), tripCustomer)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	createdTrip, err := h.storage.TripCustomer().Get(id)
+	createdTrip, err := h.storage.TripCustomer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
@@ -50,16 +38,10 @@ func (h Handler) CreateTripCustomer(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetTripCustomerByID(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
-		return
-	}
-
-	id := values["id"][0]
-	tripCustomer, err := h.storage.TripCustomer().Get(id)
+	id := r.PathValue("id")
+	tripCustomer, err := h.storage.TripCustomer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
@@ -67,31 +49,28 @@ func (h Handler) GetTripCustomerByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetTripCustomerList(w http.ResponseWriter, r *http.Request) {
-	var (
-		page, limit = 1, 10
-		err         error
-	)
-	values := r.URL.Query()
-	if len(values["page"]) > 0 {
-		page, err = strconv.Atoi(values["page"][0])
-		if err != nil {
-			page = 1
-		}
-	}
+	req := listRequest(r, 10)
 
-	if len(values["limit"]) > 0 {
-		limit, err = strconv.Atoi(values["limit"][0])
-		if err != nil {
-			limit = 10
+	if wantsCSV(r) {
+		header := []string{
+			"id", "trip_id", "customer_id", "customer_name", "customer_phone", "seats", "fare",
+			"currency", "status", "payment_status", "refund_amount", "cancelled_at", "created_at",
 		}
+		streamCSV(w, "trip_customers", header, func(write func([]string) error) error {
+			return h.storage.TripCustomer().Stream(r.Context(), req.ListQuery, func(tc models.TripCustomer) error {
+				return write([]string{
+					tc.ID, tc.TripID, tc.CustomerID, tc.CustomerData.FullName, tc.CustomerData.Phone,
+					itoa(tc.Seats), itoa(tc.Fare), tc.Currency, tc.Status, tc.PaymentStatus,
+					itoa(tc.RefundAmount), optional(tc.CancelledAt), tc.CreatedAt,
+				})
+			})
+		})
+		return
 	}
 
-	trips, err := h.storage.TripCustomer().GetList(models.GetListRequest{
-		Page:  page,
-		Limit: limit,
-	})
+	trips, err := h.storage.TripCustomer().GetList(r.Context(), req)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
@@ -107,15 +86,21 @@ func (h Handler) UpdateTripCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.storage.TripCustomer().Update(tripCustomer)
+	tripCustomer.ID = r.PathValue("id")
+
+	if !validate(w, tripCustomer) {
+		return
+	}
+
+	id, err := h.storage.TripCustomer().Update(r.Context(), tripCustomer)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	updatedTrip, err := h.storage.TripCustomer().Get(id)
+	updatedTrip, err := h.storage.TripCustomer().Get(r.Context(), id)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
@@ -124,19 +109,76 @@ func (h Handler) UpdateTripCustomer(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) DeleteTripCustomer(w http.ResponseWriter, r *http.Request) {
-	values := r.URL.Query()
-	if len(values["id"]) <= 0 {
-		handleResponse(w, http.StatusBadRequest, errors.New("id is required"))
+	id := r.PathValue("id")
+	err := h.storage.TripCustomer().Delete(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, "trip customer deleted!")
+
+}
+
+func (h Handler) CancelTripCustomer(w http.ResponseWriter, r *http.Request) {
+	cancel := models.CancelTripCustomer{}
+
+	if err := json.NewDecoder(r.Body).Decode(&cancel); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cancel.ID = r.PathValue("id")
+
+	if !validate(w, cancel) {
+		return
+	}
+
+	if err := h.storage.TripCustomer().Cancel(r.Context(), cancel); err != nil {
+		handleError(w, err)
 		return
 	}
 
-	id := values["id"][0]
-	err := h.storage.TripCustomer().Delete(id)
+	cancelled, err := h.storage.TripCustomer().Get(r.Context(), cancel.ID)
 	if err != nil {
-		handleResponse(w, http.StatusInternalServerError, err.Error())
+		handleError(w, err)
 		return
 	}
 
-	handleResponse(w, http.StatusOK, "trip customer deleted!")
+	handleResponse(w, http.StatusOK, cancelled)
+}
 
-}
\ No newline at end of file
+// GetTripCustomersByTrip returns the bookings of the trip given in the path
+func (h Handler) GetTripCustomersByTrip(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.storage.TripCustomer().GetByTrip(r.Context(), r.PathValue("id"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (h Handler) GetTripTotals(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	totals, err := h.storage.TripCustomer().GetTripTotals(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, totals)
+}
+
+func (h Handler) GetUnpaidTripCustomers(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	resp, err := h.storage.TripCustomer().GetUnpaid(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"city2city/api/models"
+	"city2city/check"
+	"city2city/schedule"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) CreateTripTemplate(w http.ResponseWriter, r *http.Request) {
+	createTemplate := models.CreateTripTemplate{}
+
+	if err := json.NewDecoder(r.Body).Decode(&createTemplate); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validate(w, createTemplate, check.Schedule(createTemplate.Weekdays, createTemplate.DepartureTime, createTemplate.DurationMinutes)) {
+		return
+	}
+
+	id, err := h.storage.TripTemplate().Create(r.Context(), createTemplate)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	template, err := h.storage.TripTemplate().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, template)
+}
+
+func (h Handler) GetTripTemplateByID(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	template, err := h.storage.TripTemplate().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, template)
+}
+
+func (h Handler) GetTripTemplateList(w http.ResponseWriter, r *http.Request) {
+	var (
+		page, limit = 1, 10
+		err         error
+	)
+	values := r.URL.Query()
+	if len(values["page"]) > 0 {
+		page, err = strconv.Atoi(values["page"][0])
+		if err != nil {
+			page = 1
+		}
+	}
+
+	if len(values["limit"]) > 0 {
+		limit, err = strconv.Atoi(values["limit"][0])
+		if err != nil {
+			limit = 10
+		}
+	}
+
+	resp, err := h.storage.TripTemplate().GetList(r.Context(), models.GetListRequest{
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (h Handler) UpdateTripTemplate(w http.ResponseWriter, r *http.Request) {
+	updateTemplate := models.TripTemplate{}
+
+	if err := json.NewDecoder(r.Body).Decode(&updateTemplate); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updateTemplate.ID = r.PathValue("id")
+
+	if !validate(w, updateTemplate, check.Schedule(updateTemplate.Weekdays, updateTemplate.DepartureTime, updateTemplate.DurationMinutes)) {
+		return
+	}
+
+	id, err := h.storage.TripTemplate().Update(r.Context(), updateTemplate)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	template, err := h.storage.TripTemplate().Get(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, template)
+}
+
+func (h Handler) DeleteTripTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := h.storage.TripTemplate().Delete(r.Context(), id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, "data successfully deleted")
+}
+
+func (h Handler) GenerateTrips(w http.ResponseWriter, r *http.Request) {
+	generate := models.GenerateTrips{}
+
+	if err := json.NewDecoder(r.Body).Decode(&generate); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	generate.TemplateID = r.PathValue("id")
+
+	if !validate(w, generate) {
+		return
+	}
+
+	resp, err := schedule.Generate(r.Context(), h.storage, generate)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	RoleAdmin      = "admin"
+	RoleDispatcher = "dispatcher"
+	RoleCustomer   = "customer"
+	RoleDriver     = "driver"
+)
+
+type Admin struct {
+	ID           string `json:"id"`
+	Login        string `json:"login"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// OTP is a one time login code sent to a phone, only its hash is kept
+type OTP struct {
+	Phone     string
+	Role      string
+	CodeHash  string
+	ExpiresAt time.Time
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type AdminLogin struct {
+	Login    string `json:"login" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RequestOTP struct {
+	Phone string `json:"phone" validate:"required,phone"`
+	Role  string `json:"role" validate:"required,oneof=customer driver"`
+}
+
+type VerifyOTP struct {
+	Phone string `json:"phone" validate:"required,phone"`
+	Role  string `json:"role" validate:"required,oneof=customer driver"`
+	Code  string `json:"code" validate:"required"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
@@ -1,21 +1,23 @@
 package models
 
 type Car struct {
-	ID         string `json:"id"`
-	Model      string `json:"model"`
-	Brand      string `json:"brand"`
-	Number     string `json:"number"`
+	ID         string `json:"id" validate:"uuid"`
+	Model      string `json:"model" validate:"required,max=30"`
+	Brand      string `json:"brand" validate:"required,max=30"`
+	Number     string `json:"number" validate:"required,max=30"`
 	Status     string `json:"status"`
-	DriverID   string `json:"driver_id"`
+	Seats      int    `json:"seats" validate:"min=0"`
+	DriverID   string `json:"driver_id" validate:"required,uuid"`
 	DriverData Driver `json:"driver_data"`
 	CreatedAt  string `json:"created_at"`
 }
 
 type CreateCar struct {
-	Model    string `json:"model"`
-	Brand    string `json:"brand"`
-	Number   string `json:"number"`
-	DriverID string `json:"driver_id"`
+	Model    string `json:"model" validate:"required,max=30"`
+	Brand    string `json:"brand" validate:"required,max=30"`
+	Number   string `json:"number" validate:"required,max=30"`
+	Seats    int    `json:"seats" validate:"min=0"`
+	DriverID string `json:"driver_id" validate:"required,uuid"`
 }
 
 type CarsResponse struct {
@@ -24,6 +26,6 @@ type CarsResponse struct {
 }
 
 type UpdateCarStatus struct {
-	ID     string `json:"id"`
+	ID     string `json:"id" validate:"uuid"`
 	Status bool   `json:"status"`
 }
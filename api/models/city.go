@@ -1,13 +1,13 @@
 package models
 
 type City struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
+	ID        string `json:"id" validate:"uuid"`
+	Name      string `json:"name" validate:"required,min=4,max=30"`
 	CreatedAt string `json:"created_at"`
 }
 
 type CreateCity struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,min=4,max=30"`
 }
 
 type CitiesResponse struct {
@@ -1,17 +1,17 @@
 package models
 
 type Customer struct {
-	ID        string `json:"id"`
-	FullName  string `json:"full_name"`
-	Phone     string `json:"phone"`
-	Email     string `json:"email"`
+	ID        string `json:"id" validate:"uuid"`
+	FullName  string `json:"full_name" validate:"required"`
+	Phone     string `json:"phone" validate:"required,phone"`
+	Email     string `json:"email" validate:"email"`
 	CreatedAt string `json:"created_at"`
 }
 
 type CreateCustomer struct {
-	FullName string `json:"full_name"`
-	Phone    string `json:"phone"`
-	Email    string `json:"email"`
+	FullName string `json:"full_name" validate:"required"`
+	Phone    string `json:"phone" validate:"required,phone"`
+	Email    string `json:"email" validate:"email"`
 }
 
 type CustomersResponse struct {
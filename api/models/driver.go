@@ -1,21 +1,21 @@
 package models
 
 type Driver struct {
-	ID           string `json:"id"`
-	FullName     string `json:"full_name"`
-	Phone        string `json:"phone"`
-	FromCityID   string `json:"from_city_id"`
+	ID           string `json:"id" validate:"uuid"`
+	FullName     string `json:"full_name" validate:"required"`
+	Phone        string `json:"phone" validate:"required,phone"`
+	FromCityID   string `json:"from_city_id" validate:"required,uuid"`
 	FromCityData City   `json:"from_city_data"`
-	ToCityID     string `json:"to_city_id"`
+	ToCityID     string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
 	ToCityData   City   `json:"to_city_data"`
 	CreatedAt    string `json:"created_at"`
 }
 
 type CreateDriver struct {
-	FullName   string `json:"full_name"`
-	Phone      string `json:"phone"`
-	FromCityID string `json:"from_city_id"`
-	ToCityID   string `json:"to_city_id"`
+	FullName   string `json:"full_name" validate:"required"`
+	Phone      string `json:"phone" validate:"required,phone"`
+	FromCityID string `json:"from_city_id" validate:"required,uuid"`
+	ToCityID   string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
 }
 
 type DriversResponse struct {
@@ -0,0 +1,51 @@
+package models
+
+const (
+	LedgerCredit = "credit"
+	LedgerPayout = "payout"
+)
+
+// LedgerEntry is a movement on a driver balance. Credits are the earnings
+// of completed trips after the platform commission, payouts are the money
+// paid to the driver. Amount is always positive.
+type LedgerEntry struct {
+	ID          string  `json:"id"`
+	DriverID    string  `json:"driver_id"`
+	TripID      *string `json:"trip_id"`
+	Kind        string  `json:"kind"`
+	Amount      int     `json:"amount"`
+	Commission  int     `json:"commission"`
+	Description string  `json:"description"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+type CreatePayout struct {
+	DriverID    string `json:"driver_id" validate:"uuid"`
+	Amount      int    `json:"amount" validate:"min=1"`
+	Description string `json:"description"`
+}
+
+type DriverBalance struct {
+	DriverID string `json:"driver_id"`
+	Credited int    `json:"credited"`
+	PaidOut  int    `json:"paid_out"`
+	Balance  int    `json:"balance"`
+}
+
+// GetStatementRequest selects the entries created in [From, To)
+type GetStatementRequest struct {
+	DriverID string `json:"driver_id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+type DriverStatement struct {
+	DriverID       string        `json:"driver_id"`
+	From           string        `json:"from"`
+	To             string        `json:"to"`
+	OpeningBalance int           `json:"opening_balance"`
+	Credited       int           `json:"credited"`
+	PaidOut        int           `json:"paid_out"`
+	ClosingBalance int           `json:"closing_balance"`
+	Entries        []LedgerEntry `json:"entries"`
+}
@@ -0,0 +1,58 @@
+package models
+
+const (
+	ImportCities  = "cities"
+	ImportDrivers = "drivers"
+	ImportCars    = "cars"
+)
+
+// ImportCity is one row of a cities csv, Line is the csv line it came from
+type ImportCity struct {
+	Line int    `json:"line"`
+	Name string `json:"name"`
+}
+
+// ImportDriver is one row of a drivers csv, cities are given by name
+type ImportDriver struct {
+	Line     int    `json:"line"`
+	FullName string `json:"full_name"`
+	Phone    string `json:"phone"`
+	FromCity string `json:"from_city"`
+	ToCity   string `json:"to_city"`
+}
+
+// ImportCar is one row of a cars csv, the driver is given by phone
+type ImportCar struct {
+	Line        int    `json:"line"`
+	Model       string `json:"model"`
+	Brand       string `json:"brand"`
+	Number      string `json:"number"`
+	Seats       int    `json:"seats"`
+	DriverPhone string `json:"driver_phone"`
+}
+
+// Import holds every parsed row, they are inserted in one transaction in
+// the order cities, drivers, cars. With DryRun the transaction is always
+// rolled back.
+type Import struct {
+	Cities  []ImportCity   `json:"cities"`
+	Drivers []ImportDriver `json:"drivers"`
+	Cars    []ImportCar    `json:"cars"`
+	DryRun  bool           `json:"dry_run"`
+}
+
+type ImportError struct {
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResult counts the inserted rows. Cities that already exist are
+// reused and not counted. When Errors is not empty nothing was inserted.
+type ImportResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Cities  int           `json:"cities"`
+	Drivers int           `json:"drivers"`
+	Cars    int           `json:"cars"`
+	Errors  []ImportError `json:"errors"`
+}
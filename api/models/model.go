@@ -3,6 +3,23 @@ package models
 type GetListRequest struct {
 	Page  int `json:"page"`
 	Limit int `json:"limit"`
+	ListQuery
+}
+
+// ListQuery narrows and orders a list. Filters keep the rows whose field
+// equals the value, Search keeps the rows with the text in one of the
+// searchable fields of the resource, like names, phones or car numbers.
+type ListQuery struct {
+	Filters map[string]string `json:"filters"`
+	Sort    []SortField       `json:"sort"`
+	Search  string            `json:"search"`
+}
+
+// SortField orders a list by Field, in the query string it is written as
+// price or -price for descending order
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
 }
 
 type PrimaryKey struct {
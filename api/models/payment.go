@@ -0,0 +1,63 @@
+package models
+
+const (
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCapturing  = "capturing"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusRefunding  = "refunding"
+	PaymentStatusRefunded   = "refunded"
+	PaymentStatusFailed     = "failed"
+	PaymentStatusVoided     = "voided"
+
+	// payment status of a booking
+	BookingUnpaid   = "unpaid"
+	BookingPaid     = "paid"
+	BookingRefunded = "refunded"
+)
+
+type Payment struct {
+	ID             string `json:"id"`
+	TripCustomerID string `json:"trip_customer_id"`
+	Provider       string `json:"provider"`
+	Amount         int    `json:"amount"`
+	RefundedAmount int    `json:"refunded_amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+	Reference      string `json:"reference"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+type CreatePayment struct {
+	TripCustomerID string `json:"trip_customer_id"`
+	Provider       string `json:"provider"`
+	Amount         int    `json:"amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+	Reference      string `json:"reference"`
+}
+
+type PayTripCustomer struct {
+	TripCustomerID string `json:"trip_customer_id" validate:"required,uuid"`
+	Provider       string `json:"provider" validate:"required"`
+}
+
+// UpdatePaymentStatus moves the payment from the status From to Status
+type UpdatePaymentStatus struct {
+	ID             string `json:"id"`
+	From           string `json:"from"`
+	Status         string `json:"status"`
+	RefundedAmount int    `json:"refunded_amount"`
+}
+
+type GetPaymentListRequest struct {
+	Page           int    `json:"page"`
+	Limit          int    `json:"limit"`
+	TripCustomerID string `json:"trip_customer_id"`
+	Status         string `json:"status"`
+}
+
+type PaymentsResponse struct {
+	Payments []Payment `json:"payments"`
+	Count    int       `json:"count"`
+}
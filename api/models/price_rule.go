@@ -0,0 +1,33 @@
+package models
+
+const (
+	// PriceRuleOccupancy applies when at least Threshold percent of the trip seats are booked
+	PriceRuleOccupancy = "occupancy"
+	// PriceRuleLastMinute applies when the trip departs in Threshold minutes or less
+	PriceRuleLastMinute = "last_minute"
+)
+
+// PriceRule changes the trip price by Percent (negative for a discount)
+// for bookings made while the rule applies
+type PriceRule struct {
+	ID        string `json:"id" validate:"uuid"`
+	Name      string `json:"name" validate:"required"`
+	Kind      string `json:"kind" validate:"required,oneof=occupancy last_minute"`
+	Threshold int    `json:"threshold" validate:"min=0"`
+	Percent   int    `json:"percent" validate:"min=-99"`
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+}
+
+type CreatePriceRule struct {
+	Name      string `json:"name" validate:"required"`
+	Kind      string `json:"kind" validate:"required,oneof=occupancy last_minute"`
+	Threshold int    `json:"threshold" validate:"min=0"`
+	Percent   int    `json:"percent" validate:"min=-99"`
+	Active    bool   `json:"active"`
+}
+
+type PriceRulesResponse struct {
+	PriceRules []PriceRule `json:"price_rules"`
+	Count      int         `json:"count"`
+}
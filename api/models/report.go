@@ -0,0 +1,40 @@
+package models
+
+const (
+	ReportByRoute  = "route"
+	ReportByDriver = "driver"
+)
+
+// ReportRequest asks for trip statistics grouped by route or driver for
+// every day, week or month of departures in [From, To)
+type ReportRequest struct {
+	GroupBy string `json:"group_by"`
+	Period  string `json:"period"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// ReportRow holds the statistics of one route or driver in one period.
+// Cancelled trips and cancelled bookings are not counted.
+type ReportRow struct {
+	Period       string  `json:"period"`
+	FromCityID   string  `json:"from_city_id,omitempty"`
+	FromCityName string  `json:"from_city_name,omitempty"`
+	ToCityID     string  `json:"to_city_id,omitempty"`
+	ToCityName   string  `json:"to_city_name,omitempty"`
+	DriverID     string  `json:"driver_id,omitempty"`
+	DriverName   string  `json:"driver_name,omitempty"`
+	Trips        int     `json:"trips"`
+	Passengers   int     `json:"passengers"`
+	Seats        int     `json:"seats"`
+	Occupancy    float64 `json:"occupancy"`
+	Revenue      int     `json:"revenue"`
+}
+
+type Report struct {
+	GroupBy string      `json:"group_by"`
+	Period  string      `json:"period"`
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Rows    []ReportRow `json:"rows"`
+}
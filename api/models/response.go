@@ -4,4 +4,21 @@ type Response struct {
 	StatusCode  int
 	Description string
 	Data        interface{}
-}
\ No newline at end of file
+}
+
+// Error is the data of an error response, Code is stable and can be
+// checked by clients, Message is for people. Details is the data of the
+// failed operation when it has more to tell, like the rows of an import.
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Details interface{}  `json:"details,omitempty"`
+}
+
+// FieldError tells why the field of a request is not valid, Field is the
+// json name of it
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// RouteTariff is the fare of a route for a period. BasePrice is charged once
+// per booking and SeatPrice for every booked seat, so a trip created without
+// a price gets BasePrice + SeatPrice, the fare of a single seat booking.
+// ValidTo is nil while the tariff is not replaced by a newer one.
+type RouteTariff struct {
+	ID           string  `json:"id"`
+	FromCityID   string  `json:"from_city_id"`
+	FromCityData City    `json:"from_city_data"`
+	ToCityID     string  `json:"to_city_id"`
+	ToCityData   City    `json:"to_city_data"`
+	BasePrice    int     `json:"base_price"`
+	SeatPrice    int     `json:"seat_price"`
+	ValidFrom    string  `json:"valid_from"`
+	ValidTo      *string `json:"valid_to"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+type CreateRouteTariff struct {
+	FromCityID string `json:"from_city_id" validate:"required,uuid"`
+	ToCityID   string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+	BasePrice  int    `json:"base_price" validate:"min=0"`
+	SeatPrice  int    `json:"seat_price" validate:"min=0"`
+	ValidFrom  string `json:"valid_from"`
+}
+
+type GetRouteTariffListRequest struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	FromCityID string `json:"from_city_id"`
+	ToCityID   string `json:"to_city_id"`
+}
+
+type RouteTariffsResponse struct {
+	RouteTariffs []RouteTariff `json:"route_tariffs"`
+	Count        int           `json:"count"`
+}
@@ -1,28 +1,82 @@
 package models
 
+const (
+	TripStatusScheduled  = "scheduled"
+	TripStatusBoarding   = "boarding"
+	TripStatusInProgress = "in_progress"
+	TripStatusCompleted  = "completed"
+	TripStatusCancelled  = "cancelled"
+)
+
 type Trip struct {
-	ID           string `json:"id"`
-	TripNumberID string `json:"trip_number_id"`
-	FromCityID   string `json:"from_city_id"`
-	FromCityData City   `json:"from_city_data"`
-	ToCityID     string `json:"to_city_id"`
-	ToCityData   City   `json:"to_city_data"`
-	DriverID     string `json:"driver_id"`
-	DriverData   Driver `json:"driver_data"`
-	Price        int    `json:"price"`
-	CreatedAt    string `json:"created_at"`
+	ID           string  `json:"id" validate:"uuid"`
+	TripNumberID string  `json:"trip_number_id"`
+	FromCityID   string  `json:"from_city_id" validate:"required,uuid"`
+	FromCityData City    `json:"from_city_data"`
+	ToCityID     string  `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+	ToCityData   City    `json:"to_city_data"`
+	DriverID     string  `json:"driver_id" validate:"required,uuid"`
+	DriverData   Driver  `json:"driver_data"`
+	Price        int     `json:"price" validate:"min=0"`
+	Seats        int     `json:"seats"`
+	FreeSeats    int     `json:"free_seats"`
+	TemplateID   *string `json:"template_id"`
+	TariffID     *string `json:"tariff_id"`
+	DepartureAt  string  `json:"departure_at" validate:"required"`
+	ArrivalAt    string  `json:"arrival_at" validate:"required"`
+	Status       string  `json:"status"`
+	BoardingAt   *string `json:"boarding_at"`
+	StartedAt    *string `json:"started_at"`
+	CompletedAt  *string `json:"completed_at"`
+	CancelledAt  *string `json:"cancelled_at"`
+	CreatedAt    string  `json:"created_at"`
 }
 
 type CreateTrip struct {
-	TripNumberID string `json:"trip_number_id"`
-	FromCityID   string `json:"from_city_id"`
-	ToCityID     string `json:"to_city_id"`
-	DriverID     string `json:"driver_id"`
-	Price        int    `json:"price"`
-	CreatedAt    string `json:"created_at"`
+	TripNumberID string `json:"trip_number_id" validate:"max=20"`
+	FromCityID   string `json:"from_city_id" validate:"required,uuid"`
+	ToCityID     string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+	DriverID     string `json:"driver_id" validate:"required,uuid"`
+	Price        int    `json:"price" validate:"min=0"`
+	DepartureAt  string `json:"departure_at" validate:"required"`
+	ArrivalAt    string `json:"arrival_at" validate:"required"`
+	TemplateID   string `json:"template_id" validate:"uuid"`
+
+	// Passengers are booked together with the trip, the trip is not
+	// created when one of them can not be booked
+	Passengers []TripPassenger `json:"passengers"`
+}
+
+type TripPassenger struct {
+	CustomerID string `json:"customer_id" validate:"required,uuid"`
+	Seats      int    `json:"seats" validate:"min=0"`
 }
 
 type TripsResponse struct {
 	Trips []Trip `json:"trips"`
 	Count int    `json:"count"`
-}
\ No newline at end of file
+}
+
+type GetTripListRequest struct {
+	Page     int    `json:"page"`
+	Limit    int    `json:"limit"`
+	Status   string `json:"status"`
+	DriverID string `json:"driver_id"`
+	ListQuery
+}
+
+type UpdateTripStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type SearchTripRequest struct {
+	FromCityID    string `json:"from_city_id"`
+	ToCityID      string `json:"to_city_id"`
+	DepartureFrom string `json:"departure_from"`
+	DepartureTo   string `json:"departure_to"`
+	MaxPrice      int    `json:"max_price"`
+	MinFreeSeats  int    `json:"min_free_seats"`
+	Page          int    `json:"page"`
+	Limit         int    `json:"limit"`
+}
@@ -1,19 +1,49 @@
 package models
 
+const (
+	BookingStatusBooked    = "booked"
+	BookingStatusCancelled = "cancelled"
+
+	CurrencyUZS = "UZS"
+)
+
 type TripCustomer struct {
-	ID           string   `json:"id"`
-	TripID       string   `json:"trip_id"`
-	CustomerID   string   `json:"customer_id"`
-	CustomerData Customer `json:"customer_data"`
-	CreatedAt    string   `json:"created_at"`
+	ID            string   `json:"id" validate:"uuid"`
+	TripID        string   `json:"trip_id"`
+	CustomerID    string   `json:"customer_id" validate:"required,uuid"`
+	CustomerData  Customer `json:"customer_data"`
+	Seats         int      `json:"seats"`
+	Fare          int      `json:"fare"`
+	Currency      string   `json:"currency"`
+	Status        string   `json:"status"`
+	CancelReason  *string  `json:"cancel_reason"`
+	CancelledAt   *string  `json:"cancelled_at"`
+	RefundAmount  int      `json:"refund_amount"`
+	PaymentStatus string   `json:"payment_status"`
+	CreatedAt     string   `json:"created_at"`
 }
 
 type CreateTripCustomer struct {
-	TripID     string `json:"trip_id"`
-	CustomerID string `json:"customer_id"`
+	TripID     string `json:"trip_id" validate:"required,uuid"`
+	CustomerID string `json:"customer_id" validate:"required,uuid"`
+	Seats      int    `json:"seats" validate:"min=0"`
+}
+
+type CancelTripCustomer struct {
+	ID     string `json:"id" validate:"uuid"`
+	Reason string `json:"reason"`
 }
 
 type TripCustomersResponse struct {
 	TripCustomers []TripCustomer `json:"trip_customers"`
 	Count         int            `json:"count"`
-}
\ No newline at end of file
+}
+
+// TripTotals sums up the bookings of a trip which are not cancelled
+type TripTotals struct {
+	TripID     string `json:"trip_id"`
+	Bookings   int    `json:"bookings"`
+	Passengers int    `json:"passengers"`
+	TotalFare  int    `json:"total_fare"`
+	Currency   string `json:"currency"`
+}
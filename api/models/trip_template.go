@@ -0,0 +1,50 @@
+package models
+
+// TripTemplate describes a trip a driver runs regularly. Weekdays are ISO
+// numbers (1 is Monday, 7 is Sunday) and DepartureTime is in "15:04" format.
+type TripTemplate struct {
+	ID              string `json:"id" validate:"uuid"`
+	FromCityID      string `json:"from_city_id" validate:"required,uuid"`
+	FromCityData    City   `json:"from_city_data"`
+	ToCityID        string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+	ToCityData      City   `json:"to_city_data"`
+	DriverID        string `json:"driver_id" validate:"required,uuid"`
+	Price           int    `json:"price" validate:"min=0"`
+	Weekdays        []int  `json:"weekdays" validate:"required"`
+	DepartureTime   string `json:"departure_time" validate:"required"`
+	DurationMinutes int    `json:"duration_minutes" validate:"min=1"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type CreateTripTemplate struct {
+	FromCityID      string `json:"from_city_id" validate:"required,uuid"`
+	ToCityID        string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+	DriverID        string `json:"driver_id" validate:"required,uuid"`
+	Price           int    `json:"price" validate:"min=0"`
+	Weekdays        []int  `json:"weekdays" validate:"required"`
+	DepartureTime   string `json:"departure_time" validate:"required"`
+	DurationMinutes int    `json:"duration_minutes" validate:"min=1"`
+}
+
+type TripTemplatesResponse struct {
+	TripTemplates []TripTemplate `json:"trip_templates"`
+	Count         int            `json:"count"`
+}
+
+// GenerateTrips asks to materialize trips of a template for the days between
+// FromDate and ToDate inclusive, both in "2006-01-02" format
+type GenerateTrips struct {
+	TemplateID string `json:"template_id" validate:"uuid"`
+	FromDate   string `json:"from_date" validate:"required"`
+	ToDate     string `json:"to_date" validate:"required"`
+}
+
+type SkippedTrip struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+type GenerateTripsResponse struct {
+	Created []string      `json:"created"`
+	Skipped []SkippedTrip `json:"skipped"`
+}
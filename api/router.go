@@ -2,15 +2,106 @@ package api
 
 import (
 	"city2city/api/handler"
+	"city2city/api/models"
 	"net/http"
 )
 
-func New(h handler.Handler) {
+// New returns the router of the api. Routes are matched by method and path,
+// a known path with another method gets 405 with the Allow header set.
+// Every route needs an access token except logging in and signing up, what
+// each role may do is in the policies of the handler package. The queries
+// of a request are cancelled after the query timeout of the handler.
+func New(h handler.Handler) http.Handler {
+	mux := http.NewServeMux()
 
-	http.HandleFunc("/city", h.City)
-	http.HandleFunc("/customer", h.Customer)
-	http.HandleFunc("/driver", h.Driver)
-	http.HandleFunc("/car", h.Car)
-	http.HandleFunc("/trip", h.Trip)
-	http.HandleFunc("/trip_customer", h.TripCustomer)
-}
\ No newline at end of file
+	public := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, h.Deadline(handler))
+	}
+	private := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, h.Deadline(h.Authenticate(h.Authorize(handler))))
+	}
+
+	public("POST /v1/auth/admin/login", h.AdminLogin)
+	public("POST /v1/auth/otp/request", h.RequestOTP)
+	public("POST /v1/auth/otp/verify", h.VerifyOTP)
+	public("POST /v1/auth/refresh", h.RefreshToken)
+	private("GET /v1/auth/me", h.Me)
+
+	private("GET /v1/cities", h.GetCityList)
+	private("POST /v1/cities", h.CreateCity)
+	private("GET /v1/cities/{id}", h.GetCityByID)
+	private("PUT /v1/cities/{id}", h.UpdateCity)
+	private("DELETE /v1/cities/{id}", h.DeleteCity)
+
+	private("GET /v1/customers", h.GetCustomerList)
+	public("POST /v1/customers", h.CreateCustomer)
+	private("GET /v1/customers/{id}", h.GetCustomerByID)
+	private("PUT /v1/customers/{id}", h.UpdateCustomer)
+	private("DELETE /v1/customers/{id}", h.DeleteCustomer)
+
+	private("GET /v1/drivers", h.GetDriverList)
+	private("POST /v1/drivers", h.CreateDriver)
+	private("GET /v1/drivers/{id}", h.GetDriverByID)
+	private("PUT /v1/drivers/{id}", h.UpdateDriver)
+	private("DELETE /v1/drivers/{id}", h.DeleteDriver)
+	private("GET /v1/drivers/{id}/balance", h.GetDriverBalance)
+	private("GET /v1/drivers/{id}/statement", h.GetDriverStatement)
+	private("POST /v1/drivers/{id}/payouts", h.CreateDriverPayout)
+
+	private("GET /v1/cars", h.GetCarList)
+	private("POST /v1/cars", h.CreateCar)
+	private("GET /v1/cars/{id}", h.GetCarByID)
+	private("PUT /v1/cars/{id}", h.UpdateCar)
+	private("DELETE /v1/cars/{id}", h.DeleteCar)
+	private("PUT /v1/cars/{id}/status", h.UpdateCarStatus)
+
+	private("GET /v1/trips", h.GetTripList)
+	private("POST /v1/trips", h.CreateTrip)
+	private("GET /v1/trips/search", h.SearchTrip)
+	private("GET /v1/trips/{id}", h.GetTripByID)
+	private("PUT /v1/trips/{id}", h.UpdateTrip)
+	private("DELETE /v1/trips/{id}", h.DeleteTrip)
+	private("POST /v1/trips/{id}/board", h.TripTransition(models.TripStatusBoarding))
+	private("POST /v1/trips/{id}/start", h.TripTransition(models.TripStatusInProgress))
+	private("POST /v1/trips/{id}/complete", h.TripTransition(models.TripStatusCompleted))
+	private("POST /v1/trips/{id}/cancel", h.TripTransition(models.TripStatusCancelled))
+	private("GET /v1/trips/{id}/customers", h.GetTripCustomersByTrip)
+	private("GET /v1/trips/{id}/customers/unpaid", h.GetUnpaidTripCustomers)
+	private("GET /v1/trips/{id}/totals", h.GetTripTotals)
+
+	private("GET /v1/trip_templates", h.GetTripTemplateList)
+	private("POST /v1/trip_templates", h.CreateTripTemplate)
+	private("GET /v1/trip_templates/{id}", h.GetTripTemplateByID)
+	private("PUT /v1/trip_templates/{id}", h.UpdateTripTemplate)
+	private("DELETE /v1/trip_templates/{id}", h.DeleteTripTemplate)
+	private("POST /v1/trip_templates/{id}/generate", h.GenerateTrips)
+
+	private("GET /v1/route_tariffs", h.GetRouteTariffList)
+	private("POST /v1/route_tariffs", h.CreateRouteTariff)
+	private("GET /v1/route_tariffs/active", h.GetActiveRouteTariff)
+	private("GET /v1/route_tariffs/{id}", h.GetRouteTariffByID)
+
+	private("GET /v1/price_rules", h.GetPriceRuleList)
+	private("POST /v1/price_rules", h.CreatePriceRule)
+	private("GET /v1/price_rules/{id}", h.GetPriceRuleByID)
+	private("PUT /v1/price_rules/{id}", h.UpdatePriceRule)
+	private("DELETE /v1/price_rules/{id}", h.DeletePriceRule)
+
+	private("GET /v1/trip_customers", h.GetTripCustomerList)
+	private("POST /v1/trip_customers", h.CreateTripCustomer)
+	private("GET /v1/trip_customers/{id}", h.GetTripCustomerByID)
+	private("PUT /v1/trip_customers/{id}", h.UpdateTripCustomer)
+	private("DELETE /v1/trip_customers/{id}", h.DeleteTripCustomer)
+	private("POST /v1/trip_customers/{id}/cancel", h.CancelTripCustomer)
+
+	private("GET /v1/payments", h.GetPaymentList)
+	private("POST /v1/payments", h.CreatePayment)
+	private("GET /v1/payments/{id}", h.GetPaymentByID)
+	private("POST /v1/payments/{id}/capture", h.CapturePayment)
+	private("POST /v1/payments/{id}/refund", h.RefundPayment)
+
+	private("GET /v1/reports", h.Report)
+	private("POST /v1/import", h.Import)
+
+	return mux
+}
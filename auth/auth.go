@@ -0,0 +1,42 @@
+// Package auth authenticates the users of the api. Admins log in with a
+// login and a password, customers and drivers with a one time code sent to
+// their phone. Both get a pair of JSON web tokens: a short lived access
+// token sent with every request and a refresh token to get a new pair.
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("login or password is not correct")
+	ErrInvalidToken       = errors.New("token is not valid")
+	ErrTokenExpired       = errors.New("token is expired")
+	ErrInvalidCode        = errors.New("code is not correct")
+	ErrCodeExpired        = errors.New("code is expired")
+	ErrTooManyAttempts    = errors.New("too many attempts, request a new code")
+	ErrCodeTooSoon        = errors.New("code was sent recently, try again later")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+	ErrUnknownRole        = errors.New("role must be admin or dispatcher")
+)
+
+// Principal is the authenticated user of a request, ID is the id of the
+// admin, customer or driver
+type Principal struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the request, ok is false for
+// requests that are not authenticated
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
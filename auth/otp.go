@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+const (
+	otpDigits      = 6
+	otpMaxAttempts = 5
+)
+
+// newCode returns a random code of otpDigits digits
+func newCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
+
+// hashCode is what is stored instead of the code, the phone and the role
+// are mixed in so a hash is only good for the login it was made for
+func hashCode(phone, role, code string) string {
+	sum := sha256.Sum256([]byte(phone + "|" + role + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkCode(hash, phone, role, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashCode(phone, role, code))) == 1
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"city2city/api/models"
+	"city2city/sms"
+	"city2city/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// otpResendAfter is how long a phone waits before it can get another code
+const otpResendAfter = time.Minute
+
+const minPasswordLength = 8
+
+// Service logs users in and checks their tokens
+type Service struct {
+	storage storage.IStorage
+	tokens  Tokens
+	sender  sms.Sender
+	otpTTL  time.Duration
+}
+
+func NewService(store storage.IStorage, tokens Tokens, sender sms.Sender, otpTTL time.Duration) Service {
+	return Service{
+		storage: store,
+		tokens:  tokens,
+		sender:  sender,
+		otpTTL:  otpTTL,
+	}
+}
+
+// CreateAdmin saves a new admin or dispatcher, they are only created from
+// the command line
+func (s Service) CreateAdmin(ctx context.Context, login, password, role string) (string, error) {
+	if role != models.RoleAdmin && role != models.RoleDispatcher {
+		return "", ErrUnknownRole
+	}
+
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	return s.storage.Auth().CreateAdmin(ctx, login, hash, role)
+}
+
+func (s Service) AdminLogin(ctx context.Context, req models.AdminLogin) (models.Tokens, error) {
+	admin, err := s.storage.Auth().GetAdminByLogin(ctx, req.Login)
+	if err != nil {
+		if storage.IsKind(err, storage.KindNotFound) {
+			return models.Tokens{}, ErrInvalidCredentials
+		}
+		return models.Tokens{}, err
+	}
+
+	if !CheckPassword(admin.PasswordHash, req.Password) {
+		return models.Tokens{}, ErrInvalidCredentials
+	}
+
+	return s.issue(Principal{ID: admin.ID, Role: admin.Role})
+}
+
+// RequestOTP sends a login code to the phone. Phones which are not
+// registered get no code but the same answer, so the endpoint can not be
+// used to find out who uses the service.
+func (s Service) RequestOTP(ctx context.Context, req models.RequestOTP) error {
+	if _, err := s.storage.Auth().UserIDByPhone(ctx, req.Role, req.Phone); err != nil {
+		if storage.IsKind(err, storage.KindNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	last, err := s.storage.Auth().GetOTP(ctx, req.Phone, req.Role)
+	if err != nil && !storage.IsKind(err, storage.KindNotFound) {
+		return err
+	}
+
+	if err == nil && time.Since(last.CreatedAt) < otpResendAfter {
+		return ErrCodeTooSoon
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.Auth().SaveOTP(ctx, models.OTP{
+		Phone:     req.Phone,
+		Role:      req.Role,
+		CodeHash:  hashCode(req.Phone, req.Role, code),
+		ExpiresAt: time.Now().Add(s.otpTTL),
+	}); err != nil {
+		return err
+	}
+
+	return s.sender.Send(req.Phone, fmt.Sprintf("Your city2city code is %s", code))
+}
+
+// VerifyOTP checks the code sent to the phone, a code can be used once
+func (s Service) VerifyOTP(ctx context.Context, req models.VerifyOTP) (models.Tokens, error) {
+	otp, err := s.storage.Auth().GetOTP(ctx, req.Phone, req.Role)
+	if err != nil {
+		if storage.IsKind(err, storage.KindNotFound) {
+			return models.Tokens{}, ErrInvalidCode
+		}
+		return models.Tokens{}, err
+	}
+
+	if time.Now().After(otp.ExpiresAt) {
+		return models.Tokens{}, ErrCodeExpired
+	}
+
+	if otp.Attempts >= otpMaxAttempts {
+		return models.Tokens{}, ErrTooManyAttempts
+	}
+
+	if !checkCode(otp.CodeHash, req.Phone, req.Role, req.Code) {
+		if err := s.storage.Auth().AddOTPAttempt(ctx, req.Phone, req.Role); err != nil {
+			return models.Tokens{}, err
+		}
+		return models.Tokens{}, ErrInvalidCode
+	}
+
+	if err := s.storage.Auth().DeleteOTP(ctx, req.Phone, req.Role); err != nil {
+		return models.Tokens{}, err
+	}
+
+	id, err := s.storage.Auth().UserIDByPhone(ctx, req.Role, req.Phone)
+	if err != nil {
+		if storage.IsKind(err, storage.KindNotFound) {
+			return models.Tokens{}, ErrInvalidCode
+		}
+		return models.Tokens{}, err
+	}
+
+	return s.issue(Principal{ID: id, Role: req.Role})
+}
+
+// Refresh returns a new pair of tokens for a refresh token, as long as its
+// user still exists
+func (s Service) Refresh(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	principal, err := s.tokens.Parse(refreshToken, TokenRefresh)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+
+	exists, err := s.storage.Auth().UserExists(ctx, principal.Role, principal.ID)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+
+	if !exists {
+		return models.Tokens{}, ErrInvalidToken
+	}
+
+	return s.issue(principal)
+}
+
+// Authenticate returns the principal of an access token
+func (s Service) Authenticate(accessToken string) (Principal, error) {
+	return s.tokens.Parse(accessToken, TokenAccess)
+}
+
+func (s Service) issue(principal Principal) (models.Tokens, error) {
+	access, refresh, err := s.tokens.Issue(principal)
+	if err != nil {
+		return models.Tokens{}, err
+	}
+
+	return models.Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.accessTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,206 @@
+package auth_test
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"city2city/config"
+	"city2city/storage"
+	"city2city/storage/memory"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inbox keeps the last code sent to each phone
+type inbox map[string]string
+
+func (i inbox) Send(phone, text string) error {
+	i[phone] = strings.TrimPrefix(text, "Your city2city code is ")
+	return nil
+}
+
+func newService(t *testing.T, otpTTL time.Duration) (auth.Service, storage.IStorage, inbox) {
+	t.Helper()
+
+	store := memory.New(config.Config{})
+	sent := inbox{}
+	tokens := auth.NewTokens("secret", time.Minute, time.Hour)
+
+	return auth.NewService(store, tokens, sent, otpTTL), store, sent
+}
+
+func newCustomer(t *testing.T, store storage.IStorage, phone string) string {
+	t.Helper()
+
+	id, err := store.Customer().Create(t.Context(), models.CreateCustomer{FullName: "Customer", Phone: phone, Email: "c@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("want error %v, got %v", want, err)
+	}
+}
+
+func TestOTP(t *testing.T) {
+	service, store, sent := newService(t, 5*time.Minute)
+	phone := "+998901234567"
+	id := newCustomer(t, store, phone)
+
+	login := models.RequestOTP{Phone: phone, Role: models.RoleCustomer}
+	if err := service.RequestOTP(t.Context(), login); err != nil {
+		t.Fatal(err)
+	}
+
+	code := sent[phone]
+	if len(code) != 6 {
+		t.Fatalf("want a 6 digit code, got %q", code)
+	}
+
+	wantErr(t, service.RequestOTP(t.Context(), login), auth.ErrCodeTooSoon)
+
+	// the phone is registered as a customer, not as a driver
+	_, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleDriver, Code: code})
+	wantErr(t, err, auth.ErrInvalidCode)
+
+	tokens, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := service.Authenticate(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.ID != id || principal.Role != models.RoleCustomer {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	// a code is used once
+	_, err = service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: code})
+	wantErr(t, err, auth.ErrInvalidCode)
+}
+
+func TestOTPAttempts(t *testing.T) {
+	service, store, sent := newService(t, 5*time.Minute)
+	phone := "+998901234568"
+	newCustomer(t, store, phone)
+
+	if err := service.RequestOTP(t.Context(), models.RequestOTP{Phone: phone, Role: models.RoleCustomer}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: "wrong"})
+		wantErr(t, err, auth.ErrInvalidCode)
+	}
+
+	// the right code is refused once the attempts are used up
+	_, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: sent[phone]})
+	wantErr(t, err, auth.ErrTooManyAttempts)
+}
+
+func TestOTPExpired(t *testing.T) {
+	service, store, sent := newService(t, -time.Second)
+	phone := "+998901234569"
+	newCustomer(t, store, phone)
+
+	if err := service.RequestOTP(t.Context(), models.RequestOTP{Phone: phone, Role: models.RoleCustomer}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: sent[phone]})
+	wantErr(t, err, auth.ErrCodeExpired)
+}
+
+func TestOTPUnknownPhone(t *testing.T) {
+	service, _, sent := newService(t, 5*time.Minute)
+
+	if err := service.RequestOTP(t.Context(), models.RequestOTP{Phone: "+998901234560", Role: models.RoleCustomer}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("want no code sent to an unknown phone, got %v", sent)
+	}
+
+	_, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: "+998901234560", Role: models.RoleCustomer, Code: "123456"})
+	wantErr(t, err, auth.ErrInvalidCode)
+}
+
+func TestAdminLogin(t *testing.T) {
+	service, _, _ := newService(t, 5*time.Minute)
+
+	_, err := service.CreateAdmin(t.Context(), "root", "password", models.RoleCustomer)
+	wantErr(t, err, auth.ErrUnknownRole)
+
+	_, err = service.CreateAdmin(t.Context(), "root", "short", models.RoleAdmin)
+	wantErr(t, err, auth.ErrWeakPassword)
+
+	id, err := service.CreateAdmin(t.Context(), "root", "password", models.RoleDispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.AdminLogin(t.Context(), models.AdminLogin{Login: "root", Password: "passw0rd"})
+	wantErr(t, err, auth.ErrInvalidCredentials)
+
+	_, err = service.AdminLogin(t.Context(), models.AdminLogin{Login: "nobody", Password: "password"})
+	wantErr(t, err, auth.ErrInvalidCredentials)
+
+	tokens, err := service.AdminLogin(t.Context(), models.AdminLogin{Login: "root", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.ExpiresIn != 60 {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+
+	principal, err := service.Authenticate(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.ID != id || principal.Role != models.RoleDispatcher {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	// a refresh token is not an access token
+	_, err = service.Authenticate(tokens.RefreshToken)
+	wantErr(t, err, auth.ErrInvalidToken)
+}
+
+func TestRefresh(t *testing.T) {
+	service, store, sent := newService(t, 5*time.Minute)
+	phone := "+998901234561"
+	id := newCustomer(t, store, phone)
+
+	if err := service.RequestOTP(t.Context(), models.RequestOTP{Phone: phone, Role: models.RoleCustomer}); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := service.VerifyOTP(t.Context(), models.VerifyOTP{Phone: phone, Role: models.RoleCustomer, Code: sent[phone]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.Refresh(t.Context(), tokens.AccessToken)
+	wantErr(t, err, auth.ErrInvalidToken)
+
+	refreshed, err := service.Refresh(t.Context(), tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal, err := service.Authenticate(refreshed.AccessToken); err != nil || principal.ID != id {
+		t.Fatalf("unexpected principal %+v, error %v", principal, err)
+	}
+
+	// the tokens of a deleted customer can not be refreshed
+	if err := store.Customer().Delete(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.Refresh(t.Context(), tokens.RefreshToken)
+	wantErr(t, err, auth.ErrInvalidToken)
+}
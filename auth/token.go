@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// jwtHeader is the only header the tokens are signed with and accepted with
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the payload of the tokens
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	TokenType string `json:"token_type"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Tokens signs and checks HS256 JSON web tokens
+type Tokens struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokens(secret string, accessTTL, refreshTTL time.Duration) Tokens {
+	return Tokens{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue returns a new access and refresh token of the principal
+func (t Tokens) Issue(principal Principal) (string, string, error) {
+	now := time.Now()
+
+	access, err := t.sign(Claims{
+		Subject:   principal.ID,
+		Role:      principal.Role,
+		TokenType: TokenAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.accessTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, err := t.sign(Claims{
+		Subject:   principal.ID,
+		Role:      principal.Role,
+		TokenType: TokenRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.refreshTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return access, refresh, nil
+}
+
+// Parse checks the signature, the expiry and the type of the token and
+// returns its principal
+func (t Tokens) Parse(token, tokenType string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Principal{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, t.signature(parts[0]+"."+parts[1])) {
+		return Principal{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+
+	claims := Claims{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+
+	if claims.TokenType != tokenType || claims.Subject == "" {
+		return Principal{}, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return Principal{}, ErrTokenExpired
+	}
+
+	return Principal{ID: claims.Subject, Role: claims.Role}, nil
+}
+
+func (t Tokens) sign(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(t.signature(unsigned)), nil
+}
+
+func (t Tokens) signature(unsigned string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,66 @@
+package auth_test
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := auth.NewTokens("secret", time.Minute, time.Hour)
+	principal := auth.Principal{ID: "c6f1b5c2-6c8f-4d59-9a4b-0e0d5c1f7a11", Role: models.RoleDriver}
+
+	access, refresh, err := tokens.Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flip a character of the payload, the signature no longer matches
+	parts := strings.Split(access, ".")
+	payload := []byte(parts[1])
+	payload[len(payload)/2] ^= 1
+	tampered := parts[0] + "." + string(payload) + "." + parts[2]
+
+	expiredAccess, _, err := auth.NewTokens("secret", -time.Second, time.Hour).Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherAccess, _, err := auth.NewTokens("other", time.Minute, time.Hour).Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+		err       error
+	}{
+		{name: "access", token: access, tokenType: auth.TokenAccess},
+		{name: "refresh", token: refresh, tokenType: auth.TokenRefresh},
+		{name: "refresh as access", token: refresh, tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+		{name: "access as refresh", token: access, tokenType: auth.TokenRefresh, err: auth.ErrInvalidToken},
+		{name: "tampered", token: tampered, tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+		{name: "other secret", token: otherAccess, tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+		{name: "expired", token: expiredAccess, tokenType: auth.TokenAccess, err: auth.ErrTokenExpired},
+		{name: "empty", token: "", tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+		{name: "not a jwt", token: "a.b", tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+		{name: "other header", token: "e30." + parts[1] + "." + parts[2], tokenType: auth.TokenAccess, err: auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokens.Parse(tt.token, tt.tokenType)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			if tt.err == nil && got != principal {
+				t.Fatalf("want principal %+v, got %+v", principal, got)
+			}
+		})
+	}
+}
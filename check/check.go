@@ -1,31 +1,98 @@
 package check
 
 import (
+	"city2city/api/models"
+	"city2city/phone"
+	"city2city/validation"
 	"errors"
-	"fmt"
 	"time"
-	"unicode"
+	"unicode/utf8"
 )
 
 //driver phone check
 
-func PhoneNumber(phone string) bool {
-	for _, p := range phone {
-		if p == '+' {
-			continue
-		} else if !unicode.IsNumber(p) {
-			return false
-		}
+func PhoneNumber(number string) bool {
+	return phone.Valid(number)
+}
+
+//city name check
+
+func CityName(name string) error {
+	if length := utf8.RuneCountInString(name); length <= 3 || length > 30 {
+		return errors.New("city name must be from 4 to 30 characters!")
+	}
+	return nil
+}
+
+//car seats check
+
+func Seats(seats int) error {
+	if seats < 0 {
+		return errors.New("seats can not be negative!")
 	}
-	return true
+	return nil
 }
 
 //car year check
 
 func Year(year int) error {
-	fmt.Println(year)
 	if year <= 0 || year > time.Now().Year() {
 		return errors.New("year is not correct for car!")
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+//trip time check, the errors are field errors of the request
+
+func TripTime(departureAt, arrivalAt string) error {
+	var errs validation.Errors
+
+	departure, err := time.Parse(time.RFC3339, departureAt)
+	if err != nil {
+		errs = append(errs, models.FieldError{Field: "departure_at", Message: "must be in RFC3339 format"})
+	}
+
+	arrival, arrErr := time.Parse(time.RFC3339, arrivalAt)
+	if arrErr != nil {
+		errs = append(errs, models.FieldError{Field: "arrival_at", Message: "must be in RFC3339 format"})
+	}
+
+	if err == nil && arrErr == nil && !arrival.After(departure) {
+		errs = append(errs, models.FieldError{Field: "arrival_at", Message: "must be after departure_at"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//trip template schedule check, the errors are field errors of the request
+
+func Schedule(weekdays []int, departureTime string, durationMinutes int) error {
+	var errs validation.Errors
+
+	if len(weekdays) == 0 {
+		errs = append(errs, models.FieldError{Field: "weekdays", Message: "is required"})
+	}
+
+	for _, day := range weekdays {
+		if day < 1 || day > 7 {
+			errs = append(errs, models.FieldError{Field: "weekdays", Message: "must be between 1 (monday) and 7 (sunday)"})
+			break
+		}
+	}
+
+	if _, err := time.Parse("15:04", departureTime); err != nil {
+		errs = append(errs, models.FieldError{Field: "departure_time", Message: "must be in HH:MM format"})
+	}
+
+	if durationMinutes <= 0 {
+		errs = append(errs, models.FieldError{Field: "duration_minutes", Message: "must be positive"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"city2city/api/models"
+	"city2city/auth"
+	"context"
+	"flag"
+	"fmt"
+)
+
+// runCreateAdmin is the admin subcommand, admins and dispatchers can not
+// sign up through the api so they are created with it:
+//
+//	go run cmd/*.go admin -login root -password secret123 -role dispatcher
+func runCreateAdmin(authService auth.Service, args []string) error {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+
+	login := flags.String("login", "", "login of the admin")
+	password := flags.String("password", "", "password of the admin, at least 8 characters")
+	role := flags.String("role", models.RoleAdmin, "admin or dispatcher")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *login == "" || *password == "" {
+		flags.Usage()
+		return fmt.Errorf("login and password are required")
+	}
+
+	id, err := authService.CreateAdmin(context.Background(), *login, *password, *role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(*role, "is created, id:", id)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"city2city/importer"
+	"city2city/storage"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runImport is the import subcommand, it takes the same csv files as the
+// /import endpoint and prints the import report as json:
+//
+//	go run cmd/*.go import -cities cities.csv -drivers drivers.csv -cars cars.csv -dry-run
+func runImport(store storage.IStorage, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+
+	paths := map[string]*string{}
+	for _, kind := range importer.Kinds {
+		paths[kind] = flags.String(kind, "", fmt.Sprintf("path of the %s csv file", kind))
+	}
+	dryRun := flags.Bool("dry-run", false, "check the files without saving anything")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files := map[string]io.Reader{}
+	for kind, path := range paths {
+		if *path == "" {
+			continue
+		}
+
+		file, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		files[kind] = file
+	}
+
+	if len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no csv file is given")
+	}
+
+	result, err := importer.Run(context.Background(), store, files, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("import failed with %d errors, nothing is saved", len(result.Errors))
+	}
+
+	return nil
+}
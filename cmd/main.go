@@ -3,11 +3,16 @@ package main
 import (
 	"city2city/api"
 	"city2city/api/handler"
+	"city2city/auth"
 	"city2city/config"
+	"city2city/payment"
+	"city2city/sms"
 	"city2city/storage/postgres"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -15,6 +20,13 @@ import (
 func main() {
 	cfg := config.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalln("error while migrating err:", err.Error())
+		}
+		return
+	}
+
 	store, err := postgres.New(cfg)
 	if err != nil {
 		log.Fatalln("error while connecting to db err:", err.Error())
@@ -23,12 +35,51 @@ func main() {
 
 	defer store.CloseDB()
 
-	handler := handler.New(store)
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(store, os.Args[2:]); err != nil {
+			log.Fatalln("error while importing err:", err.Error())
+		}
+		return
+	}
+
+	sender, err := sms.New(cfg.SMSSender, cfg.SMSFile)
+	if err != nil {
+		log.Fatalln("error while creating sms sender err:", err.Error())
+	}
+
+	tokens := auth.NewTokens(
+		cfg.JWTSecret,
+		time.Duration(cfg.AccessTokenTTLMinutes)*time.Minute,
+		time.Duration(cfg.RefreshTokenTTLHours)*time.Hour,
+	)
+	authService := auth.NewService(store, tokens, sender, time.Duration(cfg.OTPTTLMinutes)*time.Minute)
+
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := runCreateAdmin(authService, os.Args[2:]); err != nil {
+			log.Fatalln("error while creating admin err:", err.Error())
+		}
+		return
+	}
+
+	// tokens signed with an empty secret could be made by anyone
+	if cfg.JWTSecret == "" {
+		log.Fatalln("JWT_SECRET is not set")
+	}
+
+	if cfg.MigrateOnStart {
+		if err := migrateUp(cfg); err != nil {
+			log.Fatalln("error while migrating err:", err.Error())
+		}
+	}
+
+	payments := payment.NewProviders(payment.Cash{}, payment.NewFakeCard(cfg.FakeCardLimit))
+
+	handler := handler.New(store, payments, authService, time.Duration(cfg.QueryTimeoutSeconds)*time.Second)
 
-	api.New(handler)
+	router := api.New(handler)
 
 	fmt.Println("Server is running on port 8088")
-	if err = http.ListenAndServe(":8088", nil); err != nil {
+	if err = http.ListenAndServe(":8088", router); err != nil {
 		log.Fatalln("error while running server err:", err.Error())
 	}
 }
\ No newline at end of file
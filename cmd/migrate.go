@@ -0,0 +1,98 @@
+package main
+
+import (
+	"city2city/config"
+	"city2city/migrations"
+	"city2city/storage/postgres"
+	"flag"
+	"fmt"
+)
+
+// runMigrate is the migrate subcommand:
+//
+//	go run cmd/*.go migrate up
+//	go run cmd/*.go migrate down -steps 2
+//	go run cmd/*.go migrate status
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status")
+	}
+
+	db, err := postgres.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := flags.Int("steps", 1, "how many migrations to revert")
+
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		reverted, err := runner.Down(*steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Up == "" {
+				state += " (unknown to this version)"
+			}
+			fmt.Printf("%06d %-30s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+}
+
+// migrateUp applies the pending migrations before the server starts
+func migrateUp(cfg config.Config) error {
+	db, err := postgres.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := runner.Up()
+	for _, migration := range applied {
+		fmt.Printf("applied migration %d_%s\n", migration.Version, migration.Name)
+	}
+
+	return err
+}
@@ -14,6 +14,25 @@ type Config struct {
 	PostgresUser     string
 	PostgresPassword string
 	PostgresDB       string
+
+	MigrateOnStart bool
+
+	QueryTimeoutSeconds int
+
+	RefundFullBeforeHours int
+	RefundPartialPercent  int
+
+	FakeCardLimit int
+
+	PlatformCommissionPercent int
+
+	JWTSecret             string
+	AccessTokenTTLMinutes int
+	RefreshTokenTTLHours  int
+	OTPTTLMinutes         int
+
+	SMSSender string
+	SMSFile   string
 }
 
 func Load() Config {
@@ -29,6 +48,25 @@ func Load() Config {
 	cfg.PostgresPassword = cast.ToString(getOrReturnDefault("POSTGRES_PASSWORD", "password"))
 	cfg.PostgresDB = cast.ToString(getOrReturnDefault("POSTGRES_DB", "db"))
 
+	cfg.MigrateOnStart = cast.ToBool(getOrReturnDefault("MIGRATE_ON_START", true))
+
+	cfg.QueryTimeoutSeconds = cast.ToInt(getOrReturnDefault("QUERY_TIMEOUT_SECONDS", 10))
+
+	cfg.RefundFullBeforeHours = cast.ToInt(getOrReturnDefault("REFUND_FULL_BEFORE_HOURS", 24))
+	cfg.RefundPartialPercent = cast.ToInt(getOrReturnDefault("REFUND_PARTIAL_PERCENT", 50))
+
+	cfg.FakeCardLimit = cast.ToInt(getOrReturnDefault("FAKE_CARD_LIMIT", 0))
+
+	cfg.PlatformCommissionPercent = cast.ToInt(getOrReturnDefault("PLATFORM_COMMISSION_PERCENT", 10))
+
+	cfg.JWTSecret = cast.ToString(getOrReturnDefault("JWT_SECRET", ""))
+	cfg.AccessTokenTTLMinutes = cast.ToInt(getOrReturnDefault("ACCESS_TOKEN_TTL_MINUTES", 15))
+	cfg.RefreshTokenTTLHours = cast.ToInt(getOrReturnDefault("REFRESH_TOKEN_TTL_HOURS", 720))
+	cfg.OTPTTLMinutes = cast.ToInt(getOrReturnDefault("OTP_TTL_MINUTES", 5))
+
+	cfg.SMSSender = cast.ToString(getOrReturnDefault("SMS_SENDER", "console"))
+	cfg.SMSFile = cast.ToString(getOrReturnDefault("SMS_FILE", "sms.log"))
+
 	return cfg
 }
 func getOrReturnDefault(key string, defaultValue interface{}) interface{} {
@@ -37,4 +75,4 @@ func getOrReturnDefault(key string, defaultValue interface{}) interface{} {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
@@ -1,6 +1,6 @@
 module city2city
 
-go 1.18
+go 1.24
 
 require (
 	github.com/google/uuid v1.5.0
@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"city2city/api/models"
+	"city2city/check"
+	"city2city/phone"
+	"city2city/storage"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Kinds are the csv files an import accepts, in the order they are inserted
+var Kinds = []string{models.ImportCities, models.ImportDrivers, models.ImportCars}
+
+var columns = map[string][]string{
+	models.ImportCities:  {"name"},
+	models.ImportDrivers: {"full_name", "phone", "from_city", "to_city"},
+	models.ImportCars:    {"model", "brand", "number", "seats", "driver_phone"},
+}
+
+// Run parses the given csv files by kind and imports them in one transaction.
+// Every row is checked even when some of them fail, so the result lists all
+// the errors at once and nothing is inserted if there is any. A file which
+// can not be read as a whole gives a storage validation error.
+func Run(ctx context.Context, store storage.IStorage, files map[string]io.Reader, dryRun bool) (models.ImportResult, error) {
+	req := models.Import{DryRun: dryRun}
+
+	for kind := range files {
+		if _, ok := columns[kind]; !ok {
+			return models.ImportResult{}, storage.NewError(storage.KindValidation, "unknown_import_file",
+				fmt.Sprintf("unknown import file %q, expected one of %s", kind, strings.Join(Kinds, ", ")))
+		}
+	}
+
+	var errs []models.ImportError
+	for _, kind := range Kinds {
+		r, ok := files[kind]
+		if !ok {
+			continue
+		}
+
+		rowErrs, err := parse(kind, r, &req)
+		if err != nil {
+			return models.ImportResult{}, storage.NewError(storage.KindValidation, "invalid_csv", fmt.Sprintf("%s: %v", kind, err))
+		}
+		errs = append(errs, rowErrs...)
+	}
+
+	// rows that are already known to be bad make the whole import fail,
+	// the rest is still run against the database to report its errors too
+	if len(errs) > 0 {
+		req.DryRun = true
+	}
+
+	result, err := store.Import().Import(ctx, req)
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+
+	result.DryRun = dryRun
+	result.Errors = append(errs, result.Errors...)
+	if len(result.Errors) > 0 {
+		result.Cities, result.Drivers, result.Cars = 0, 0, 0
+	}
+
+	return result, nil
+}
+
+// parse reads the rows of one csv into req. The first line is the header,
+// columns are found by name so their order does not matter.
+func parse(kind string, r io.Reader, req *models.Import) ([]models.ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("could not read csv header")
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range columns[kind] {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("column %s is missing", name)
+		}
+	}
+
+	var errs []models.ImportError
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(name string) string {
+			if i := index[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if err := parseRow(kind, line, get, req); err != nil {
+			errs = append(errs, models.ImportError{File: kind, Line: line, Error: err.Error()})
+		}
+	}
+
+	return errs, nil
+}
+
+func parseRow(kind string, line int, get func(string) string, req *models.Import) error {
+	switch kind {
+	case models.ImportCities:
+		city := models.ImportCity{Line: line, Name: get("name")}
+
+		if err := check.CityName(city.Name); err != nil {
+			return err
+		}
+
+		req.Cities = append(req.Cities, city)
+
+	case models.ImportDrivers:
+		driver := models.ImportDriver{
+			Line:     line,
+			FullName: get("full_name"),
+			Phone:    get("phone"),
+			FromCity: get("from_city"),
+			ToCity:   get("to_city"),
+		}
+
+		if driver.FullName == "" {
+			return errors.New("full_name is required!")
+		}
+		number, err := phone.Normalize(driver.Phone)
+		if err != nil {
+			return fmt.Errorf("phone: %v", err)
+		}
+		driver.Phone = number
+		if err := check.CityName(driver.FromCity); err != nil {
+			return fmt.Errorf("from_city: %v", err)
+		}
+		if err := check.CityName(driver.ToCity); err != nil {
+			return fmt.Errorf("to_city: %v", err)
+		}
+
+		req.Drivers = append(req.Drivers, driver)
+
+	case models.ImportCars:
+		car := models.ImportCar{
+			Line:        line,
+			Model:       get("model"),
+			Brand:       get("brand"),
+			Number:      get("number"),
+			DriverPhone: get("driver_phone"),
+		}
+
+		if car.Model == "" || car.Brand == "" || car.Number == "" {
+			return errors.New("model, brand and number are required!")
+		}
+
+		if seats := get("seats"); seats != "" {
+			n, err := strconv.Atoi(seats)
+			if err != nil {
+				return errors.New("seats must be a number!")
+			}
+			car.Seats = n
+		}
+		if err := check.Seats(car.Seats); err != nil {
+			return err
+		}
+
+		number, err := phone.Normalize(car.DriverPhone)
+		if err != nil {
+			return fmt.Errorf("driver_phone: %v", err)
+		}
+		car.DriverPhone = number
+
+		req.Cars = append(req.Cars, car)
+	}
+
+	return nil
+}
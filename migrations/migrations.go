@@ -0,0 +1,284 @@
+// Package migrations keeps the versioned schema of the database and applies
+// it. Migrations are the files in postgres/ named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, they are embedded
+// in the binary and applied in the order of their versions.
+//
+// Every migration runs in its own transaction together with its row in
+// schema_migrations, so a failed migration leaves nothing behind. The runner
+// holds a postgres advisory lock while it works, so servers started at the
+// same time apply each migration once.
+//
+// 000001 is the schema of the old hand-run db.sql and every later change
+// of the schema is a migration of its own. They only create what is
+// missing and fill the new not null columns of the rows already there, so
+// a database created from db.sql is brought up to date by migrate up like
+// an empty one, as long as its rows pass the checks the migrations add.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed postgres/*.sql
+var files embed.FS
+
+// lockID is the key of the advisory lock taken while migrating
+const lockID = 72_051_402
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and whether it is applied, migrations which are in
+// the database but not in the files have an empty Up
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load returns the embedded migrations ordered by version
+func Load() ([]Migration, error) {
+	names, err := fs.Glob(files, "postgres/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, name := range names {
+		match := fileName.FindStringSubmatch(path.Base(name))
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>.<up|down>.sql", name)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		body, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewRunner(db *sql.DB) (Runner, error) {
+	migrations, err := Load()
+	if err != nil {
+		return Runner{}, err
+	}
+
+	return Runner{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies the migrations which are not applied yet and returns them
+func (r Runner) Up() ([]Migration, error) {
+	applied := []Migration{}
+
+	err := r.locked(func(conn *sql.Conn, done map[int]time.Time) error {
+		for _, migration := range r.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err := r.apply(conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns them
+func (r Runner) Down(steps int) ([]Migration, error) {
+	reverted := []Migration{}
+
+	err := r.locked(func(conn *sql.Conn, done map[int]time.Time) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := r.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			if err := r.apply(conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns every migration of the files and of the database
+func (r Runner) Status() ([]Status, error) {
+	statuses := []Status{}
+
+	err := r.locked(func(conn *sql.Conn, done map[int]time.Time) error {
+		for _, migration := range r.migrations {
+			appliedAt, ok := done[migration.Version]
+			statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+			delete(done, migration.Version)
+		}
+
+		// applied by a newer version of the code
+		for version, appliedAt := range done {
+			statuses = append(statuses, Status{Migration: Migration{Version: version}, Applied: true, AppliedAt: appliedAt})
+		}
+
+		return nil
+	})
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, err
+}
+
+// locked takes the advisory lock on a connection of its own, makes sure
+// schema_migrations exists and calls fn with the applied versions
+func (r Runner) locked(fn func(conn *sql.Conn, done map[int]time.Time) error) error {
+	ctx := context.Background()
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("could not take the migration lock: %w", err)
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			fmt.Println("error while releasing the migration lock", err.Error())
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint primary key,
+			name text not null,
+			applied_at timestamptz not null default now()
+		)
+	`); err != nil {
+		return err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	done := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return err
+		}
+		done[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return fn(conn, done)
+}
+
+// apply runs the sql and record in one transaction
+func (r Runner) apply(conn *sql.Conn, query string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(query); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trips_before_insert_trigger ON trips;
+DROP FUNCTION IF EXISTS generate_trip_number_id();
+DROP SEQUENCE IF EXISTS trip_number_id_sequence;
+
+drop table if exists trip_customers;
+drop table if exists trips;
+drop table if exists cars;
+drop table if exists drivers;
+drop table if exists customers;
+drop table if exists cities;
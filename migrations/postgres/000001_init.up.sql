@@ -1,10 +1,14 @@
-create table cities (
+-- The schema the api started with. Databases created by hand from the old
+-- db.sql already have these tables, so they are only created when missing
+-- and the migrations after this one bring such a database up to date.
+
+create table if not exists cities (
     id uuid primary key,
     name text check (char_length(name) > 3 AND char_length(name) <= 30),
     created_at timestamp default now()
 );
 
-create table customers (
+create table if not exists customers (
     id uuid primary key,
     full_name text,
     phone text unique,
@@ -12,7 +16,7 @@ create table customers (
     created_at timestamp default now()
 );
 
-create table drivers (
+create table if not exists drivers (
     id uuid primary key ,
     full_name text,
     phone text unique,
@@ -21,7 +25,7 @@ create table drivers (
     created_at timestamp default now()
 );
 
-create table cars (
+create table if not exists cars (
     id uuid primary key ,
     model varchar(30),
     brand varchar(30),
@@ -31,7 +35,7 @@ create table cars (
     created_at timestamp default now()
 );
 
-create table trips (
+create table if not exists trips (
     id uuid primary key,
     trip_number_id varchar(5) unique,
     from_city_id uuid references cities(id),
@@ -41,18 +45,17 @@ create table trips (
     created_at timestamp default now()
 );
 
-create table trip_customers (
+create table if not exists trip_customers (
     id uuid primary key,
     trip_id uuid references trips(id),
     customer_id uuid references customers(id),
     created_at timestamp default now()
 );
 
-
-
-
-
-CREATE SEQUENCE trip_num_id_sequence START 1;
+-- db.sql created trip_num_id_sequence while the trigger takes the numbers
+-- from trip_number_id_sequence, so no trip could be inserted
+DROP SEQUENCE IF EXISTS trip_num_id_sequence;
+CREATE SEQUENCE IF NOT EXISTS trip_number_id_sequence START 1;
 
 CREATE OR REPLACE FUNCTION generate_trip_number_id() RETURNS TRIGGER AS $$
 BEGIN
@@ -61,6 +64,7 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+DROP TRIGGER IF EXISTS trips_before_insert_trigger ON trips;
 CREATE TRIGGER trips_before_insert_trigger
 BEFORE INSERT ON trips
-FOR EACH ROW EXECUTE FUNCTION generate_trip_number_id();
\ No newline at end of file
+FOR EACH ROW EXECUTE FUNCTION generate_trip_number_id();
@@ -0,0 +1,2 @@
+alter table trips drop column if exists seats;
+alter table cars drop column if exists seats;
@@ -0,0 +1,2 @@
+alter table cars add column if not exists seats int default 4 check (seats > 0);
+alter table trips add column if not exists seats int default 4 check (seats > 0);
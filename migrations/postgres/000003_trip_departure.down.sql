@@ -0,0 +1,3 @@
+drop index if exists trips_route_departure_idx;
+
+alter table trips drop column if exists departure_at;
@@ -0,0 +1,3 @@
+alter table trips add column if not exists departure_at timestamp not null default now();
+
+create index if not exists trips_route_departure_idx on trips (from_city_id, to_city_id, departure_at);
@@ -0,0 +1,8 @@
+drop index if exists trips_status_idx;
+
+alter table trips
+    drop column if exists cancelled_at,
+    drop column if exists completed_at,
+    drop column if exists started_at,
+    drop column if exists boarding_at,
+    drop column if exists status;
@@ -0,0 +1,9 @@
+alter table trips
+    add column if not exists status varchar(20) not null default 'scheduled'
+        check (status in ('scheduled', 'boarding', 'in_progress', 'completed', 'cancelled')),
+    add column if not exists boarding_at timestamp,
+    add column if not exists started_at timestamp,
+    add column if not exists completed_at timestamp,
+    add column if not exists cancelled_at timestamp;
+
+create index if not exists trips_status_idx on trips (status);
@@ -0,0 +1,4 @@
+drop index if exists trips_driver_departure_idx;
+
+alter table trips drop column if exists arrival_at;
+alter table trips alter column departure_at set default now();
@@ -0,0 +1,12 @@
+alter table trips alter column departure_at drop default;
+
+-- trips made before arrival_at get an arrival an hour after their departure,
+-- the column can not be not null while they have none
+alter table trips add column if not exists arrival_at timestamp;
+update trips set arrival_at = departure_at + interval '1 hour' where arrival_at is null;
+alter table trips alter column arrival_at set not null;
+
+alter table trips drop constraint if exists trips_check;
+alter table trips add constraint trips_check check (arrival_at > departure_at);
+
+create index if not exists trips_driver_departure_idx on trips (driver_id, departure_at);
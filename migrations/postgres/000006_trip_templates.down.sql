@@ -0,0 +1,8 @@
+drop index if exists trips_template_departure_idx;
+
+alter table trips drop column if exists template_id;
+
+-- trip_number_id stays varchar(20), the numbers of generated trips do not
+-- fit in five characters
+
+drop table if exists trip_templates;
@@ -0,0 +1,17 @@
+create table if not exists trip_templates (
+    id uuid primary key,
+    from_city_id uuid references cities(id),
+    to_city_id uuid references cities(id),
+    driver_id uuid references drivers(id),
+    price int default 0 check (price >= 0),
+    weekdays int[] not null,
+    departure_time time not null,
+    duration_minutes int not null check (duration_minutes > 0),
+    created_at timestamp default now()
+);
+
+-- the numbers of generated trips are longer than the five characters of T-NNN
+alter table trips alter column trip_number_id type varchar(20);
+alter table trips add column if not exists template_id uuid references trip_templates(id);
+
+create index if not exists trips_template_departure_idx on trips (template_id, departure_at);
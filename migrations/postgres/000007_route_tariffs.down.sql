@@ -0,0 +1,3 @@
+alter table trips drop column if exists tariff_id;
+
+drop table if exists route_tariffs;
@@ -0,0 +1,15 @@
+create table if not exists route_tariffs (
+    id uuid primary key,
+    from_city_id uuid not null references cities(id),
+    to_city_id uuid not null references cities(id),
+    base_price int not null default 0 check (base_price >= 0),
+    seat_price int not null check (seat_price >= 0),
+    valid_from timestamp not null default now(),
+    valid_to timestamp,
+    created_at timestamp default now(),
+    check (valid_to is null or valid_to > valid_from)
+);
+
+create index if not exists route_tariffs_route_idx on route_tariffs (from_city_id, to_city_id, valid_from);
+
+alter table trips add column if not exists tariff_id uuid references route_tariffs(id);
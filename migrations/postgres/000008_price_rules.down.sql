@@ -0,0 +1,3 @@
+drop table if exists price_rules;
+
+alter table trip_customers drop column if exists fare;
@@ -0,0 +1,11 @@
+alter table trip_customers add column if not exists fare int not null default 0 check (fare >= 0);
+
+create table if not exists price_rules (
+    id uuid primary key,
+    name varchar(50),
+    kind varchar(20) not null check (kind in ('occupancy', 'last_minute')),
+    threshold int not null check (threshold >= 0),
+    percent int not null check (percent > -100),
+    active boolean default true,
+    created_at timestamp default now()
+);
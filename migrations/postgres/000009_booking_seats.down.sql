@@ -0,0 +1,6 @@
+drop index if exists trip_customers_trip_idx;
+
+alter table trip_customers
+    drop column if exists status,
+    drop column if exists currency,
+    drop column if exists seats;
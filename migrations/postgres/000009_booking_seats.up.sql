@@ -0,0 +1,6 @@
+alter table trip_customers
+    add column if not exists seats int not null default 1 check (seats > 0),
+    add column if not exists currency varchar(3) not null default 'UZS',
+    add column if not exists status varchar(20) not null default 'booked' check (status in ('booked', 'cancelled'));
+
+create index if not exists trip_customers_trip_idx on trip_customers (trip_id, status);
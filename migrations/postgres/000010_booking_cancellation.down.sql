@@ -0,0 +1,4 @@
+alter table trip_customers
+    drop column if exists refund_amount,
+    drop column if exists cancelled_at,
+    drop column if exists cancel_reason;
@@ -0,0 +1,4 @@
+alter table trip_customers
+    add column if not exists cancel_reason text,
+    add column if not exists cancelled_at timestamp,
+    add column if not exists refund_amount int not null default 0 check (refund_amount >= 0);
@@ -0,0 +1,3 @@
+drop table if exists payments;
+
+alter table trip_customers drop column if exists payment_status;
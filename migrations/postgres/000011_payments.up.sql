@@ -0,0 +1,17 @@
+alter table trip_customers add column if not exists payment_status varchar(20) not null default 'unpaid'
+    check (payment_status in ('unpaid', 'paid', 'refunded'));
+
+create table if not exists payments (
+    id uuid primary key,
+    trip_customer_id uuid not null references trip_customers(id),
+    provider varchar(20) not null,
+    amount int not null check (amount >= 0),
+    refunded_amount int not null default 0 check (refunded_amount >= 0),
+    currency varchar(3) not null default 'UZS',
+    status varchar(20) not null check (status in ('authorized', 'captured', 'refunded', 'failed')),
+    reference text,
+    created_at timestamp default now(),
+    updated_at timestamp default now()
+);
+
+create index if not exists payments_trip_customer_idx on payments (trip_customer_id);
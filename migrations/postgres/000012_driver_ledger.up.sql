@@ -0,0 +1,13 @@
+create table if not exists driver_ledger (
+    id uuid primary key,
+    driver_id uuid not null references drivers(id),
+    trip_id uuid references trips(id),
+    kind varchar(20) not null check (kind in ('credit', 'payout')),
+    amount int not null check (amount >= 0),
+    commission int not null default 0 check (commission >= 0),
+    description text,
+    created_at timestamp default now()
+);
+
+create index if not exists driver_ledger_driver_idx on driver_ledger (driver_id, created_at);
+create unique index if not exists driver_ledger_trip_credit_idx on driver_ledger (trip_id) where kind = 'credit';
@@ -0,0 +1 @@
+-- the phones were only rewritten to E.164, there is nothing to undo
@@ -0,0 +1,73 @@
+-- Brings the phones of existing customers and drivers to E.164, the format
+-- the api saves since phones are normalized (see the phone package).
+--
+-- Rows whose normalized phone is already used by another row are left as
+-- they are, they have to be merged by hand. They can be found with
+--
+--     SELECT id, phone FROM customers WHERE phone !~ '^\+[0-9]+$';
+--     SELECT id, phone FROM drivers WHERE phone !~ '^\+[0-9]+$';
+
+CREATE OR REPLACE FUNCTION pg_temp.normalize_phone(raw text) RETURNS text AS $$
+DECLARE
+    digits text := regexp_replace(raw, '[\s().-]', '', 'g');
+    plus boolean := left(digits, 1) = '+';
+BEGIN
+    digits := ltrim(digits, '+');
+
+    IF digits !~ '^[0-9]+$' THEN
+        RETURN NULL;
+    END IF;
+
+    IF NOT plus AND left(digits, 2) = '00' THEN
+        digits := substr(digits, 3);
+        plus := true;
+    END IF;
+
+    IF NOT plus THEN
+        IF length(digits) = 9 THEN
+            digits := '998' || digits;
+        ELSIF length(digits) = 10 AND left(digits, 1) = '8' THEN
+            digits := '998' || substr(digits, 2);
+        ELSIF NOT (length(digits) = 12 AND left(digits, 3) = '998') THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    IF left(digits, 3) = '998' THEN
+        IF length(digits) <> 12 OR substr(digits, 4, 2) NOT IN (
+            '20', '33', '50', '55', '77', '88', '90', '91', '93', '94', '95', '97', '98', '99',
+            '61', '62', '65', '66', '67', '69', '70', '71', '72', '73', '74', '75', '76', '78', '79'
+        ) THEN
+            RETURN NULL;
+        END IF;
+    ELSIF NOT (
+        (left(digits, 1) = '7' AND length(digits) = 11) OR
+        (left(digits, 3) IN ('992', '996') AND length(digits) = 12) OR
+        (left(digits, 3) = '993' AND length(digits) = 11)
+    ) THEN
+        RETURN NULL;
+    END IF;
+
+    RETURN '+' || digits;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+UPDATE customers c
+SET phone = pg_temp.normalize_phone(c.phone)
+WHERE pg_temp.normalize_phone(c.phone) IS NOT NULL
+  AND pg_temp.normalize_phone(c.phone) <> c.phone
+  AND NOT EXISTS (
+      SELECT 1 FROM customers o
+      WHERE o.id <> c.id AND pg_temp.normalize_phone(o.phone) = pg_temp.normalize_phone(c.phone)
+  );
+
+UPDATE drivers d
+SET phone = pg_temp.normalize_phone(d.phone)
+WHERE pg_temp.normalize_phone(d.phone) IS NOT NULL
+  AND pg_temp.normalize_phone(d.phone) <> d.phone
+  AND NOT EXISTS (
+      SELECT 1 FROM drivers o
+      WHERE o.id <> d.id AND pg_temp.normalize_phone(o.phone) = pg_temp.normalize_phone(d.phone)
+  );
+
+DROP FUNCTION pg_temp.normalize_phone(text);
@@ -0,0 +1,2 @@
+drop table if exists otp_codes;
+drop table if exists admins;
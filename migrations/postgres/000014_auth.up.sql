@@ -0,0 +1,16 @@
+create table if not exists admins (
+    id uuid primary key,
+    login varchar(50) unique not null,
+    password_hash text not null,
+    created_at timestamp default now()
+);
+
+create table if not exists otp_codes (
+    phone text not null,
+    role varchar(20) not null check (role in ('customer', 'driver')),
+    code_hash text not null,
+    expires_at timestamptz not null,
+    attempts int not null default 0,
+    created_at timestamptz default now(),
+    primary key (phone, role)
+);
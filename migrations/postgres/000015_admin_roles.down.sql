@@ -0,0 +1 @@
+alter table admins drop column if exists role;
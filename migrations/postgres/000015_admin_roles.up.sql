@@ -0,0 +1,2 @@
+alter table admins add column if not exists role varchar(20) not null default 'admin'
+    check (role in ('admin', 'dispatcher'));
@@ -0,0 +1,3 @@
+alter table trips
+    alter column departure_at type timestamp using departure_at at time zone current_setting('TimeZone'),
+    alter column arrival_at type timestamp using arrival_at at time zone current_setting('TimeZone');
@@ -0,0 +1,5 @@
+-- departure_at and arrival_at are moments, the api takes them with an
+-- offset. The stored wall clocks are read in the time zone of the session.
+alter table trips
+    alter column departure_at type timestamptz using departure_at at time zone current_setting('TimeZone'),
+    alter column arrival_at type timestamptz using arrival_at at time zone current_setting('TimeZone');
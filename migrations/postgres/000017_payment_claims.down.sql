@@ -0,0 +1,6 @@
+update payments set status = 'authorized' where status = 'capturing';
+update payments set status = 'captured' where status = 'refunding';
+
+alter table payments drop constraint if exists payments_status_check;
+alter table payments add constraint payments_status_check
+    check (status in ('authorized', 'captured', 'refunded', 'failed'));
@@ -0,0 +1,3 @@
+alter table payments drop constraint if exists payments_status_check;
+alter table payments add constraint payments_status_check
+    check (status in ('authorized', 'capturing', 'captured', 'refunding', 'refunded', 'failed'));
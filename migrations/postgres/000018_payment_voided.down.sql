@@ -0,0 +1,5 @@
+update payments set status = 'failed' where status = 'voided';
+
+alter table payments drop constraint if exists payments_status_check;
+alter table payments add constraint payments_status_check
+    check (status in ('authorized', 'capturing', 'captured', 'refunding', 'refunded', 'failed'));
@@ -0,0 +1 @@
+alter table price_rules drop constraint if exists price_rules_last_minute_percent_check;
@@ -0,0 +1,5 @@
+-- last minute rules make fares cheaper, rules made before are left as they
+-- are and only checked when they change
+alter table price_rules drop constraint if exists price_rules_last_minute_percent_check;
+alter table price_rules add constraint price_rules_last_minute_percent_check
+    check (kind <> 'last_minute' or percent <= 0) not valid;
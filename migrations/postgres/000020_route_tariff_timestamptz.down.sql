@@ -0,0 +1,3 @@
+alter table route_tariffs
+    alter column valid_from type timestamp using valid_from at time zone current_setting('TimeZone'),
+    alter column valid_to type timestamp using valid_to at time zone current_setting('TimeZone');
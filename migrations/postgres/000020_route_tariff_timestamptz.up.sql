@@ -0,0 +1,5 @@
+-- a tariff starts and ends at a moment like the trips it prices. The stored
+-- wall clocks are read in the time zone of the session.
+alter table route_tariffs
+    alter column valid_from type timestamptz using valid_from at time zone current_setting('TimeZone'),
+    alter column valid_to type timestamptz using valid_to at time zone current_setting('TimeZone');
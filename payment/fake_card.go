@@ -0,0 +1,84 @@
+package payment
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const FakeCardProvider = "fake_card"
+
+// FakeCard imitates a card acquirer for local testing. It keeps payments in
+// memory and declines every authorization above Limit, when Limit is set.
+type FakeCard struct {
+	Limit int
+
+	mu       sync.Mutex
+	payments map[string]*fakeCardPayment
+}
+
+type fakeCardPayment struct {
+	authorized int
+	captured   int
+	refunded   int
+}
+
+func NewFakeCard(limit int) *FakeCard {
+	return &FakeCard{
+		Limit:    limit,
+		payments: map[string]*fakeCardPayment{},
+	}
+}
+
+func (f *FakeCard) Name() string {
+	return FakeCardProvider
+}
+
+func (f *FakeCard) Authorize(amount int, currency string) (string, error) {
+	if f.Limit > 0 && amount > f.Limit {
+		return "", ErrDeclined
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reference := "card-" + uuid.New().String()
+	f.payments[reference] = &fakeCardPayment{authorized: amount}
+
+	return reference, nil
+}
+
+func (f *FakeCard) Capture(reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[reference]
+	if !ok {
+		return ErrUnknownPayment
+	}
+
+	if amount > payment.authorized-payment.captured {
+		return errors.New("capture amount is greater than authorized amount")
+	}
+
+	payment.captured += amount
+	return nil
+}
+
+func (f *FakeCard) Refund(reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[reference]
+	if !ok {
+		return ErrUnknownPayment
+	}
+
+	if amount > payment.captured-payment.refunded {
+		return errors.New("refund amount is greater than captured amount")
+	}
+
+	payment.refunded += amount
+	return nil
+}
@@ -0,0 +1,40 @@
+package payment
+
+import "errors"
+
+var (
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	ErrDeclined        = errors.New("payment is declined")
+	ErrUnknownPayment  = errors.New("payment is not known by the provider")
+)
+
+// Provider moves money for a booking. Authorize reserves the amount and
+// returns the provider reference of the payment, Capture takes the reserved
+// money and Refund returns (part of) the captured money to the customer.
+type Provider interface {
+	Name() string
+	Authorize(amount int, currency string) (string, error)
+	Capture(reference string, amount int) error
+	Refund(reference string, amount int) error
+}
+
+// Providers keeps the available providers by name
+type Providers map[string]Provider
+
+func NewProviders(providers ...Provider) Providers {
+	registry := make(Providers, len(providers))
+	for _, provider := range providers {
+		registry[provider.Name()] = provider
+	}
+
+	return registry
+}
+
+func (p Providers) Get(name string) (Provider, error) {
+	provider, ok := p[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,104 @@
+// Package phone parses phone numbers written in the ways people in
+// Uzbekistan write them and turns them into E.164, e.g. "+998901234567".
+//
+// Numbers without a country code are Uzbek: "90 123-45-67", "901234567",
+// "8 90 1234567" and "998901234567" are all +998901234567. Numbers of a few
+// other CIS countries are accepted with their country code.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+const uzbekistan = "998"
+
+var (
+	ErrInvalid            = errors.New("phone number is not correct")
+	ErrUnknownOperator    = errors.New("phone number has an unknown operator code")
+	ErrUnsupportedCountry = errors.New("phone number country is not supported")
+)
+
+// countries maps the supported country codes to the length of their
+// national numbers
+var countries = map[string]int{
+	uzbekistan: 9,
+	"7":        10, // Russia, Kazakhstan
+	"992":      9,  // Tajikistan
+	"993":      8,  // Turkmenistan
+	"996":      9,  // Kyrgyzstan
+}
+
+// operators are the two digit codes Uzbek national numbers start with,
+// mobile operators and the landline codes of the regions
+var operators = map[string]bool{
+	"20": true, "33": true, "50": true, "55": true, "77": true, "88": true,
+	"90": true, "91": true, "93": true, "94": true, "95": true, "97": true, "98": true, "99": true,
+	"61": true, "62": true, "65": true, "66": true, "67": true, "69": true, "70": true,
+	"71": true, "72": true, "73": true, "74": true, "75": true, "76": true, "78": true, "79": true,
+}
+
+// Normalize returns the number in E.164. Spaces, dashes, dots and
+// parentheses are ignored.
+func Normalize(raw string) (string, error) {
+	var (
+		digits strings.Builder
+		plus   bool
+	)
+
+	raw = strings.TrimSpace(raw)
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			plus = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	number := digits.String()
+	if strings.HasPrefix(number, "00") && !plus {
+		number, plus = number[2:], true
+	}
+
+	if !plus {
+		switch {
+		case len(number) == 9:
+			number = uzbekistan + number
+		case len(number) == 10 && number[0] == '8':
+			// the old trunk prefix, 8 90 1234567
+			number = uzbekistan + number[1:]
+		case len(number) == 12 && strings.HasPrefix(number, uzbekistan):
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	for code, length := range countries {
+		if !strings.HasPrefix(number, code) {
+			continue
+		}
+
+		national := number[len(code):]
+		if len(national) != length {
+			return "", ErrInvalid
+		}
+
+		if code == uzbekistan && !operators[national[:2]] {
+			return "", ErrUnknownOperator
+		}
+
+		return "+" + number, nil
+	}
+
+	return "", ErrUnsupportedCountry
+}
+
+// Valid reports whether the number can be normalized
+func Valid(raw string) bool {
+	_, err := Normalize(raw)
+	return err == nil
+}
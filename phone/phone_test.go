@@ -0,0 +1,67 @@
+package phone_test
+
+import (
+	"city2city/phone"
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		// the ways an Uzbek number is written
+		{raw: "+998 90 123-45-67", want: "+998901234567"},
+		{raw: "901234567", want: "+998901234567"},
+		{raw: "998901234567", want: "+998901234567"},
+		{raw: "90 123 45 67", want: "+998901234567"},
+		{raw: "(90) 123.45.67", want: "+998901234567"},
+		{raw: "8 90 1234567", want: "+998901234567"},
+		{raw: "00998901234567", want: "+998901234567"},
+		{raw: "  +998901234567  ", want: "+998901234567"},
+		{raw: "+998 71 234 56 78", want: "+998712345678"},
+
+		{raw: "+998 12 345 67 89", err: phone.ErrUnknownOperator},
+		{raw: "123456789", err: phone.ErrUnknownOperator},
+		{raw: "8 12 3456789", err: phone.ErrUnknownOperator},
+
+		// the other countries, each with its own length
+		{raw: "+7 701 123 45 67", want: "+77011234567"},
+		{raw: "+7 (912) 345-67-89", want: "+79123456789"},
+		{raw: "+992 93 123 4567", want: "+992931234567"},
+		{raw: "+993 65 123456", want: "+99365123456"},
+		{raw: "+996 555 123 456", want: "+996555123456"},
+
+		{raw: "+7 701 123 45 6", err: phone.ErrInvalid},
+		{raw: "+992 93 123 45678", err: phone.ErrInvalid},
+		{raw: "+993 65 1234567", err: phone.ErrInvalid},
+		{raw: "+996 555 123 45", err: phone.ErrInvalid},
+		{raw: "+998 90 123 45 678", err: phone.ErrInvalid},
+
+		{raw: "+1 212 555 0100", err: phone.ErrUnsupportedCountry},
+		{raw: "+380 44 123 4567", err: phone.ErrUnsupportedCountry},
+
+		{raw: "", err: phone.ErrInvalid},
+		{raw: "12345", err: phone.ErrInvalid},
+		{raw: "90 123 45 6x", err: phone.ErrInvalid},
+		{raw: "90+1234567", err: phone.ErrInvalid},
+		{raw: "7011234567", err: phone.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := phone.Normalize(tt.raw)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+			if phone.Valid(tt.raw) != (tt.err == nil) {
+				t.Fatalf("Valid does not agree with Normalize")
+			}
+		})
+	}
+}
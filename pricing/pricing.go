@@ -0,0 +1,80 @@
+package pricing
+
+import (
+	"city2city/api/models"
+	"time"
+)
+
+// Quote returns the fare of a booking whose regular price is price. booked
+// of the seats of the trip are taken before the booking and the trip
+// departs in minutesToDeparture. Of each kind of rule only the most specific
+// one that applies is used: the highest occupancy threshold reached and the
+// closest last minute window. Their percents are added up.
+func Quote(price, seats, booked, minutesToDeparture int, rules []models.PriceRule) int {
+	var (
+		occupancy    = 0
+		percent      = 0
+		byOccupancy  *models.PriceRule
+		byLastMinute *models.PriceRule
+	)
+
+	if seats > 0 {
+		occupancy = booked * 100 / seats
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Active {
+			continue
+		}
+
+		switch rule.Kind {
+		case models.PriceRuleOccupancy:
+			if occupancy >= rule.Threshold && (byOccupancy == nil || rule.Threshold > byOccupancy.Threshold) {
+				byOccupancy = rule
+			}
+		case models.PriceRuleLastMinute:
+			if minutesToDeparture >= 0 && minutesToDeparture <= rule.Threshold && (byLastMinute == nil || rule.Threshold < byLastMinute.Threshold) {
+				byLastMinute = rule
+			}
+		}
+	}
+
+	if byOccupancy != nil {
+		percent += byOccupancy.Percent
+	}
+
+	if byLastMinute != nil {
+		percent += byLastMinute.Percent
+	}
+
+	fare := (price*(100+percent) + 50) / 100
+	if fare < 0 {
+		return 0
+	}
+
+	return fare
+}
+
+func IsRuleKind(kind string) bool {
+	return kind == models.PriceRuleOccupancy || kind == models.PriceRuleLastMinute
+}
+
+// RefundPolicy decides how much of the fare is returned when a booking is
+// cancelled: all of it until FullBefore before departure, PartialPercent of
+// it after that and nothing once the trip has departed
+type RefundPolicy struct {
+	FullBefore     time.Duration
+	PartialPercent int
+}
+
+func (p RefundPolicy) Refund(fare int, untilDeparture time.Duration) int {
+	switch {
+	case untilDeparture > p.FullBefore:
+		return fare
+	case untilDeparture > 0:
+		return fare * p.PartialPercent / 100
+	default:
+		return 0
+	}
+}
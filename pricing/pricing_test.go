@@ -0,0 +1,55 @@
+package pricing_test
+
+import (
+	"city2city/api/models"
+	"city2city/pricing"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	rules := []models.PriceRule{
+		{Kind: models.PriceRuleOccupancy, Threshold: 50, Percent: 10, Active: true},
+		{Kind: models.PriceRuleOccupancy, Threshold: 75, Percent: 20, Active: true},
+		{Kind: models.PriceRuleOccupancy, Threshold: 25, Percent: 50, Active: false},
+		{Kind: models.PriceRuleLastMinute, Threshold: 180, Percent: -5, Active: true},
+		{Kind: models.PriceRuleLastMinute, Threshold: 60, Percent: -15, Active: true},
+	}
+
+	tests := []struct {
+		name               string
+		price              int
+		seats, booked      int
+		minutesToDeparture int
+		rules              []models.PriceRule
+		want               int
+	}{
+		{name: "no rules", price: 100000, seats: 4, booked: 3, minutesToDeparture: 30, want: 100000},
+		{name: "no rule applies", price: 100000, seats: 4, booked: 1, minutesToDeparture: 300, rules: rules, want: 100000},
+		{name: "occupancy threshold reached", price: 100000, seats: 4, booked: 2, minutesToDeparture: 300, rules: rules, want: 110000},
+		{name: "highest occupancy threshold", price: 100000, seats: 4, booked: 3, minutesToDeparture: 300, rules: rules, want: 120000},
+		{name: "closest last minute window", price: 100000, seats: 4, booked: 0, minutesToDeparture: 30, rules: rules, want: 85000},
+		{name: "wider last minute window", price: 100000, seats: 4, booked: 0, minutesToDeparture: 120, rules: rules, want: 95000},
+		{name: "departed trip", price: 100000, seats: 4, booked: 0, minutesToDeparture: -10, rules: rules, want: 100000},
+		{name: "both kinds add up", price: 100000, seats: 4, booked: 3, minutesToDeparture: 30, rules: rules, want: 105000},
+		{name: "no seats", price: 100000, seats: 0, booked: 0, minutesToDeparture: 300, rules: rules, want: 100000},
+		{name: "rounds half up", price: 335, seats: 4, booked: 2, minutesToDeparture: 300, rules: rules, want: 369},
+		{name: "rounds down", price: 333, seats: 4, booked: 2, minutesToDeparture: 300, rules: rules, want: 366},
+		{
+			name: "discounts do not go below zero", price: 100000, seats: 4, booked: 4, minutesToDeparture: 10,
+			rules: []models.PriceRule{
+				{Kind: models.PriceRuleOccupancy, Threshold: 0, Percent: -60, Active: true},
+				{Kind: models.PriceRuleLastMinute, Threshold: 60, Percent: -60, Active: true},
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pricing.Quote(tt.price, tt.seats, tt.booked, tt.minutesToDeparture, tt.rules)
+			if got != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
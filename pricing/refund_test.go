@@ -0,0 +1,37 @@
+package pricing_test
+
+import (
+	"city2city/pricing"
+	"testing"
+	"time"
+)
+
+func TestRefund(t *testing.T) {
+	policy := pricing.RefundPolicy{
+		FullBefore:     24 * time.Hour,
+		PartialPercent: 50,
+	}
+
+	tests := []struct {
+		name           string
+		fare           int
+		untilDeparture time.Duration
+		want           int
+	}{
+		{name: "long before departure", fare: 100000, untilDeparture: 48 * time.Hour, want: 100000},
+		{name: "just before full refund ends", fare: 100000, untilDeparture: 24*time.Hour + time.Second, want: 100000},
+		{name: "full refund ended", fare: 100000, untilDeparture: 24 * time.Hour, want: 50000},
+		{name: "shortly before departure", fare: 100000, untilDeparture: time.Hour, want: 50000},
+		{name: "partial refund rounds down", fare: 333, untilDeparture: time.Hour, want: 166},
+		{name: "at departure", fare: 100000, untilDeparture: 0, want: 0},
+		{name: "after departure", fare: 100000, untilDeparture: -time.Hour, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Refund(tt.fare, tt.untilDeparture); got != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
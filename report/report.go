@@ -0,0 +1,110 @@
+package report
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidGroupBy = storage.NewError(storage.KindValidation, "invalid_group_by", "group_by must be route or driver")
+	ErrInvalidPeriod  = storage.NewError(storage.KindValidation, "invalid_period", "period must be day, week or month")
+	ErrInvalidFrom    = storage.NewError(storage.KindValidation, "invalid_date", "from must be in YYYY-MM-DD format")
+	ErrInvalidTo      = storage.NewError(storage.KindValidation, "invalid_date", "to must be in YYYY-MM-DD format")
+	ErrInvalidRange   = storage.NewError(storage.KindValidation, "invalid_range", "to must not be before from")
+)
+
+var periods = map[string]bool{
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
+// Build validates the request and collects the report rows. From and To are
+// dates in YYYY-MM-DD format, To is included in the report. A request which
+// is not valid gives a storage validation error.
+func Build(ctx context.Context, store storage.IStorage, req models.ReportRequest) (models.Report, error) {
+	if req.GroupBy != models.ReportByRoute && req.GroupBy != models.ReportByDriver {
+		return models.Report{}, ErrInvalidGroupBy
+	}
+
+	if !periods[req.Period] {
+		return models.Report{}, ErrInvalidPeriod
+	}
+
+	from, err := time.Parse("2006-01-02", req.From)
+	if err != nil {
+		return models.Report{}, ErrInvalidFrom
+	}
+
+	to, err := time.Parse("2006-01-02", req.To)
+	if err != nil {
+		return models.Report{}, ErrInvalidTo
+	}
+
+	if to.Before(from) {
+		return models.Report{}, ErrInvalidRange
+	}
+
+	query := req
+	query.To = to.AddDate(0, 0, 1).Format("2006-01-02")
+
+	var rows []models.ReportRow
+	if req.GroupBy == models.ReportByRoute {
+		rows, err = store.Report().Routes(ctx, query)
+	} else {
+		rows, err = store.Report().Drivers(ctx, query)
+	}
+	if err != nil {
+		return models.Report{}, err
+	}
+
+	return models.Report{
+		GroupBy: req.GroupBy,
+		Period:  req.Period,
+		From:    req.From,
+		To:      req.To,
+		Rows:    rows,
+	}, nil
+}
+
+// WriteCSV writes the report as CSV with a header row, the group columns
+// depend on report.GroupBy
+func WriteCSV(w io.Writer, report models.Report) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"period", "from_city_id", "from_city_name", "to_city_id", "to_city_name"}
+	if report.GroupBy == models.ReportByDriver {
+		header = []string{"period", "driver_id", "driver_name"}
+	}
+	header = append(header, "trips", "passengers", "seats", "occupancy", "revenue")
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		record := []string{row.Period, row.FromCityID, row.FromCityName, row.ToCityID, row.ToCityName}
+		if report.GroupBy == models.ReportByDriver {
+			record = []string{row.Period, row.DriverID, row.DriverName}
+		}
+		record = append(record,
+			strconv.Itoa(row.Trips),
+			strconv.Itoa(row.Passengers),
+			strconv.Itoa(row.Seats),
+			strconv.FormatFloat(row.Occupancy, 'f', 2, 64),
+			strconv.Itoa(row.Revenue),
+		)
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
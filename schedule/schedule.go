@@ -0,0 +1,147 @@
+package schedule
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxDays limits how many days can be generated with one request
+const MaxDays = 92
+
+var (
+	ErrInvalidFromDate = storage.NewError(storage.KindValidation, "invalid_date", "from_date must be in YYYY-MM-DD format")
+	ErrInvalidToDate   = storage.NewError(storage.KindValidation, "invalid_date", "to_date must be in YYYY-MM-DD format")
+	ErrInvalidRange    = storage.NewError(storage.KindValidation, "invalid_range", "to_date must not be before from_date")
+	ErrTooManyDays     = storage.NewError(storage.KindValidation, "too_many_days", fmt.Sprintf("at most %d days can be generated at once", MaxDays))
+)
+
+// Generate creates trips of the template for every matching weekday between
+// req.FromDate and req.ToDate. Days which already have a trip of the template
+// and days the driver can not take are skipped and reported in the response.
+// The trips are created in one transaction, if one of them fails none is
+// created.
+func Generate(ctx context.Context, store storage.IStorage, req models.GenerateTrips) (models.GenerateTripsResponse, error) {
+	resp := models.GenerateTripsResponse{
+		Created: []string{},
+		Skipped: []models.SkippedTrip{},
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", req.FromDate, time.Local)
+	if err != nil {
+		return resp, ErrInvalidFromDate
+	}
+
+	to, err := time.ParseInLocation("2006-01-02", req.ToDate, time.Local)
+	if err != nil {
+		return resp, ErrInvalidToDate
+	}
+
+	if to.Before(from) {
+		return resp, ErrInvalidRange
+	}
+
+	if to.Sub(from) >= MaxDays*24*time.Hour {
+		return resp, ErrTooManyDays
+	}
+
+	err = store.WithTx(ctx, func(tx storage.IStorage) error {
+		var err error
+		resp, err = generate(ctx, tx, req, from, to)
+		return err
+	})
+	if err != nil {
+		return models.GenerateTripsResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// generate creates the trips of Generate in the transaction tx
+func generate(ctx context.Context, tx storage.IStorage, req models.GenerateTrips, from, to time.Time) (models.GenerateTripsResponse, error) {
+	resp := models.GenerateTripsResponse{
+		Created: []string{},
+		Skipped: []models.SkippedTrip{},
+	}
+
+	template, err := tx.TripTemplate().Get(ctx, req.TemplateID)
+	if err != nil {
+		return resp, err
+	}
+
+	departure, err := time.Parse("15:04", template.DepartureTime)
+	if err != nil {
+		return resp, err
+	}
+
+	generated, err := tx.TripTemplate().GeneratedDates(ctx, template.ID, req.FromDate, req.ToDate)
+	if err != nil {
+		return resp, err
+	}
+
+	exists := make(map[string]bool, len(generated))
+	for _, date := range generated {
+		exists[date] = true
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if !runsOn(template.Weekdays, day.Weekday()) {
+			continue
+		}
+
+		if exists[date] {
+			resp.Skipped = append(resp.Skipped, models.SkippedTrip{
+				Date:   date,
+				Reason: "already generated",
+			})
+			continue
+		}
+
+		departureAt := time.Date(day.Year(), day.Month(), day.Day(), departure.Hour(), departure.Minute(), 0, 0, time.Local)
+		arrivalAt := departureAt.Add(time.Duration(template.DurationMinutes) * time.Minute)
+
+		id, err := tx.Trip().Create(ctx, models.CreateTrip{
+			FromCityID:  template.FromCityID,
+			ToCityID:    template.ToCityID,
+			DriverID:    template.DriverID,
+			Price:       template.Price,
+			DepartureAt: departureAt.Format(time.RFC3339),
+			ArrivalAt:   arrivalAt.Format(time.RFC3339),
+			TemplateID:  template.ID,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrDriverBusy) || errors.Is(err, storage.ErrDriverNoCar) || errors.Is(err, storage.ErrNoTariff) {
+				resp.Skipped = append(resp.Skipped, models.SkippedTrip{
+					Date:   date,
+					Reason: err.Error(),
+				})
+				continue
+			}
+			return resp, err
+		}
+
+		resp.Created = append(resp.Created, id)
+	}
+
+	return resp, nil
+}
+
+// runsOn reports if the ISO weekdays (1 is Monday, 7 is Sunday) include the day
+func runsOn(weekdays []int, day time.Weekday) bool {
+	iso := int(day)
+	if day == time.Sunday {
+		iso = 7
+	}
+
+	for _, weekday := range weekdays {
+		if weekday == iso {
+			return true
+		}
+	}
+
+	return false
+}
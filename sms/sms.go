@@ -0,0 +1,63 @@
+// Package sms sends text messages to phones. Real gateways implement
+// Sender, Console and File stand in for them in development.
+package sms
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	SenderConsole = "console"
+	SenderFile    = "file"
+)
+
+type Sender interface {
+	Send(phone, text string) error
+}
+
+// New returns the sender of the kind, path is only used by the file sender
+func New(kind, path string) (Sender, error) {
+	switch kind {
+	case SenderConsole, "":
+		return Console{Out: os.Stdout}, nil
+	case SenderFile:
+		return &File{Path: path}, nil
+	}
+
+	return nil, fmt.Errorf("unknown sms sender %q", kind)
+}
+
+// Console prints the messages instead of sending them
+type Console struct {
+	Out io.Writer
+}
+
+func (c Console) Send(phone, text string) error {
+	_, err := fmt.Fprintf(c.Out, "sms to %s: %s\n", phone, text)
+	return err
+}
+
+// File appends the messages to a file, so tests and scripts can read the codes
+type File struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (f *File) Send(phone, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, text)
+	return err
+}
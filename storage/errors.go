@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind tells what went wrong in a way the api can map to a status code
+type ErrorKind string
+
+const (
+	KindNotFound   ErrorKind = "not_found"
+	KindConflict   ErrorKind = "conflict"
+	KindValidation ErrorKind = "validation"
+	KindForeignKey ErrorKind = "foreign_key"
+	KindTimeout    ErrorKind = "timeout"
+)
+
+// Error is a domain error of the storage. Code is a machine readable name
+// of the error, Err is the database error it was made from, if any.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Err     error
+}
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsKind reports whether err is a storage error of the kind
+func IsKind(err error, kind ErrorKind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+var (
+	ErrTripFull    = NewError(KindConflict, "trip_full", "trip is full")
+	ErrDriverNoCar = NewError(KindValidation, "driver_no_car", "driver has no car")
+	ErrDriverBusy  = NewError(KindConflict, "driver_busy", "driver already has a trip at this time")
+
+	ErrTripNotBookable   = NewError(KindConflict, "trip_not_bookable", "trip is not open for booking")
+	ErrUnknownTripStatus = NewError(KindValidation, "unknown_trip_status", "unknown trip status")
+	ErrInvalidTripStatus = NewError(KindConflict, "invalid_trip_status", "trip status transition is not allowed")
+	ErrTripNotEditable   = NewError(KindConflict, "trip_not_editable", "only scheduled trips can be changed")
+	ErrCarTooSmall       = NewError(KindConflict, "car_too_small", "car of the driver has fewer seats than are booked")
+
+	ErrBookingCancelled = NewError(KindConflict, "booking_cancelled", "booking is already cancelled")
+	ErrTripStarted      = NewError(KindConflict, "trip_started", "trip has already started")
+
+	ErrPaymentExists        = NewError(KindConflict, "payment_exists", "booking already has an authorized or captured payment")
+	ErrUnknownPaymentStatus = NewError(KindValidation, "unknown_payment_status", "unknown payment status")
+	ErrInvalidPaymentStatus = NewError(KindConflict, "invalid_payment_status", "payment status transition is not allowed")
+
+	ErrInsufficientBalance = NewError(KindConflict, "insufficient_balance", "driver balance is less than the payout")
+
+	ErrNoTariff      = NewError(KindNotFound, "no_tariff", "route has no tariff, price is required")
+	ErrTariffOverlap = NewError(KindConflict, "tariff_overlap", "route already has a tariff starting at or after valid_from")
+)
+
+// UnknownFilterError is returned for a filter on a field the list does not
+// have or can not be filtered by
+func UnknownFilterError(field string) error {
+	return NewError(KindValidation, "unknown_filter", "can not filter by "+field)
+}
+
+// UnknownSortError is returned for sorting by a field the list does not
+// have or can not be sorted by
+func UnknownSortError(field string) error {
+	return NewError(KindValidation, "unknown_sort", "can not sort by "+field)
+}
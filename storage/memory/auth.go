@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type admin struct {
+	id           string
+	login        string
+	passwordHash string
+	role         string
+	createdAt    time.Time
+}
+
+type otp struct {
+	models.OTP
+}
+
+type authRepo struct {
+	db *db
+}
+
+func otpKey(phone, role string) string {
+	return phone + "|" + role
+}
+
+func (a authRepo) CreateAdmin(ctx context.Context, login, passwordHash, role string) (string, error) {
+	if err := a.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer a.db.mu.Unlock()
+
+	if utf8.RuneCountInString(login) > 50 {
+		return "", errTooLong(50)
+	}
+
+	if role != models.RoleAdmin && role != models.RoleDispatcher {
+		return "", errInvalidValue("role")
+	}
+
+	if a.db.admins.any(func(other admin) bool { return other.login == login }) {
+		return "", errAlreadyExists("login")
+	}
+
+	row := admin{
+		id:           uuid.New().String(),
+		login:        login,
+		passwordHash: passwordHash,
+		role:         role,
+		createdAt:    now(),
+	}
+
+	a.db.admins.set(row.id, row)
+
+	return row.id, nil
+}
+
+func (a authRepo) GetAdminByLogin(ctx context.Context, login string) (models.Admin, error) {
+	if err := a.db.rlock(ctx); err != nil {
+		return models.Admin{}, err
+	}
+	defer a.db.mu.RUnlock()
+
+	for _, row := range a.db.admins.all() {
+		if row.login == login {
+			return models.Admin{
+				ID:           row.id,
+				Login:        row.login,
+				Role:         row.role,
+				PasswordHash: row.passwordHash,
+				CreatedAt:    formatTimestamp(row.createdAt),
+			}, nil
+		}
+	}
+
+	return models.Admin{}, errNotFound()
+}
+
+func (a authRepo) UserIDByPhone(ctx context.Context, role, phone string) (string, error) {
+	if err := a.db.rlock(ctx); err != nil {
+		return "", err
+	}
+	defer a.db.mu.RUnlock()
+
+	switch role {
+	case models.RoleCustomer:
+		for _, row := range a.db.customers.all() {
+			if row.phone == phone {
+				return row.id, nil
+			}
+		}
+	case models.RoleDriver:
+		for _, row := range a.db.drivers.all() {
+			if row.phone == phone {
+				return row.id, nil
+			}
+		}
+	default:
+		return "", storage.NewError(storage.KindValidation, "unknown_role", "role can not log in by phone")
+	}
+
+	return "", errNotFound()
+}
+
+func (a authRepo) UserExists(ctx context.Context, role, id string) (bool, error) {
+	if err := a.db.rlock(ctx); err != nil {
+		return false, err
+	}
+	defer a.db.mu.RUnlock()
+
+	var exists func(id string) bool
+	switch role {
+	case models.RoleCustomer:
+		exists = func(id string) bool { _, ok := a.db.customers.get(id); return ok }
+	case models.RoleDriver:
+		exists = func(id string) bool { _, ok := a.db.drivers.get(id); return ok }
+	case models.RoleAdmin, models.RoleDispatcher:
+		// an admin whose role was changed has to log in again
+		exists = func(id string) bool { row, ok := a.db.admins.get(id); return ok && row.role == role }
+	default:
+		return false, nil
+	}
+
+	id, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+
+	return exists(id), nil
+}
+
+// SaveOTP replaces the code of the phone and role, a new code resets the attempts
+func (a authRepo) SaveOTP(ctx context.Context, code models.OTP) error {
+	if err := a.db.lock(ctx); err != nil {
+		return err
+	}
+	defer a.db.mu.Unlock()
+
+	if code.Role != models.RoleCustomer && code.Role != models.RoleDriver {
+		return errInvalidValue("role")
+	}
+
+	code.Attempts = 0
+	code.CreatedAt = time.Now()
+	a.db.otps.set(otpKey(code.Phone, code.Role), otp{code})
+
+	return nil
+}
+
+func (a authRepo) GetOTP(ctx context.Context, phone, role string) (models.OTP, error) {
+	if err := a.db.rlock(ctx); err != nil {
+		return models.OTP{}, err
+	}
+	defer a.db.mu.RUnlock()
+
+	row, ok := a.db.otps.get(otpKey(phone, role))
+	if !ok {
+		return models.OTP{}, errNotFound()
+	}
+
+	return row.OTP, nil
+}
+
+func (a authRepo) AddOTPAttempt(ctx context.Context, phone, role string) error {
+	if err := a.db.lock(ctx); err != nil {
+		return err
+	}
+	defer a.db.mu.Unlock()
+
+	row, ok := a.db.otps.get(otpKey(phone, role))
+	if !ok {
+		return nil
+	}
+
+	row.Attempts++
+	a.db.otps.set(otpKey(phone, role), row)
+
+	return nil
+}
+
+func (a authRepo) DeleteOTP(ctx context.Context, phone, role string) error {
+	if err := a.db.lock(ctx); err != nil {
+		return err
+	}
+	defer a.db.mu.Unlock()
+
+	a.db.otps.delete(otpKey(phone, role))
+
+	return nil
+}
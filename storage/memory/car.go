@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const defaultCarSeats = 4
+
+type car struct {
+	id        string
+	model     string
+	brand     string
+	number    string
+	status    bool
+	seats     int
+	driverID  string
+	createdAt time.Time
+}
+
+type carRepo struct {
+	db *db
+}
+
+func (c carRepo) Create(ctx context.Context, req models.CreateCar) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	return c.db.insertCar(req)
+}
+
+func (d *db) insertCar(req models.CreateCar) (string, error) {
+	if req.Seats == 0 {
+		req.Seats = defaultCarSeats
+	}
+
+	row := car{
+		id:        uuid.New().String(),
+		model:     req.Model,
+		brand:     req.Brand,
+		number:    req.Number,
+		status:    true,
+		seats:     req.Seats,
+		driverID:  req.DriverID,
+		createdAt: now(),
+	}
+
+	if err := d.checkCar(&row); err != nil {
+		return "", err
+	}
+
+	d.cars.set(row.id, row)
+
+	return row.id, nil
+}
+
+// checkCar checks the columns of the car the way the cars table does
+func (d *db) checkCar(row *car) error {
+	if err := parseIDs(&row.driverID); err != nil {
+		return err
+	}
+
+	for _, value := range []string{row.model, row.brand, row.number} {
+		if utf8.RuneCountInString(value) > 30 {
+			return errTooLong(30)
+		}
+	}
+
+	if row.seats <= 0 {
+		return errInvalidValue("seats")
+	}
+
+	if d.cars.any(func(other car) bool { return other.id != row.id && other.number == row.number }) {
+		return errAlreadyExists("number")
+	}
+
+	if _, ok := d.drivers.get(row.driverID); !ok {
+		return errForeignKey("driver_id")
+	}
+
+	return nil
+}
+
+// carModel is the car with the data of its driver, like the postgres
+// store it has no driver id and cities in the driver data
+func (d *db) carModel(row car) (models.Car, bool) {
+	driver, ok := d.drivers.get(row.driverID)
+	if !ok {
+		return models.Car{}, false
+	}
+
+	return models.Car{
+		ID:       row.id,
+		Model:    row.model,
+		Brand:    row.brand,
+		Number:   row.number,
+		Status:   strconv.FormatBool(row.status),
+		Seats:    row.seats,
+		DriverID: row.driverID,
+		DriverData: models.Driver{
+			FullName:   driver.fullName,
+			Phone:      driver.phone,
+			FromCityID: driver.fromCityID,
+			ToCityID:   driver.toCityID,
+			CreatedAt:  formatTimestamp(driver.createdAt),
+		},
+		CreatedAt: formatTimestamp(row.createdAt),
+	}, true
+}
+
+// carModels joins the cars with their drivers, cars without a driver are
+// left out like by the join of the postgres store
+func (d *db) carModels(rows []car) []models.Car {
+	cars := []models.Car{}
+	for _, row := range rows {
+		if car, ok := d.carModel(row); ok {
+			cars = append(cars, car)
+		}
+	}
+	return cars
+}
+
+func (c carRepo) Get(ctx context.Context, id string) (models.Car, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.Car{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.Car{}, err
+	}
+
+	row, ok := c.db.cars.get(id)
+	if !ok {
+		return models.Car{}, errNotFound()
+	}
+
+	car, ok := c.db.carModel(row)
+	if !ok {
+		return models.Car{}, errNotFound()
+	}
+
+	return car, nil
+}
+
+// carFields are the fields the list of cars can be filtered and sorted by
+var carFields = listFields[car]{
+	"id":         uuidField(func(c car) string { return c.id }),
+	"model":      textField(func(c car) string { return c.model }),
+	"brand":      textField(func(c car) string { return c.brand }),
+	"number":     textField(func(c car) string { return c.number }),
+	"status":     boolField(func(c car) bool { return c.status }),
+	"seats":      intField(func(c car) int { return c.seats }),
+	"driver_id":  uuidField(func(c car) string { return c.driverID }),
+	"created_at": timeField(func(c car) time.Time { return c.createdAt }),
+}
+
+// carSearch returns the texts the search of cars looks in
+func carSearch(c car) []string {
+	return []string{c.model, c.brand, c.number}
+}
+
+func (c carRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CarsResponse, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.CarsResponse{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	rows, err := list(c.db.cars.all(), req.ListQuery, carFields, carSearch, byCreatedAt)
+	if err != nil {
+		return models.CarsResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.CarsResponse{}, err
+	}
+
+	return models.CarsResponse{
+		Cars:  c.db.carModels(rows),
+		Count: count,
+	}, nil
+}
+
+// Stream calls fn for every car matching the filters and the search of q in
+// the order of the list
+func (c carRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Car) error) error {
+	if err := c.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(c.db.cars.all(), q, carFields, carSearch, byCreatedAt)
+	if err != nil {
+		c.db.mu.RUnlock()
+		return err
+	}
+	cars := c.db.carModels(rows)
+	c.db.mu.RUnlock()
+
+	for _, car := range cars {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(car); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c carRepo) Update(ctx context.Context, req models.Car) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := c.db.cars.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	row.model = req.Model
+	row.brand = req.Brand
+	row.number = req.Number
+	row.driverID = req.DriverID
+	if req.Seats != 0 {
+		row.seats = req.Seats
+	}
+
+	if err := c.db.checkCar(&row); err != nil {
+		return "", err
+	}
+
+	c.db.cars.set(id, row)
+
+	return req.ID, nil
+}
+
+func (c carRepo) Delete(ctx context.Context, id string) error {
+	if err := c.db.lock(ctx); err != nil {
+		return err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	c.db.cars.delete(id)
+
+	return nil
+}
+
+func (c carRepo) UpdateCarStatus(ctx context.Context, req models.UpdateCarStatus) error {
+	if err := c.db.lock(ctx); err != nil {
+		return err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	row, ok := c.db.cars.get(id)
+	if !ok {
+		return nil
+	}
+
+	row.status = req.Status
+	c.db.cars.set(id, row)
+
+	return nil
+}
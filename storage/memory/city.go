@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type city struct {
+	id        string
+	name      string
+	createdAt time.Time
+}
+
+func (c city) model() models.City {
+	return models.City{
+		ID:        c.id,
+		Name:      c.name,
+		CreatedAt: formatTimestamp(c.createdAt),
+	}
+}
+
+type cityRepo struct {
+	db *db
+}
+
+func (c cityRepo) Create(ctx context.Context, req models.CreateCity) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	return c.db.insertCity(req.Name)
+}
+
+func (d *db) insertCity(name string) (string, error) {
+	if err := checkCityName(name); err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	d.cities.set(id, city{id: id, name: name, createdAt: now()})
+
+	return id, nil
+}
+
+// checkCityName is the check of cities.name
+func checkCityName(name string) error {
+	if length := utf8.RuneCountInString(name); length <= 3 || length > 30 {
+		return errInvalidValue("name")
+	}
+	return nil
+}
+
+func (c cityRepo) Get(ctx context.Context, id string) (models.City, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.City{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.City{}, err
+	}
+
+	row, ok := c.db.cities.get(id)
+	if !ok {
+		return models.City{}, errNotFound()
+	}
+
+	return row.model(), nil
+}
+
+// cityFields are the fields the list of cities can be filtered and sorted by
+var cityFields = listFields[city]{
+	"id":         uuidField(func(c city) string { return c.id }),
+	"name":       textField(func(c city) string { return c.name }),
+	"created_at": timeField(func(c city) time.Time { return c.createdAt }),
+}
+
+// citySearch returns the texts the search of cities looks in
+func citySearch(c city) []string {
+	return []string{c.name}
+}
+
+func (c cityRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CitiesResponse, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.CitiesResponse{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	rows, err := list(c.db.cities.all(), req.ListQuery, cityFields, citySearch, byCreatedAt)
+	if err != nil {
+		return models.CitiesResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.CitiesResponse{}, err
+	}
+
+	cities := []models.City{}
+	for _, row := range rows {
+		cities = append(cities, row.model())
+	}
+
+	return models.CitiesResponse{
+		Cities: cities,
+		Count:  count,
+	}, nil
+}
+
+// Stream calls fn for every city matching the filters and the search of q
+// in the order of its sort, by name when it has none. fn is called after
+// the lock is released so it may use the store.
+func (c cityRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.City) error) error {
+	if err := c.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(c.db.cities.all(), q, cityFields, citySearch, []models.SortField{{Field: "name"}})
+	c.db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(row.model()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c cityRepo) Update(ctx context.Context, req models.City) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := c.db.cities.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	if err := checkCityName(req.Name); err != nil {
+		return "", err
+	}
+
+	row.name = req.Name
+	c.db.cities.set(id, row)
+
+	return req.ID, nil
+}
+
+func (c cityRepo) Delete(ctx context.Context, id string) error {
+	if err := c.db.lock(ctx); err != nil {
+		return err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	if c.db.drivers.any(func(d driver) bool { return d.fromCityID == id }) ||
+		c.db.routeTariffs.any(func(r routeTariff) bool { return r.fromCityID == id }) ||
+		c.db.tripTemplates.any(func(t tripTemplate) bool { return t.fromCityID == id }) ||
+		c.db.trips.any(func(t trip) bool { return t.fromCityID == id }) {
+		return errForeignKey("from_city_id")
+	}
+
+	if c.db.drivers.any(func(d driver) bool { return d.toCityID == id }) ||
+		c.db.routeTariffs.any(func(r routeTariff) bool { return r.toCityID == id }) ||
+		c.db.tripTemplates.any(func(t tripTemplate) bool { return t.toCityID == id }) ||
+		c.db.trips.any(func(t trip) bool { return t.toCityID == id }) {
+		return errForeignKey("to_city_id")
+	}
+
+	c.db.cities.delete(id)
+
+	return nil
+}
+
+// cityModel is the joined data of a city, empty if there is no such city
+func (d *db) cityModel(id string) models.City {
+	row, ok := d.cities.get(id)
+	if !ok {
+		return models.City{}
+	}
+	return row.model()
+}
@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type customer struct {
+	id        string
+	fullName  string
+	phone     string
+	email     string
+	createdAt time.Time
+}
+
+func (c customer) model() models.Customer {
+	return models.Customer{
+		ID:        c.id,
+		FullName:  c.fullName,
+		Phone:     c.phone,
+		Email:     c.email,
+		CreatedAt: formatTimestamp(c.createdAt),
+	}
+}
+
+type customerRepo struct {
+	db *db
+}
+
+func (c customerRepo) Create(ctx context.Context, req models.CreateCustomer) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	row := customer{
+		id:        uuid.New().String(),
+		fullName:  req.FullName,
+		phone:     req.Phone,
+		email:     req.Email,
+		createdAt: now(),
+	}
+
+	if err := c.db.checkCustomer(row); err != nil {
+		return "", err
+	}
+
+	c.db.customers.set(row.id, row)
+
+	return row.id, nil
+}
+
+// checkCustomer checks the unique phone and email of the customer
+func (d *db) checkCustomer(row customer) error {
+	if d.customers.any(func(c customer) bool { return c.id != row.id && c.phone == row.phone }) {
+		return errAlreadyExists("phone")
+	}
+
+	if d.customers.any(func(c customer) bool { return c.id != row.id && c.email == row.email }) {
+		return errAlreadyExists("email")
+	}
+
+	return nil
+}
+
+func (c customerRepo) Get(ctx context.Context, id string) (models.Customer, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.Customer{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	row, ok := c.db.customers.get(id)
+	if !ok {
+		return models.Customer{}, errNotFound()
+	}
+
+	return row.model(), nil
+}
+
+// customerFields are the fields the list of customers can be filtered and
+// sorted by
+var customerFields = listFields[customer]{
+	"id":         uuidField(func(c customer) string { return c.id }),
+	"full_name":  textField(func(c customer) string { return c.fullName }),
+	"phone":      textField(func(c customer) string { return c.phone }),
+	"email":      textField(func(c customer) string { return c.email }),
+	"created_at": timeField(func(c customer) time.Time { return c.createdAt }),
+}
+
+// customerSearch returns the texts the search of customers looks in
+func customerSearch(c customer) []string {
+	return []string{c.fullName, c.phone, c.email}
+}
+
+func (c customerRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CustomersResponse, error) {
+	if err := c.db.rlock(ctx); err != nil {
+		return models.CustomersResponse{}, err
+	}
+	defer c.db.mu.RUnlock()
+
+	rows, err := list(c.db.customers.all(), req.ListQuery, customerFields, customerSearch, byCreatedAt)
+	if err != nil {
+		return models.CustomersResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.CustomersResponse{}, err
+	}
+
+	customers := []models.Customer{}
+	for _, row := range rows {
+		customers = append(customers, row.model())
+	}
+
+	return models.CustomersResponse{
+		Customers: customers,
+		Count:     count,
+	}, nil
+}
+
+// Stream calls fn for every customer matching the filters and the search of
+// q in the order of the list
+func (c customerRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Customer) error) error {
+	if err := c.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(c.db.customers.all(), q, customerFields, customerSearch, byCreatedAt)
+	c.db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(row.model()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c customerRepo) Update(ctx context.Context, req models.Customer) (string, error) {
+	if err := c.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := c.db.customers.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	row.fullName = req.FullName
+	row.phone = req.Phone
+	row.email = req.Email
+
+	if err := c.db.checkCustomer(row); err != nil {
+		return "", err
+	}
+
+	c.db.customers.set(id, row)
+
+	return req.ID, nil
+}
+
+func (c customerRepo) Delete(ctx context.Context, id string) error {
+	if err := c.db.lock(ctx); err != nil {
+		return err
+	}
+	defer c.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	if c.db.tripCustomers.any(func(tc tripCustomer) bool { return tc.customerID == id }) {
+		return errForeignKey("customer_id")
+	}
+
+	c.db.customers.delete(id)
+
+	return nil
+}
+
+func (d *db) customerModel(id string) models.Customer {
+	row, ok := d.customers.get(id)
+	if !ok {
+		return models.Customer{}
+	}
+	return row.model()
+}
@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type driver struct {
+	id         string
+	fullName   string
+	phone      string
+	fromCityID string
+	toCityID   string
+	createdAt  time.Time
+}
+
+type driverRepo struct {
+	db *db
+}
+
+func (d driverRepo) Create(ctx context.Context, req models.CreateDriver) (string, error) {
+	if err := d.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer d.db.mu.Unlock()
+
+	return d.db.insertDriver(req)
+}
+
+func (d *db) insertDriver(req models.CreateDriver) (string, error) {
+	row := driver{
+		id:         uuid.New().String(),
+		fullName:   req.FullName,
+		phone:      req.Phone,
+		fromCityID: req.FromCityID,
+		toCityID:   req.ToCityID,
+		createdAt:  now(),
+	}
+
+	if err := d.checkDriver(&row); err != nil {
+		return "", err
+	}
+
+	d.drivers.set(row.id, row)
+
+	return row.id, nil
+}
+
+// checkDriver parses the city ids of the driver and checks the unique phone
+// and the cities
+func (d *db) checkDriver(row *driver) error {
+	if err := parseIDs(&row.fromCityID, &row.toCityID); err != nil {
+		return err
+	}
+
+	if d.drivers.any(func(other driver) bool { return other.id != row.id && other.phone == row.phone }) {
+		return errAlreadyExists("phone")
+	}
+
+	if _, ok := d.cities.get(row.fromCityID); !ok {
+		return errForeignKey("from_city_id")
+	}
+
+	if _, ok := d.cities.get(row.toCityID); !ok {
+		return errForeignKey("to_city_id")
+	}
+
+	return nil
+}
+
+// driverModel is the driver with the data of its cities
+func (d *db) driverModel(row driver) models.Driver {
+	return models.Driver{
+		ID:           row.id,
+		FullName:     row.fullName,
+		Phone:        row.phone,
+		FromCityID:   row.fromCityID,
+		FromCityData: d.cityModel(row.fromCityID),
+		ToCityID:     row.toCityID,
+		ToCityData:   d.cityModel(row.toCityID),
+		CreatedAt:    formatTimestamp(row.createdAt),
+	}
+}
+
+func (d driverRepo) Get(ctx context.Context, pkey models.PrimaryKey) (models.Driver, error) {
+	if err := d.db.rlock(ctx); err != nil {
+		return models.Driver{}, err
+	}
+	defer d.db.mu.RUnlock()
+
+	id, err := parseID(pkey.ID)
+	if err != nil {
+		return models.Driver{}, err
+	}
+
+	row, ok := d.db.drivers.get(id)
+	if !ok {
+		return models.Driver{}, errNotFound()
+	}
+
+	return d.db.driverModel(row), nil
+}
+
+// driverFields are the fields the list of drivers can be filtered and sorted
+// by
+var driverFields = listFields[driver]{
+	"id":           uuidField(func(d driver) string { return d.id }),
+	"full_name":    textField(func(d driver) string { return d.fullName }),
+	"phone":        textField(func(d driver) string { return d.phone }),
+	"from_city_id": uuidField(func(d driver) string { return d.fromCityID }),
+	"to_city_id":   uuidField(func(d driver) string { return d.toCityID }),
+	"created_at":   timeField(func(d driver) time.Time { return d.createdAt }),
+}
+
+// driverSearch returns the texts the search of drivers looks in
+func driverSearch(d driver) []string {
+	return []string{d.fullName, d.phone}
+}
+
+func (d driverRepo) GetList(ctx context.Context, req models.GetListRequest) (models.DriversResponse, error) {
+	if err := d.db.rlock(ctx); err != nil {
+		return models.DriversResponse{}, err
+	}
+	defer d.db.mu.RUnlock()
+
+	rows, err := list(d.db.drivers.all(), req.ListQuery, driverFields, driverSearch, byCreatedAt)
+	if err != nil {
+		return models.DriversResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.DriversResponse{}, err
+	}
+
+	drivers := []models.Driver{}
+	for _, row := range rows {
+		drivers = append(drivers, d.db.driverModel(row))
+	}
+
+	return models.DriversResponse{
+		Drivers: drivers,
+		Count:   count,
+	}, nil
+}
+
+// Stream calls fn for every driver matching the filters and the search of q
+// in the order of the list
+func (d driverRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Driver) error) error {
+	if err := d.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(d.db.drivers.all(), q, driverFields, driverSearch, byCreatedAt)
+	if err != nil {
+		d.db.mu.RUnlock()
+		return err
+	}
+
+	drivers := make([]models.Driver, 0, len(rows))
+	for _, row := range rows {
+		drivers = append(drivers, d.db.driverModel(row))
+	}
+	d.db.mu.RUnlock()
+
+	for _, driver := range drivers {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(driver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d driverRepo) Update(ctx context.Context, req models.Driver) (string, error) {
+	if err := d.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer d.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := d.db.drivers.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	row.fullName = req.FullName
+	row.phone = req.Phone
+	row.fromCityID = req.FromCityID
+	row.toCityID = req.ToCityID
+
+	if err := d.db.checkDriver(&row); err != nil {
+		return "", err
+	}
+
+	d.db.drivers.set(id, row)
+
+	return req.ID, nil
+}
+
+func (d driverRepo) Delete(ctx context.Context, pkey models.PrimaryKey) error {
+	if err := d.db.lock(ctx); err != nil {
+		return err
+	}
+	defer d.db.mu.Unlock()
+
+	id, err := parseID(pkey.ID)
+	if err != nil {
+		return err
+	}
+
+	if d.db.cars.any(func(c car) bool { return c.driverID == id }) ||
+		d.db.tripTemplates.any(func(t tripTemplate) bool { return t.driverID == id }) ||
+		d.db.trips.any(func(t trip) bool { return t.driverID == id }) ||
+		d.db.ledger.any(func(e ledgerEntry) bool { return e.driverID == id }) {
+		return errForeignKey("driver_id")
+	}
+
+	d.db.drivers.delete(id)
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ledgerEntry struct {
+	id       string
+	driverID string
+	// tripID is empty for payouts
+	tripID      string
+	kind        string
+	amount      int
+	commission  int
+	description string
+	createdAt   time.Time
+}
+
+func (e ledgerEntry) model() models.LedgerEntry {
+	entry := models.LedgerEntry{
+		ID:          e.id,
+		DriverID:    e.driverID,
+		Kind:        e.kind,
+		Amount:      e.amount,
+		Commission:  e.commission,
+		Description: e.description,
+		CreatedAt:   formatTimestamp(e.createdAt),
+	}
+
+	if e.tripID != "" {
+		tripID := e.tripID
+		entry.TripID = &tripID
+	}
+
+	return entry
+}
+
+type driverLedgerRepo struct {
+	db *db
+}
+
+// balance sums the ledger of the driver for the entries which match
+func (d *db) balance(driverID string, match func(ledgerEntry) bool) (credited, paidOut int) {
+	for _, entry := range d.ledger.all() {
+		if entry.driverID != driverID || !match(entry) {
+			continue
+		}
+
+		if entry.kind == models.LedgerCredit {
+			credited += entry.amount
+		} else {
+			paidOut += entry.amount
+		}
+	}
+
+	return credited, paidOut
+}
+
+func (l driverLedgerRepo) CreatePayout(ctx context.Context, req models.CreatePayout) (string, error) {
+	if err := l.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer l.db.mu.Unlock()
+
+	driverID, err := parseID(req.DriverID)
+	if err != nil {
+		return "", err
+	}
+
+	credited, paidOut := l.db.balance(driverID, func(ledgerEntry) bool { return true })
+	if req.Amount > credited-paidOut {
+		return "", storage.ErrInsufficientBalance
+	}
+
+	if req.Amount < 0 {
+		return "", errInvalidValue("amount")
+	}
+
+	if _, ok := l.db.drivers.get(driverID); !ok {
+		return "", errForeignKey("driver_id")
+	}
+
+	entry := ledgerEntry{
+		id:          uuid.New().String(),
+		driverID:    driverID,
+		kind:        models.LedgerPayout,
+		amount:      req.Amount,
+		description: req.Description,
+		createdAt:   now(),
+	}
+
+	l.db.ledger.set(entry.id, entry)
+
+	return entry.id, nil
+}
+
+func (l driverLedgerRepo) GetBalance(ctx context.Context, driverID string) (models.DriverBalance, error) {
+	if err := l.db.rlock(ctx); err != nil {
+		return models.DriverBalance{}, err
+	}
+	defer l.db.mu.RUnlock()
+
+	balance := models.DriverBalance{
+		DriverID: driverID,
+	}
+
+	driverID, err := parseID(driverID)
+	if err != nil {
+		return models.DriverBalance{}, err
+	}
+
+	balance.Credited, balance.PaidOut = l.db.balance(driverID, func(ledgerEntry) bool { return true })
+	balance.Balance = balance.Credited - balance.PaidOut
+
+	return balance, nil
+}
+
+func (l driverLedgerRepo) GetStatement(ctx context.Context, req models.GetStatementRequest) (models.DriverStatement, error) {
+	if err := l.db.rlock(ctx); err != nil {
+		return models.DriverStatement{}, err
+	}
+	defer l.db.mu.RUnlock()
+
+	statement := models.DriverStatement{
+		DriverID: req.DriverID,
+		From:     req.From,
+		To:       req.To,
+		Entries:  []models.LedgerEntry{},
+	}
+
+	driverID, err := parseID(req.DriverID)
+	if err != nil {
+		return models.DriverStatement{}, err
+	}
+
+	from, err := parseTimestamp(req.From)
+	if err != nil {
+		return models.DriverStatement{}, err
+	}
+
+	to, err := parseTimestamp(req.To)
+	if err != nil {
+		return models.DriverStatement{}, err
+	}
+
+	credited, paidOut := l.db.balance(driverID, func(e ledgerEntry) bool { return e.createdAt.Before(from) })
+	statement.OpeningBalance = credited - paidOut
+
+	entries := []ledgerEntry{}
+	for _, entry := range l.db.ledger.all() {
+		if entry.driverID == driverID && !entry.createdAt.Before(from) && entry.createdAt.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sortByCreatedAt(entries, func(e ledgerEntry) time.Time { return e.createdAt })
+
+	for _, entry := range entries {
+		if entry.kind == models.LedgerCredit {
+			statement.Credited += entry.amount
+		} else {
+			statement.PaidOut += entry.amount
+		}
+		statement.Entries = append(statement.Entries, entry.model())
+	}
+
+	statement.ClosingBalance = statement.OpeningBalance + statement.Credited - statement.PaidOut
+
+	return statement, nil
+}
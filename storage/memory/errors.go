@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the errors postgres returns for the same violations, see
+// storage/postgres/errors.go
+
+var (
+	errNegativeLimit  = errors.New("LIMIT must not be negative")
+	errNegativeOffset = errors.New("OFFSET must not be negative")
+)
+
+func errNotFound() error {
+	return &storage.Error{Kind: storage.KindNotFound, Code: "not_found", Message: "not found", Err: sql.ErrNoRows}
+}
+
+func errAlreadyExists(column string) error {
+	return storage.NewError(storage.KindConflict, "already_exists", column+" already exists")
+}
+
+func errForeignKey(column string) error {
+	return storage.NewError(storage.KindForeignKey, "foreign_key", column+" references a missing or used row")
+}
+
+func errInvalidValue(column string) error {
+	return storage.NewError(storage.KindValidation, "invalid_value", column+" is not valid")
+}
+
+func errTooLong(length int) error {
+	return storage.NewError(storage.KindValidation, "invalid_value", fmt.Sprintf("value too long for type character varying(%d)", length))
+}
+
+func errInvalidText(kind, value string) error {
+	return storage.NewError(storage.KindValidation, "invalid_value", fmt.Sprintf("invalid input syntax for type %s: %q", kind, value))
+}
+
+// ctxErr returns the error of a done context, a passed deadline is a
+// timeout the way storage/postgres reports it
+func ctxErr(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &storage.Error{Kind: storage.KindTimeout, Code: "timeout", Message: "the database took too long to answer", Err: err}
+	}
+	return err
+}
+
+// parseID checks an id the way a uuid column does and returns it in the
+// form postgres returns it in
+func parseID(id string) (string, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return "", errInvalidText("uuid", id)
+	}
+	return uid.String(), nil
+}
+
+// parseIDs parses the ids in place and returns the first error
+func parseIDs(ids ...*string) error {
+	for _, id := range ids {
+		parsed, err := parseID(*id)
+		if err != nil {
+			return err
+		}
+		*id = parsed
+	}
+	return nil
+}
+
+// timestampLayouts are the forms of timestamps the api sends
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a value of a timestamp column. Like postgres it
+// keeps the wall clock and drops the offset of the value.
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return wallClock(t), nil
+		}
+	}
+
+	return time.Time{}, storage.NewError(storage.KindValidation, "invalid_value",
+		fmt.Sprintf("invalid input syntax for type timestamp: %q", value))
+}
+
+// parseTimestamptz parses a value of a timestamptz column. The offset of the
+// value is kept, a value without one is in the local time zone like postgres
+// reads it in the time zone of the session.
+func parseTimestamptz(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t.Truncate(time.Microsecond), nil
+		}
+	}
+
+	return time.Time{}, storage.NewError(storage.KindValidation, "invalid_value",
+		fmt.Sprintf("invalid input syntax for type timestamp with time zone: %q", value))
+}
+
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+}
+
+// now is now() of a timestamp column, the local wall clock
+func now() time.Time {
+	return wallClock(time.Now())
+}
+
+// formatTimestamp formats a timestamp the way a timestamp column is
+// scanned into a string
+func formatTimestamp(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// formatTimestamptz formats a timestamptz the way it is scanned into a
+// string, in the time zone of the session
+func formatTimestamptz(t time.Time) string {
+	return t.In(time.Local).Format(time.RFC3339Nano)
+}
+
+func formatOptional(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	s := formatTimestamp(*t)
+	return &s
+}
+
+func formatOptionalTimestamptz(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	s := formatTimestamptz(*t)
+	return &s
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+type importRepo struct {
+	db *db
+}
+
+// Import inserts all the rows into a copy of the tables, so a failed row
+// does not stop the rest from being checked. The copy replaces the tables
+// only when no row failed and it is not a dry run.
+func (i importRepo) Import(ctx context.Context, req models.Import) (models.ImportResult, error) {
+	if err := i.db.lock(ctx); err != nil {
+		return models.ImportResult{}, err
+	}
+	defer i.db.mu.Unlock()
+
+	var (
+		result = models.ImportResult{DryRun: req.DryRun}
+		tx     = i.db.clone()
+	)
+
+	addError := func(file string, line int, err error) {
+		result.Errors = append(result.Errors, models.ImportError{File: file, Line: line, Error: err.Error()})
+	}
+
+	// city names are matched case insensitive, the ones created by this
+	// import are added so drivers can use them right away
+	cities := map[string]string{}
+	for _, city := range tx.cities.all() {
+		cities[strings.ToLower(city.name)] = city.id
+	}
+
+	for _, city := range req.Cities {
+		if _, ok := cities[strings.ToLower(city.Name)]; ok {
+			continue
+		}
+
+		id, err := tx.insertCity(city.Name)
+		if err != nil {
+			addError(models.ImportCities, city.Line, err)
+			continue
+		}
+
+		cities[strings.ToLower(city.Name)] = id
+		result.Cities++
+	}
+
+	for _, driver := range req.Drivers {
+		fromCityID, ok := cities[strings.ToLower(driver.FromCity)]
+		if !ok {
+			addError(models.ImportDrivers, driver.Line, fmt.Errorf("unknown city %s", driver.FromCity))
+			continue
+		}
+
+		toCityID, ok := cities[strings.ToLower(driver.ToCity)]
+		if !ok {
+			addError(models.ImportDrivers, driver.Line, fmt.Errorf("unknown city %s", driver.ToCity))
+			continue
+		}
+
+		if _, err := tx.insertDriver(models.CreateDriver{
+			FullName:   driver.FullName,
+			Phone:      driver.Phone,
+			FromCityID: fromCityID,
+			ToCityID:   toCityID,
+		}); err != nil {
+			addError(models.ImportDrivers, driver.Line, err)
+			continue
+		}
+
+		result.Drivers++
+	}
+
+	// drivers are found by phone, the ones created by this import included
+	for _, car := range req.Cars {
+		driverID := ""
+		for _, driver := range tx.drivers.all() {
+			if driver.phone == car.DriverPhone {
+				driverID = driver.id
+				break
+			}
+		}
+
+		if driverID == "" {
+			addError(models.ImportCars, car.Line, fmt.Errorf("unknown driver phone %s", car.DriverPhone))
+			continue
+		}
+
+		if _, err := tx.insertCar(models.CreateCar{
+			Model:    car.Model,
+			Brand:    car.Brand,
+			Number:   car.Number,
+			Seats:    car.Seats,
+			DriverID: driverID,
+		}); err != nil {
+			addError(models.ImportCars, car.Line, err)
+			continue
+		}
+
+		result.Cars++
+	}
+
+	if req.DryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	i.db.replace(tx)
+
+	return result, nil
+}
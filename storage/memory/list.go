@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"cmp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listField is a field a list can be filtered and sorted by. value reads it
+// from a row, parse reads a filter value the way postgres reads it for the
+// type of the column.
+type listField[T any] struct {
+	value func(T) any
+	parse func(string) (any, error)
+}
+
+// listFields are the fields of a list by name, the names are the ones of
+// storage/postgres
+type listFields[T any] map[string]listField[T]
+
+func textField[T any](value func(T) string) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) { return s, nil },
+	}
+}
+
+func uuidField[T any](value func(T) string) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) { return parseID(s) },
+	}
+}
+
+func intField[T any](value func(T) int) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, errInvalidText("integer", s)
+			}
+			return n, nil
+		},
+	}
+}
+
+func boolField[T any](value func(T) bool) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "t", "true", "y", "yes", "on", "1":
+				return true, nil
+			case "f", "false", "n", "no", "off", "0":
+				return false, nil
+			}
+			return nil, errInvalidText("boolean", s)
+		},
+	}
+}
+
+func timeField[T any](value func(T) time.Time) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) { return parseTimestamp(s) },
+	}
+}
+
+func timestamptzField[T any](value func(T) time.Time) listField[T] {
+	return listField[T]{
+		value: func(row T) any { return value(row) },
+		parse: func(s string) (any, error) { return parseTimestamptz(s) },
+	}
+}
+
+// compareValues compares two values of the same field
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if a {
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic("memory: unknown type of a list field")
+}
+
+// byCreatedAt is the order of a list which is not sorted
+var byCreatedAt = []models.SortField{{Field: "created_at"}}
+
+// list keeps the rows matching the filters and the search of q and sorts
+// them by the sort fields of q, or by orderBy when q has none, and then by
+// id like storage/postgres. search returns the texts of a row the search
+// looks in.
+func list[T any](rows []T, q models.ListQuery, fields listFields[T], search func(T) []string, orderBy []models.SortField) ([]T, error) {
+	// checked in the order postgres checks them: the names when the query is
+	// built, the values when it runs
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		if _, ok := fields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return nil, storage.UnknownFilterError(names[0])
+	}
+
+	for _, field := range q.Sort {
+		if _, ok := fields[field.Field]; !ok {
+			return nil, storage.UnknownSortError(field.Field)
+		}
+	}
+
+	filters := map[string]any{}
+	for name, value := range q.Filters {
+		parsed, err := fields[name].parse(value)
+		if err != nil {
+			return nil, err
+		}
+		filters[name] = parsed
+	}
+
+	text := strings.ToLower(q.Search)
+
+	matches := []T{}
+	for _, row := range rows {
+		if matchFilters(row, fields, filters) && (text == "" || matchSearch(search(row), text)) {
+			matches = append(matches, row)
+		}
+	}
+
+	order := q.Sort
+	if len(order) == 0 {
+		order = orderBy
+	}
+	order = append(order[:len(order):len(order)], models.SortField{Field: "id"})
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, field := range order {
+			value := fields[field.Field].value
+			c := compareValues(value(matches[i]), value(matches[j]))
+			if field.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	return matches, nil
+}
+
+func matchFilters[T any](row T, fields listFields[T], filters map[string]any) bool {
+	for name, want := range filters {
+		if compareValues(fields[name].value(row), want) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSearch is ILIKE of the search, text is lower case
+func matchSearch(texts []string, text string) bool {
+	for _, t := range texts {
+		if strings.Contains(strings.ToLower(t), text) {
+			return true
+		}
+	}
+	return false
+}
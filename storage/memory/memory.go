@@ -0,0 +1,307 @@
+// Package memory keeps the whole storage in maps, for tests and demos that
+// should run without postgres. It behaves like storage/postgres: the same
+// constraints give the same storage errors, lists are ordered and paginated
+// the same way and the joined data of the rows is filled in.
+//
+// A Store is safe for concurrent use, every call runs under one lock the way
+// a call of the postgres store runs in one transaction. A call with a done
+// context fails before it takes the lock, like a query of a done context.
+package memory
+
+import (
+	"city2city/config"
+	"city2city/pricing"
+	"city2city/storage"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	db                *db
+	refundPolicy      pricing.RefundPolicy
+	commissionPercent int
+}
+
+func New(cfg config.Config) storage.IStorage {
+	return Store{
+		db: newDB(),
+		refundPolicy: pricing.RefundPolicy{
+			FullBefore:     time.Duration(cfg.RefundFullBeforeHours) * time.Hour,
+			PartialPercent: cfg.RefundPartialPercent,
+		},
+		commissionPercent: cfg.PlatformCommissionPercent,
+	}
+}
+
+func (s Store) CloseDB() {}
+
+// WithTx runs fn on a copy of the tables and keeps the copy when fn returns
+// nil. The store is locked until fn returns, so transactions run one after
+// another and calls on the store wait for them.
+func (s Store) WithTx(ctx context.Context, fn func(tx storage.IStorage) error) error {
+	if err := s.db.lock(ctx); err != nil {
+		return err
+	}
+	defer s.db.mu.Unlock()
+
+	tx := s
+	tx.db = s.db.clone()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.db.replace(tx.db)
+	return nil
+}
+
+func (s Store) City() storage.ICityRepo {
+	return cityRepo{db: s.db}
+}
+
+func (s Store) Customer() storage.ICustomerRepo {
+	return customerRepo{db: s.db}
+}
+
+func (s Store) Driver() storage.IDriverRepo {
+	return driverRepo{db: s.db}
+}
+
+func (s Store) Car() storage.ICarRepo {
+	return carRepo{db: s.db}
+}
+
+func (s Store) Trip() storage.ITripRepo {
+	return tripRepo{db: s.db, commissionPercent: s.commissionPercent}
+}
+
+func (s Store) TripTemplate() storage.ITripTemplateRepo {
+	return tripTemplateRepo{db: s.db}
+}
+
+func (s Store) RouteTariff() storage.IRouteTariffRepo {
+	return routeTariffRepo{db: s.db}
+}
+
+func (s Store) PriceRule() storage.IPriceRuleRepo {
+	return priceRuleRepo{db: s.db}
+}
+
+func (s Store) TripCustomer() storage.ITripCustomerRepo {
+	return tripCustomerRepo{db: s.db, refundPolicy: s.refundPolicy}
+}
+
+func (s Store) Payment() storage.IPaymentRepo {
+	return paymentRepo{db: s.db}
+}
+
+func (s Store) DriverLedger() storage.IDriverLedgerRepo {
+	return driverLedgerRepo{db: s.db}
+}
+
+func (s Store) Report() storage.IReportRepo {
+	return reportRepo{db: s.db}
+}
+
+func (s Store) Import() storage.IImportRepo {
+	return importRepo{db: s.db}
+}
+
+func (s Store) Auth() storage.IAuthRepo {
+	return authRepo{db: s.db}
+}
+
+// db holds the tables, every repo of a store shares it
+type db struct {
+	mu sync.RWMutex
+
+	cities        table[city]
+	customers     table[customer]
+	drivers       table[driver]
+	cars          table[car]
+	routeTariffs  table[routeTariff]
+	tripTemplates table[tripTemplate]
+	trips         table[trip]
+	tripCustomers table[tripCustomer]
+	payments      table[payment]
+	ledger        table[ledgerEntry]
+	priceRules    table[priceRule]
+	admins        table[admin]
+	otps          table[otp]
+
+	// tripNumber is the last value of trip_number_id_sequence
+	tripNumber int
+}
+
+func newDB() *db {
+	return &db{
+		cities:        newTable[city](),
+		customers:     newTable[customer](),
+		drivers:       newTable[driver](),
+		cars:          newTable[car](),
+		routeTariffs:  newTable[routeTariff](),
+		tripTemplates: newTable[tripTemplate](),
+		trips:         newTable[trip](),
+		tripCustomers: newTable[tripCustomer](),
+		payments:      newTable[payment](),
+		ledger:        newTable[ledgerEntry](),
+		priceRules:    newTable[priceRule](),
+		admins:        newTable[admin](),
+		otps:          newTable[otp](),
+	}
+}
+
+// clone copies the tables, rows are values so changing the copy leaves
+// the original as it is
+func (d *db) clone() *db {
+	return &db{
+		cities:        d.cities.clone(),
+		customers:     d.customers.clone(),
+		drivers:       d.drivers.clone(),
+		cars:          d.cars.clone(),
+		routeTariffs:  d.routeTariffs.clone(),
+		tripTemplates: d.tripTemplates.clone(),
+		trips:         d.trips.clone(),
+		tripCustomers: d.tripCustomers.clone(),
+		payments:      d.payments.clone(),
+		ledger:        d.ledger.clone(),
+		priceRules:    d.priceRules.clone(),
+		admins:        d.admins.clone(),
+		otps:          d.otps.clone(),
+		tripNumber:    d.tripNumber,
+	}
+}
+
+// replace takes the tables of other, used to commit a clone
+func (d *db) replace(other *db) {
+	d.cities = other.cities
+	d.customers = other.customers
+	d.drivers = other.drivers
+	d.cars = other.cars
+	d.routeTariffs = other.routeTariffs
+	d.tripTemplates = other.tripTemplates
+	d.trips = other.trips
+	d.tripCustomers = other.tripCustomers
+	d.payments = other.payments
+	d.ledger = other.ledger
+	d.priceRules = other.priceRules
+	d.admins = other.admins
+	d.otps = other.otps
+	d.tripNumber = other.tripNumber
+}
+
+// lock takes the write lock of the tables unless ctx is done
+func (d *db) lock(ctx context.Context) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	return nil
+}
+
+// rlock takes the read lock of the tables unless ctx is done
+func (d *db) rlock(ctx context.Context) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	return nil
+}
+
+// table keeps rows by key in the order they were inserted, which is the
+// order postgres returns rows of a query without ORDER BY in
+type table[T any] struct {
+	rows map[string]T
+	keys []string
+}
+
+func newTable[T any]() table[T] {
+	return table[T]{rows: map[string]T{}}
+}
+
+func (t *table[T]) get(key string) (T, bool) {
+	row, ok := t.rows[key]
+	return row, ok
+}
+
+// set inserts or replaces the row
+func (t *table[T]) set(key string, row T) {
+	if _, ok := t.rows[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.rows[key] = row
+}
+
+func (t *table[T]) delete(key string) {
+	if _, ok := t.rows[key]; !ok {
+		return
+	}
+
+	delete(t.rows, key)
+	for i, k := range t.keys {
+		if k == key {
+			t.keys = append(t.keys[:i:i], t.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// all returns the rows in insertion order
+func (t *table[T]) all() []T {
+	rows := make([]T, 0, len(t.keys))
+	for _, key := range t.keys {
+		rows = append(rows, t.rows[key])
+	}
+	return rows
+}
+
+// any reports whether a row matches
+func (t *table[T]) any(match func(T) bool) bool {
+	for _, key := range t.keys {
+		if match(t.rows[key]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table[T]) clone() table[T] {
+	clone := table[T]{
+		rows: make(map[string]T, len(t.rows)),
+		keys: append([]string(nil), t.keys...),
+	}
+	for key, row := range t.rows {
+		clone.rows[key] = row
+	}
+	return clone
+}
+
+// page returns the rows of LIMIT limit OFFSET offset, postgres fails on
+// negative values the same way
+func page[T any](rows []T, limit, offset int) ([]T, error) {
+	if limit < 0 {
+		return nil, errNegativeLimit
+	}
+
+	if offset < 0 {
+		return nil, errNegativeOffset
+	}
+
+	if offset >= len(rows) {
+		return rows[:0], nil
+	}
+
+	return rows[offset:min(offset+limit, len(rows))], nil
+}
+
+// sortByCreatedAt orders the rows by creation time, rows created at the
+// same time stay in insertion order
+func sortByCreatedAt[T any](rows []T, createdAt func(T) time.Time) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return createdAt(rows[i]).Before(createdAt(rows[j]))
+	})
+}
@@ -0,0 +1,21 @@
+package memory_test
+
+import (
+	"city2city/config"
+	"city2city/storage"
+	"city2city/storage/memory"
+	"city2city/storage/storagetest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	cfg := config.Config{
+		RefundFullBeforeHours:     24,
+		RefundPartialPercent:      50,
+		PlatformCommissionPercent: 10,
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.IStorage {
+		return memory.New(cfg)
+	})
+}
@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// bookingPaymentStatus maps a payment status to the payment status its
+// booking gets, statuses which do not change the booking are missing
+var bookingPaymentStatus = map[string]string{
+	models.PaymentStatusCaptured: models.BookingPaid,
+	models.PaymentStatusRefunded: models.BookingRefunded,
+}
+
+type payment struct {
+	id             string
+	tripCustomerID string
+	provider       string
+	amount         int
+	refundedAmount int
+	currency       string
+	status         string
+	reference      string
+	createdAt      time.Time
+	updatedAt      time.Time
+}
+
+func (p payment) model() models.Payment {
+	return models.Payment{
+		ID:             p.id,
+		TripCustomerID: p.tripCustomerID,
+		Provider:       p.provider,
+		Amount:         p.amount,
+		RefundedAmount: p.refundedAmount,
+		Currency:       p.currency,
+		Status:         p.status,
+		Reference:      p.reference,
+		CreatedAt:      formatTimestamp(p.createdAt),
+		UpdatedAt:      formatTimestamp(p.updatedAt),
+	}
+}
+
+type paymentRepo struct {
+	db *db
+}
+
+func (p paymentRepo) Create(ctx context.Context, req models.CreatePayment) (string, error) {
+	if err := p.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer p.db.mu.Unlock()
+
+	tripCustomerID, err := parseID(req.TripCustomerID)
+	if err != nil {
+		return "", err
+	}
+
+	row := payment{
+		id:             uuid.New().String(),
+		tripCustomerID: tripCustomerID,
+		provider:       req.Provider,
+		amount:         req.Amount,
+		currency:       req.Currency,
+		status:         req.Status,
+		reference:      req.Reference,
+		createdAt:      now(),
+	}
+	row.updatedAt = row.createdAt
+
+	if err := checkPayment(row); err != nil {
+		return "", err
+	}
+
+	booking, ok := p.db.tripCustomers.get(row.tripCustomerID)
+	if !ok {
+		return "", errForeignKey("trip_customer_id")
+	}
+
+	if booking.status == models.BookingStatusCancelled {
+		return "", storage.ErrBookingCancelled
+	}
+
+	for _, other := range p.db.payments.all() {
+		if other.tripCustomerID == row.tripCustomerID && storage.IsActivePayment(other.status) {
+			return "", storage.ErrPaymentExists
+		}
+	}
+
+	p.db.payments.set(row.id, row)
+	p.db.setBookingPaymentStatus(row.tripCustomerID, row.status)
+
+	return row.id, nil
+}
+
+// checkPayment checks the columns of the payment the way the payments
+// table does
+func checkPayment(row payment) error {
+	if utf8.RuneCountInString(row.provider) > 20 {
+		return errTooLong(20)
+	}
+
+	if utf8.RuneCountInString(row.currency) > 3 {
+		return errTooLong(3)
+	}
+
+	if utf8.RuneCountInString(row.status) > 20 {
+		return errTooLong(20)
+	}
+
+	if row.amount < 0 {
+		return errInvalidValue("amount")
+	}
+
+	if row.refundedAmount < 0 {
+		return errInvalidValue("refunded_amount")
+	}
+
+	if !storage.IsPaymentStatus(row.status) {
+		return errInvalidValue("status")
+	}
+
+	return nil
+}
+
+func (d *db) setBookingPaymentStatus(tripCustomerID, paymentStatus string) {
+	status, ok := bookingPaymentStatus[paymentStatus]
+	if !ok {
+		return
+	}
+
+	row, ok := d.tripCustomers.get(tripCustomerID)
+	if !ok {
+		return
+	}
+
+	// a booking which is still active is unpaid again after a refund, so it
+	// can be paid once more
+	if paymentStatus == models.PaymentStatusRefunded && row.status == models.BookingStatusBooked {
+		status = models.BookingUnpaid
+	}
+
+	row.paymentStatus = status
+	d.tripCustomers.set(row.id, row)
+}
+
+func (p paymentRepo) Get(ctx context.Context, id string) (models.Payment, error) {
+	if err := p.db.rlock(ctx); err != nil {
+		return models.Payment{}, err
+	}
+	defer p.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	row, ok := p.db.payments.get(id)
+	if !ok {
+		return models.Payment{}, errNotFound()
+	}
+
+	return row.model(), nil
+}
+
+func (p paymentRepo) GetList(ctx context.Context, req models.GetPaymentListRequest) (models.PaymentsResponse, error) {
+	if err := p.db.rlock(ctx); err != nil {
+		return models.PaymentsResponse{}, err
+	}
+	defer p.db.mu.RUnlock()
+
+	rows := []payment{}
+	for _, row := range p.db.payments.all() {
+		if (req.TripCustomerID == "" || row.tripCustomerID == req.TripCustomerID) && (req.Status == "" || row.status == req.Status) {
+			rows = append(rows, row)
+		}
+	}
+	count := len(rows)
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].createdAt.After(rows[j].createdAt)
+	})
+
+	rows, err := page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.PaymentsResponse{}, err
+	}
+
+	payments := []models.Payment{}
+	for _, row := range rows {
+		payments = append(payments, row.model())
+	}
+
+	return models.PaymentsResponse{
+		Payments: payments,
+		Count:    count,
+	}, nil
+}
+
+// UpdateStatus changes the payment status and the payment status of its
+// booking together, the payment must still have the status it moves from
+func (p paymentRepo) UpdateStatus(ctx context.Context, req models.UpdatePaymentStatus) error {
+	if err := storage.CheckPaymentTransition(req.From, req.Status); err != nil {
+		return err
+	}
+
+	if err := p.db.lock(ctx); err != nil {
+		return err
+	}
+	defer p.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	row, ok := p.db.payments.get(id)
+	if !ok {
+		return errNotFound()
+	}
+
+	if row.status != req.From {
+		return storage.ErrInvalidPaymentStatus
+	}
+
+	row.status = req.Status
+	row.refundedAmount = req.RefundedAmount
+	row.updatedAt = now()
+
+	if err := checkPayment(row); err != nil {
+		return err
+	}
+
+	p.db.payments.set(id, row)
+	p.db.setBookingPaymentStatus(row.tripCustomerID, row.status)
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/pricing"
+	"context"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+type priceRule struct {
+	id        string
+	name      string
+	kind      string
+	threshold int
+	percent   int
+	active    bool
+	createdAt time.Time
+}
+
+func (p priceRule) model() models.PriceRule {
+	return models.PriceRule{
+		ID:        p.id,
+		Name:      p.name,
+		Kind:      p.kind,
+		Threshold: p.threshold,
+		Percent:   p.percent,
+		Active:    p.active,
+		CreatedAt: formatTimestamp(p.createdAt),
+	}
+}
+
+// check checks the columns of the rule the way the price_rules table does
+func (p priceRule) check() error {
+	if utf8.RuneCountInString(p.name) > 50 {
+		return errTooLong(50)
+	}
+
+	if utf8.RuneCountInString(p.kind) > 20 {
+		return errTooLong(20)
+	}
+
+	if !pricing.IsRuleKind(p.kind) {
+		return errInvalidValue("kind")
+	}
+
+	if p.threshold < 0 {
+		return errInvalidValue("threshold")
+	}
+
+	if p.percent <= -100 {
+		return errInvalidValue("percent")
+	}
+
+	if p.kind == models.PriceRuleLastMinute && p.percent > 0 {
+		return errInvalidValue("last_minute_percent")
+	}
+
+	return nil
+}
+
+type priceRuleRepo struct {
+	db *db
+}
+
+func (p priceRuleRepo) Create(ctx context.Context, req models.CreatePriceRule) (string, error) {
+	if err := p.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer p.db.mu.Unlock()
+
+	row := priceRule{
+		id:        uuid.New().String(),
+		name:      req.Name,
+		kind:      req.Kind,
+		threshold: req.Threshold,
+		percent:   req.Percent,
+		active:    req.Active,
+		createdAt: now(),
+	}
+
+	if err := row.check(); err != nil {
+		return "", err
+	}
+
+	p.db.priceRules.set(row.id, row)
+
+	return row.id, nil
+}
+
+func (p priceRuleRepo) Get(ctx context.Context, id string) (models.PriceRule, error) {
+	if err := p.db.rlock(ctx); err != nil {
+		return models.PriceRule{}, err
+	}
+	defer p.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.PriceRule{}, err
+	}
+
+	row, ok := p.db.priceRules.get(id)
+	if !ok {
+		return models.PriceRule{}, errNotFound()
+	}
+
+	return row.model(), nil
+}
+
+func (p priceRuleRepo) GetList(ctx context.Context, req models.GetListRequest) (models.PriceRulesResponse, error) {
+	if err := p.db.rlock(ctx); err != nil {
+		return models.PriceRulesResponse{}, err
+	}
+	defer p.db.mu.RUnlock()
+
+	rows := p.db.priceRules.all()
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].createdAt.After(rows[j].createdAt)
+	})
+
+	rows, err := page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.PriceRulesResponse{}, err
+	}
+
+	rules := []models.PriceRule{}
+	for _, row := range rows {
+		rules = append(rules, row.model())
+	}
+
+	return models.PriceRulesResponse{
+		PriceRules: rules,
+		Count:      len(p.db.priceRules.keys),
+	}, nil
+}
+
+func (p priceRuleRepo) Update(ctx context.Context, req models.PriceRule) (string, error) {
+	if err := p.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer p.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := p.db.priceRules.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	row.name = req.Name
+	row.kind = req.Kind
+	row.threshold = req.Threshold
+	row.percent = req.Percent
+	row.active = req.Active
+
+	if err := row.check(); err != nil {
+		return "", err
+	}
+
+	p.db.priceRules.set(id, row)
+
+	return req.ID, nil
+}
+
+func (p priceRuleRepo) Delete(ctx context.Context, id string) error {
+	if err := p.db.lock(ctx); err != nil {
+		return err
+	}
+	defer p.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	p.db.priceRules.delete(id)
+
+	return nil
+}
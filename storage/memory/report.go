@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type reportRepo struct {
+	db *db
+}
+
+// tripStats is a not cancelled trip with its period, booked seats and revenue
+type tripStats struct {
+	trip
+	period     time.Time
+	passengers int
+	revenue    int
+}
+
+// truncate is date_trunc of the report periods in the local time zone,
+// weeks start on Monday
+func truncate(unit string, t time.Time) (time.Time, error) {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+
+	switch unit {
+	case "day":
+		return day, nil
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unit %q not recognized for type timestamp with time zone", unit)
+}
+
+// tripStats returns the not cancelled trips departing in [From, To)
+func (d *db) tripStats(req models.ReportRequest) ([]tripStats, error) {
+	from, err := parseTimestamptz(req.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseTimestamptz(req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []tripStats{}
+	for _, row := range d.trips.all() {
+		if row.status == models.TripStatusCancelled || row.departureAt.Before(from) || !row.departureAt.Before(to) {
+			continue
+		}
+
+		period, err := truncate(req.Period, row.departureAt)
+		if err != nil {
+			return nil, err
+		}
+
+		ts := tripStats{trip: row, period: period}
+		for _, booking := range d.tripCustomers.all() {
+			if booking.tripID == row.id && booking.status == models.BookingStatusBooked {
+				ts.passengers += booking.seats
+				ts.revenue += booking.fare
+			}
+		}
+
+		stats = append(stats, ts)
+	}
+
+	return stats, nil
+}
+
+// group adds up the trips with the same key into report rows in the order
+// the keys first appear, row fills in the columns of the key
+func group(stats []tripStats, key func(tripStats) string, row func(tripStats) models.ReportRow) []models.ReportRow {
+	var (
+		report    = []models.ReportRow{}
+		index     = map[string]int{}
+		occupancy = []float64{}
+	)
+
+	for _, ts := range stats {
+		k := ts.period.String() + "|" + key(ts)
+
+		i, ok := index[k]
+		if !ok {
+			i = len(report)
+			index[k] = i
+
+			r := row(ts)
+			r.Period = ts.period.Format(time.DateOnly)
+			report = append(report, r)
+			occupancy = append(occupancy, 0)
+		}
+
+		report[i].Trips++
+		report[i].Passengers += ts.passengers
+		report[i].Seats += ts.seats
+		report[i].Revenue += ts.revenue
+		occupancy[i] += float64(ts.passengers) * 100 / float64(ts.seats)
+	}
+
+	for i := range report {
+		report[i].Occupancy = math.Round(occupancy[i]/float64(report[i].Trips)*100) / 100
+	}
+
+	return report
+}
+
+func (r reportRepo) Routes(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error) {
+	if err := r.db.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.db.mu.RUnlock()
+
+	stats, err := r.db.tripStats(req)
+	if err != nil {
+		return nil, err
+	}
+
+	report := group(stats,
+		func(ts tripStats) string { return ts.fromCityID + "|" + ts.toCityID },
+		func(ts tripStats) models.ReportRow {
+			return models.ReportRow{
+				FromCityID:   ts.fromCityID,
+				FromCityName: r.db.cityModel(ts.fromCityID).Name,
+				ToCityID:     ts.toCityID,
+				ToCityName:   r.db.cityModel(ts.toCityID).Name,
+			}
+		},
+	)
+
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.FromCityName != b.FromCityName {
+			return a.FromCityName < b.FromCityName
+		}
+		return a.ToCityName < b.ToCityName
+	})
+
+	return report, nil
+}
+
+func (r reportRepo) Drivers(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error) {
+	if err := r.db.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.db.mu.RUnlock()
+
+	stats, err := r.db.tripStats(req)
+	if err != nil {
+		return nil, err
+	}
+
+	report := group(stats,
+		func(ts tripStats) string { return ts.driverID },
+		func(ts tripStats) models.ReportRow {
+			driver, _ := r.db.drivers.get(ts.driverID)
+			return models.ReportRow{
+				DriverID:   ts.driverID,
+				DriverName: driver.fullName,
+			}
+		},
+	)
+
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		return a.DriverName < b.DriverName
+	})
+
+	return report, nil
+}
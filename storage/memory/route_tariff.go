@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type routeTariff struct {
+	id         string
+	fromCityID string
+	toCityID   string
+	basePrice  int
+	seatPrice  int
+	validFrom  time.Time
+	validTo    *time.Time
+	createdAt  time.Time
+}
+
+func (r routeTariff) model(d *db) models.RouteTariff {
+	return models.RouteTariff{
+		ID:           r.id,
+		FromCityID:   r.fromCityID,
+		FromCityData: d.cityModel(r.fromCityID),
+		ToCityID:     r.toCityID,
+		ToCityData:   d.cityModel(r.toCityID),
+		BasePrice:    r.basePrice,
+		SeatPrice:    r.seatPrice,
+		ValidFrom:    formatTimestamptz(r.validFrom),
+		ValidTo:      formatOptionalTimestamptz(r.validTo),
+		CreatedAt:    formatTimestamp(r.createdAt),
+	}
+}
+
+type routeTariffRepo struct {
+	db *db
+}
+
+// Create starts a new tariff of the route. The tariff in effect at valid_from
+// is closed at that moment instead of being changed, so older fares stay in history.
+func (r routeTariffRepo) Create(ctx context.Context, req models.CreateRouteTariff) (string, error) {
+	if err := r.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer r.db.mu.Unlock()
+
+	row := routeTariff{
+		id:         uuid.New().String(),
+		fromCityID: req.FromCityID,
+		toCityID:   req.ToCityID,
+		basePrice:  req.BasePrice,
+		seatPrice:  req.SeatPrice,
+		validFrom:  time.Now().Truncate(time.Microsecond),
+		createdAt:  now(),
+	}
+
+	if req.ValidFrom != "" {
+		validFrom, err := parseTimestamptz(req.ValidFrom)
+		if err != nil {
+			return "", err
+		}
+		row.validFrom = validFrom
+	}
+
+	if err := parseIDs(&row.fromCityID, &row.toCityID); err != nil {
+		return "", err
+	}
+
+	route := func(tariff routeTariff) bool {
+		return tariff.fromCityID == row.fromCityID && tariff.toCityID == row.toCityID
+	}
+
+	if r.db.routeTariffs.any(func(tariff routeTariff) bool {
+		return route(tariff) && !tariff.validFrom.Before(row.validFrom)
+	}) {
+		return "", storage.ErrTariffOverlap
+	}
+
+	if row.basePrice < 0 {
+		return "", errInvalidValue("base_price")
+	}
+
+	if row.seatPrice < 0 {
+		return "", errInvalidValue("seat_price")
+	}
+
+	if _, ok := r.db.cities.get(row.fromCityID); !ok {
+		return "", errForeignKey("from_city_id")
+	}
+
+	if _, ok := r.db.cities.get(row.toCityID); !ok {
+		return "", errForeignKey("to_city_id")
+	}
+
+	for _, tariff := range r.db.routeTariffs.all() {
+		if route(tariff) && (tariff.validTo == nil || tariff.validTo.After(row.validFrom)) {
+			validTo := row.validFrom
+			tariff.validTo = &validTo
+			r.db.routeTariffs.set(tariff.id, tariff)
+		}
+	}
+
+	r.db.routeTariffs.set(row.id, row)
+
+	return row.id, nil
+}
+
+func (r routeTariffRepo) Get(ctx context.Context, id string) (models.RouteTariff, error) {
+	if err := r.db.rlock(ctx); err != nil {
+		return models.RouteTariff{}, err
+	}
+	defer r.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.RouteTariff{}, err
+	}
+
+	row, ok := r.db.routeTariffs.get(id)
+	if !ok {
+		return models.RouteTariff{}, errNotFound()
+	}
+
+	return row.model(r.db), nil
+}
+
+func (r routeTariffRepo) GetList(ctx context.Context, req models.GetRouteTariffListRequest) (models.RouteTariffsResponse, error) {
+	if err := r.db.rlock(ctx); err != nil {
+		return models.RouteTariffsResponse{}, err
+	}
+	defer r.db.mu.RUnlock()
+
+	rows := []routeTariff{}
+	for _, row := range r.db.routeTariffs.all() {
+		if (req.FromCityID == "" || row.fromCityID == req.FromCityID) && (req.ToCityID == "" || row.toCityID == req.ToCityID) {
+			rows = append(rows, row)
+		}
+	}
+	count := len(rows)
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].validFrom.After(rows[j].validFrom)
+	})
+
+	rows, err := page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.RouteTariffsResponse{}, err
+	}
+
+	tariffs := []models.RouteTariff{}
+	for _, row := range rows {
+		tariffs = append(tariffs, row.model(r.db))
+	}
+
+	return models.RouteTariffsResponse{
+		RouteTariffs: tariffs,
+		Count:        count,
+	}, nil
+}
+
+// GetActive returns the tariff of the route in effect at the given time,
+// storage.ErrNoTariff if there is none
+func (r routeTariffRepo) GetActive(ctx context.Context, fromCityID, toCityID, at string) (models.RouteTariff, error) {
+	if err := r.db.rlock(ctx); err != nil {
+		return models.RouteTariff{}, err
+	}
+	defer r.db.mu.RUnlock()
+
+	if err := parseIDs(&fromCityID, &toCityID); err != nil {
+		return models.RouteTariff{}, err
+	}
+
+	moment := time.Now()
+	if at != "" {
+		var err error
+		if moment, err = parseTimestamptz(at); err != nil {
+			return models.RouteTariff{}, err
+		}
+	}
+
+	tariff, ok := r.db.activeTariff(fromCityID, toCityID, moment)
+	if !ok {
+		return models.RouteTariff{}, storage.ErrNoTariff
+	}
+
+	return tariff.model(r.db), nil
+}
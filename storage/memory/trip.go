@@ -0,0 +1,603 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type trip struct {
+	id           string
+	tripNumberID string
+	fromCityID   string
+	toCityID     string
+	driverID     string
+	price        int
+	seats        int
+	templateID   *string
+	tariffID     *string
+	departureAt  time.Time
+	arrivalAt    time.Time
+	status       string
+	boardingAt   *time.Time
+	startedAt    *time.Time
+	completedAt  *time.Time
+	cancelledAt  *time.Time
+	createdAt    time.Time
+}
+
+type tripRepo struct {
+	db                *db
+	commissionPercent int
+}
+
+func (t tripRepo) Create(ctx context.Context, req models.CreateTrip) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	return t.db.insertTrip(req)
+}
+
+func (d *db) insertTrip(req models.CreateTrip) (string, error) {
+	if err := parseIDs(&req.FromCityID, &req.ToCityID, &req.DriverID); err != nil {
+		return "", err
+	}
+
+	row := trip{
+		id:         uuid.New().String(),
+		fromCityID: req.FromCityID,
+		toCityID:   req.ToCityID,
+		driverID:   req.DriverID,
+		price:      req.Price,
+		status:     models.TripStatusScheduled,
+		createdAt:  now(),
+	}
+
+	if req.TemplateID != "" {
+		templateID, err := parseID(req.TemplateID)
+		if err != nil {
+			return "", err
+		}
+		row.templateID = &templateID
+	}
+
+	seats, err := d.driverSeats(row.driverID)
+	if err != nil {
+		return "", err
+	}
+	row.seats = seats
+
+	if err := d.setSchedule(&row, req.DepartureAt, req.ArrivalAt); err != nil {
+		return "", err
+	}
+
+	// a trip without an explicit price takes the fare of the route tariff
+	// in effect at departure and remembers which tariff it came from
+	if row.price == 0 {
+		tariff, ok := d.activeTariff(row.fromCityID, row.toCityID, row.departureAt)
+		if !ok {
+			return "", storage.ErrNoTariff
+		}
+
+		row.price = tariff.basePrice + tariff.seatPrice
+		row.tariffID = &tariff.id
+	}
+
+	if err := d.checkTrip(row); err != nil {
+		return "", err
+	}
+
+	d.tripNumber++
+	row.tripNumberID = "T-" + strconv.Itoa(d.tripNumber)
+
+	d.trips.set(row.id, row)
+
+	return row.id, nil
+}
+
+// setSchedule parses the departure and arrival of the trip and fails with
+// storage.ErrDriverBusy if another not cancelled trip of the driver
+// overlaps them
+func (d *db) setSchedule(row *trip, departureAt, arrivalAt string) error {
+	var err error
+
+	if row.departureAt, err = parseTimestamptz(departureAt); err != nil {
+		return err
+	}
+
+	if row.arrivalAt, err = parseTimestamptz(arrivalAt); err != nil {
+		return err
+	}
+
+	if d.trips.any(func(other trip) bool {
+		return other.driverID == row.driverID &&
+			other.id != row.id &&
+			other.status != models.TripStatusCancelled &&
+			other.departureAt.Before(row.arrivalAt) &&
+			other.arrivalAt.After(row.departureAt)
+	}) {
+		return storage.ErrDriverBusy
+	}
+
+	return nil
+}
+
+// checkTrip checks the columns of the trip the way the trips table does
+// driverSeats returns the seats of the active and newest car of the driver,
+// storage.ErrDriverNoCar if the driver has no car
+func (d *db) driverSeats(driverID string) (int, error) {
+	cars := d.cars.all()
+	sort.SliceStable(cars, func(i, j int) bool {
+		if cars[i].status != cars[j].status {
+			return cars[i].status
+		}
+		return cars[i].createdAt.After(cars[j].createdAt)
+	})
+
+	for _, car := range cars {
+		if car.driverID == driverID {
+			return car.seats, nil
+		}
+	}
+
+	return 0, storage.ErrDriverNoCar
+}
+
+func (d *db) checkTrip(row trip) error {
+	if row.price < 0 {
+		return errInvalidValue("price")
+	}
+
+	if row.seats <= 0 {
+		return errInvalidValue("seats")
+	}
+
+	if !row.arrivalAt.After(row.departureAt) {
+		return errInvalidValue("check")
+	}
+
+	if _, ok := d.cities.get(row.fromCityID); !ok {
+		return errForeignKey("from_city_id")
+	}
+
+	if _, ok := d.cities.get(row.toCityID); !ok {
+		return errForeignKey("to_city_id")
+	}
+
+	if _, ok := d.drivers.get(row.driverID); !ok {
+		return errForeignKey("driver_id")
+	}
+
+	if row.templateID != nil {
+		if _, ok := d.tripTemplates.get(*row.templateID); !ok {
+			return errForeignKey("template_id")
+		}
+	}
+
+	return nil
+}
+
+// bookedSeats is the number of seats of the trip taken by bookings
+func (d *db) bookedSeats(tripID string) int {
+	booked := 0
+	for _, booking := range d.tripCustomers.all() {
+		if booking.tripID == tripID && booking.status == models.BookingStatusBooked {
+			booked += booking.seats
+		}
+	}
+	return booked
+}
+
+// tripModel is the trip with the data of its cities and driver
+func (d *db) tripModel(row trip) models.Trip {
+	driver, _ := d.drivers.get(row.driverID)
+
+	return models.Trip{
+		ID:           row.id,
+		TripNumberID: row.tripNumberID,
+		FromCityID:   row.fromCityID,
+		FromCityData: d.cityModel(row.fromCityID),
+		ToCityID:     row.toCityID,
+		ToCityData:   d.cityModel(row.toCityID),
+		DriverID:     row.driverID,
+		DriverData:   d.driverModel(driver),
+		Price:        row.price,
+		Seats:        row.seats,
+		FreeSeats:    row.seats - d.bookedSeats(row.id),
+		TemplateID:   row.templateID,
+		TariffID:     row.tariffID,
+		DepartureAt:  formatTimestamptz(row.departureAt),
+		ArrivalAt:    formatTimestamptz(row.arrivalAt),
+		Status:       row.status,
+		BoardingAt:   formatOptional(row.boardingAt),
+		StartedAt:    formatOptional(row.startedAt),
+		CompletedAt:  formatOptional(row.completedAt),
+		CancelledAt:  formatOptional(row.cancelledAt),
+		CreatedAt:    formatTimestamp(row.createdAt),
+	}
+}
+
+func (d *db) tripModels(rows []trip) []models.Trip {
+	trips := []models.Trip{}
+	for _, row := range rows {
+		trips = append(trips, d.tripModel(row))
+	}
+	return trips
+}
+
+func (t tripRepo) Get(ctx context.Context, pkey models.PrimaryKey) (models.Trip, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.Trip{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	id, err := parseID(pkey.ID)
+	if err != nil {
+		return models.Trip{}, err
+	}
+
+	row, ok := t.db.trips.get(id)
+	if !ok {
+		return models.Trip{}, errNotFound()
+	}
+
+	return t.db.tripModel(row), nil
+}
+
+// filterTrips returns the trips with the status and driver of the request,
+// all of them for empty values
+func (d *db) filterTrips(req models.GetTripListRequest) []trip {
+	rows := []trip{}
+	for _, row := range d.trips.all() {
+		if (req.Status == "" || row.status == req.Status) && (req.DriverID == "" || row.driverID == req.DriverID) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+var (
+	// tripsByNewest is the order of the list of trips which is not sorted
+	tripsByNewest = []models.SortField{{Field: "created_at", Desc: true}}
+
+	// tripsByDeparture is the order of the export of trips which is not sorted
+	tripsByDeparture = []models.SortField{{Field: "departure_at"}}
+)
+
+// tripFields are the fields the list of trips can be filtered and sorted by,
+// free_seats counts the bookings of d
+func (d *db) tripFields() listFields[trip] {
+	return listFields[trip]{
+		"id":             uuidField(func(t trip) string { return t.id }),
+		"trip_number_id": textField(func(t trip) string { return t.tripNumberID }),
+		"from_city_id":   uuidField(func(t trip) string { return t.fromCityID }),
+		"to_city_id":     uuidField(func(t trip) string { return t.toCityID }),
+		"driver_id":      uuidField(func(t trip) string { return t.driverID }),
+		"price":          intField(func(t trip) int { return t.price }),
+		"seats":          intField(func(t trip) int { return t.seats }),
+		"free_seats":     intField(func(t trip) int { return t.seats - d.bookedSeats(t.id) }),
+		"status":         textField(func(t trip) string { return t.status }),
+		"departure_at":   timestamptzField(func(t trip) time.Time { return t.departureAt }),
+		"arrival_at":     timestamptzField(func(t trip) time.Time { return t.arrivalAt }),
+		"created_at":     timeField(func(t trip) time.Time { return t.createdAt }),
+	}
+}
+
+// tripSearch returns the texts the search of trips looks in
+func (d *db) tripSearch(t trip) []string {
+	from, _ := d.cities.get(t.fromCityID)
+	to, _ := d.cities.get(t.toCityID)
+	driver, _ := d.drivers.get(t.driverID)
+
+	return []string{t.tripNumberID, from.name, to.name, driver.fullName, driver.phone}
+}
+
+func (t tripRepo) GetList(ctx context.Context, req models.GetTripListRequest) (models.TripsResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripsResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	rows, err := list(t.db.filterTrips(req), req.ListQuery, t.db.tripFields(), t.db.tripSearch, tripsByNewest)
+	if err != nil {
+		return models.TripsResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.TripsResponse{}, err
+	}
+
+	return models.TripsResponse{
+		Trips: t.db.tripModels(rows),
+		Count: count,
+	}, nil
+}
+
+// Stream calls fn for every trip of the list of req in the order of its
+// sort, by departure when it has none. Page and limit are ignored.
+func (t tripRepo) Stream(ctx context.Context, req models.GetTripListRequest, fn func(models.Trip) error) error {
+	if err := t.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(t.db.filterTrips(req), req.ListQuery, t.db.tripFields(), t.db.tripSearch, tripsByDeparture)
+	if err != nil {
+		t.db.mu.RUnlock()
+		return err
+	}
+	trips := t.db.tripModels(rows)
+	t.db.mu.RUnlock()
+
+	for _, trip := range trips {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(trip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t tripRepo) Search(ctx context.Context, req models.SearchTripRequest) (models.TripsResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripsResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	departureFrom, err := parseTimestamptz(req.DepartureFrom)
+	if err != nil {
+		return models.TripsResponse{}, err
+	}
+
+	match := []func(trip) bool{
+		func(row trip) bool {
+			return (row.status == models.TripStatusScheduled || row.status == models.TripStatusBoarding) &&
+				!row.departureAt.Before(departureFrom)
+		},
+	}
+
+	if req.DepartureTo != "" {
+		departureTo, err := parseTimestamptz(req.DepartureTo)
+		if err != nil {
+			return models.TripsResponse{}, err
+		}
+		match = append(match, func(row trip) bool { return row.departureAt.Before(departureTo) })
+	}
+
+	if req.FromCityID != "" {
+		fromCityID, err := parseID(req.FromCityID)
+		if err != nil {
+			return models.TripsResponse{}, err
+		}
+		match = append(match, func(row trip) bool { return row.fromCityID == fromCityID })
+	}
+
+	if req.ToCityID != "" {
+		toCityID, err := parseID(req.ToCityID)
+		if err != nil {
+			return models.TripsResponse{}, err
+		}
+		match = append(match, func(row trip) bool { return row.toCityID == toCityID })
+	}
+
+	if req.MaxPrice > 0 {
+		match = append(match, func(row trip) bool { return row.price <= req.MaxPrice })
+	}
+
+	if req.MinFreeSeats > 0 {
+		match = append(match, func(row trip) bool { return row.seats-t.db.bookedSeats(row.id) >= req.MinFreeSeats })
+	}
+
+	rows := []trip{}
+next:
+	for _, row := range t.db.trips.all() {
+		for _, m := range match {
+			if !m(row) {
+				continue next
+			}
+		}
+		rows = append(rows, row)
+	}
+	count := len(rows)
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].departureAt.Equal(rows[j].departureAt) {
+			return rows[i].departureAt.Before(rows[j].departureAt)
+		}
+		return rows[i].price < rows[j].price
+	})
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.TripsResponse{}, err
+	}
+
+	return models.TripsResponse{
+		Trips: t.db.tripModels(rows),
+		Count: count,
+	}, nil
+}
+
+func (t tripRepo) Update(ctx context.Context, req models.Trip) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	if err := parseIDs(&req.ID, &req.FromCityID, &req.ToCityID, &req.DriverID); err != nil {
+		return "", err
+	}
+
+	row, ok := t.db.trips.get(req.ID)
+	if !ok {
+		return "", errNotFound()
+	}
+
+	// a trip which is boarding or on the road keeps its driver and times
+	if row.status != models.TripStatusScheduled {
+		return "", storage.ErrTripNotEditable
+	}
+
+	// another driver comes with another car, the bookings must fit in it
+	if req.DriverID != row.driverID {
+		seats, err := t.db.driverSeats(req.DriverID)
+		if err != nil {
+			return "", err
+		}
+
+		if t.db.bookedSeats(row.id) > seats {
+			return "", storage.ErrCarTooSmall
+		}
+		row.seats = seats
+	}
+
+	row.fromCityID = req.FromCityID
+	row.toCityID = req.ToCityID
+	row.driverID = req.DriverID
+	row.price = req.Price
+
+	if err := t.db.setSchedule(&row, req.DepartureAt, req.ArrivalAt); err != nil {
+		return "", err
+	}
+
+	if err := t.db.checkTrip(row); err != nil {
+		return "", err
+	}
+
+	t.db.trips.set(row.id, row)
+
+	return req.ID, nil
+}
+
+func (t tripRepo) UpdateStatus(ctx context.Context, req models.UpdateTripStatus) error {
+	if err := t.db.lock(ctx); err != nil {
+		return err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	row, ok := t.db.trips.get(id)
+	if !ok {
+		return errNotFound()
+	}
+
+	if err := storage.CheckTripTransition(row.status, req.Status); err != nil {
+		return err
+	}
+
+	at := now()
+	row.status = req.Status
+
+	switch req.Status {
+	case models.TripStatusBoarding:
+		row.boardingAt = &at
+	case models.TripStatusInProgress:
+		row.startedAt = &at
+	case models.TripStatusCompleted:
+		row.completedAt = &at
+	case models.TripStatusCancelled:
+		row.cancelledAt = &at
+	}
+
+	t.db.trips.set(id, row)
+
+	if req.Status == models.TripStatusCompleted {
+		t.creditDriver(row)
+	}
+
+	return nil
+}
+
+// creditDriver adds the earnings of a completed trip to the driver ledger:
+// the fares of paid bookings and the part of the fares of paid bookings
+// cancelled late that is not refunded, minus the platform commission
+func (t tripRepo) creditDriver(row trip) {
+	paid := 0
+	for _, booking := range t.db.tripCustomers.all() {
+		if booking.tripID != row.id {
+			continue
+		}
+
+		switch {
+		case booking.status == models.BookingStatusBooked && booking.paymentStatus == models.BookingPaid:
+			paid += booking.fare
+		case booking.status == models.BookingStatusCancelled && booking.paymentStatus != models.BookingUnpaid:
+			paid += booking.fare - booking.refundAmount
+		}
+	}
+
+	commission := paid * t.commissionPercent / 100
+
+	entry := ledgerEntry{
+		id:          uuid.New().String(),
+		driverID:    row.driverID,
+		tripID:      row.id,
+		kind:        models.LedgerCredit,
+		amount:      paid - commission,
+		commission:  commission,
+		description: "trip " + row.tripNumberID,
+		createdAt:   now(),
+	}
+
+	t.db.ledger.set(entry.id, entry)
+}
+
+func (t tripRepo) Delete(ctx context.Context, pkey models.PrimaryKey) error {
+	if err := t.db.lock(ctx); err != nil {
+		return err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(pkey.ID)
+	if err != nil {
+		return err
+	}
+
+	if t.db.tripCustomers.any(func(b tripCustomer) bool { return b.tripID == id }) ||
+		t.db.ledger.any(func(e ledgerEntry) bool { return e.tripID == id }) {
+		return errForeignKey("trip_id")
+	}
+
+	t.db.trips.delete(id)
+
+	return nil
+}
+
+// activeTariff is the tariff of the route in effect at the time
+func (d *db) activeTariff(fromCityID, toCityID string, at time.Time) (routeTariff, bool) {
+	var (
+		active routeTariff
+		found  bool
+	)
+
+	for _, tariff := range d.routeTariffs.all() {
+		if tariff.fromCityID != fromCityID || tariff.toCityID != toCityID {
+			continue
+		}
+
+		if tariff.validFrom.After(at) || (tariff.validTo != nil && !tariff.validTo.After(at)) {
+			continue
+		}
+
+		if !found || tariff.validFrom.After(active.validFrom) {
+			active, found = tariff, true
+		}
+	}
+
+	return active, found
+}
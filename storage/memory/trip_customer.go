@@ -0,0 +1,412 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"city2city/pricing"
+	"city2city/storage"
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type tripCustomer struct {
+	id            string
+	tripID        string
+	customerID    string
+	seats         int
+	fare          int
+	currency      string
+	status        string
+	cancelReason  *string
+	cancelledAt   *time.Time
+	refundAmount  int
+	paymentStatus string
+	createdAt     time.Time
+}
+
+type tripCustomerRepo struct {
+	db           *db
+	refundPolicy pricing.RefundPolicy
+}
+
+// secondsUntil is the whole seconds from now until the moment t, rounded
+// like an epoch cast to int
+func secondsUntil(t time.Time) int {
+	return int(math.RoundToEven(time.Until(t).Seconds()))
+}
+
+func (t tripCustomerRepo) Create(ctx context.Context, req models.CreateTripCustomer) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	if err := parseIDs(&req.TripID, &req.CustomerID); err != nil {
+		return "", err
+	}
+
+	trip, ok := t.db.trips.get(req.TripID)
+	if !ok {
+		return "", errNotFound()
+	}
+
+	if trip.status != models.TripStatusScheduled && trip.status != models.TripStatusBoarding {
+		return "", storage.ErrTripNotBookable
+	}
+
+	booked := t.db.bookedSeats(trip.id)
+
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+
+	if booked+req.Seats > trip.seats {
+		return "", storage.ErrTripFull
+	}
+
+	// trips priced from a tariff already include the base price once,
+	// every extra seat adds the tariff seat price
+	fare := trip.price * req.Seats
+	if trip.tariffID != nil {
+		if tariff, ok := t.db.routeTariffs.get(*trip.tariffID); ok {
+			fare = trip.price + tariff.seatPrice*(req.Seats-1)
+		}
+	}
+
+	rules := []models.PriceRule{}
+	for _, rule := range t.db.priceRules.all() {
+		if rule.active {
+			rules = append(rules, rule.model())
+		}
+	}
+
+	fare = pricing.Quote(fare, trip.seats, booked, secondsUntil(trip.departureAt)/60, rules)
+
+	row := tripCustomer{
+		id:            uuid.New().String(),
+		tripID:        trip.id,
+		customerID:    req.CustomerID,
+		seats:         req.Seats,
+		fare:          fare,
+		currency:      models.CurrencyUZS,
+		status:        models.BookingStatusBooked,
+		paymentStatus: models.BookingUnpaid,
+		createdAt:     now(),
+	}
+
+	if row.seats <= 0 {
+		return "", errInvalidValue("seats")
+	}
+
+	if _, ok := t.db.customers.get(row.customerID); !ok {
+		return "", errForeignKey("customer_id")
+	}
+
+	t.db.tripCustomers.set(row.id, row)
+
+	return row.id, nil
+}
+
+func (d *db) tripCustomerModel(row tripCustomer) models.TripCustomer {
+	return models.TripCustomer{
+		ID:            row.id,
+		TripID:        row.tripID,
+		CustomerID:    row.customerID,
+		CustomerData:  d.customerModel(row.customerID),
+		Seats:         row.seats,
+		Fare:          row.fare,
+		Currency:      row.currency,
+		Status:        row.status,
+		CancelReason:  row.cancelReason,
+		CancelledAt:   formatOptional(row.cancelledAt),
+		RefundAmount:  row.refundAmount,
+		PaymentStatus: row.paymentStatus,
+		CreatedAt:     formatTimestamp(row.createdAt),
+	}
+}
+
+// tripCustomers returns the bookings which match in the order they were
+// created together with their count
+func (d *db) tripCustomerModels(match func(tripCustomer) bool) models.TripCustomersResponse {
+	rows := []tripCustomer{}
+	for _, row := range d.tripCustomers.all() {
+		if match(row) {
+			rows = append(rows, row)
+		}
+	}
+
+	sortByCreatedAt(rows, func(row tripCustomer) time.Time { return row.createdAt })
+
+	tripCustomers := []models.TripCustomer{}
+	for _, row := range rows {
+		tripCustomers = append(tripCustomers, d.tripCustomerModel(row))
+	}
+
+	return models.TripCustomersResponse{
+		TripCustomers: tripCustomers,
+		Count:         len(tripCustomers),
+	}
+}
+
+func (t tripCustomerRepo) Get(ctx context.Context, id string) (models.TripCustomer, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripCustomer{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.TripCustomer{}, err
+	}
+
+	row, ok := t.db.tripCustomers.get(id)
+	if !ok {
+		return models.TripCustomer{}, errNotFound()
+	}
+
+	return t.db.tripCustomerModel(row), nil
+}
+
+// tripCustomerFields are the fields the list of bookings can be filtered and
+// sorted by
+var tripCustomerFields = listFields[tripCustomer]{
+	"id":             uuidField(func(t tripCustomer) string { return t.id }),
+	"trip_id":        uuidField(func(t tripCustomer) string { return t.tripID }),
+	"customer_id":    uuidField(func(t tripCustomer) string { return t.customerID }),
+	"seats":          intField(func(t tripCustomer) int { return t.seats }),
+	"fare":           intField(func(t tripCustomer) int { return t.fare }),
+	"status":         textField(func(t tripCustomer) string { return t.status }),
+	"payment_status": textField(func(t tripCustomer) string { return t.paymentStatus }),
+	"created_at":     timeField(func(t tripCustomer) time.Time { return t.createdAt }),
+}
+
+// tripCustomerSearch returns the texts the search of bookings looks in, the
+// name and the phone of the customer
+func (d *db) tripCustomerSearch(row tripCustomer) []string {
+	customer, _ := d.customers.get(row.customerID)
+	return []string{customer.fullName, customer.phone}
+}
+
+func (t tripCustomerRepo) GetList(ctx context.Context, req models.GetListRequest) (models.TripCustomersResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	rows, err := list(t.db.tripCustomers.all(), req.ListQuery, tripCustomerFields, t.db.tripCustomerSearch, byCreatedAt)
+	if err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+	count := len(rows)
+
+	rows, err = page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+
+	tripCustomers := []models.TripCustomer{}
+	for _, row := range rows {
+		tripCustomers = append(tripCustomers, t.db.tripCustomerModel(row))
+	}
+
+	return models.TripCustomersResponse{
+		TripCustomers: tripCustomers,
+		Count:         count,
+	}, nil
+}
+
+// Stream calls fn for every trip customer matching the filters and the
+// search of q in the order of the list
+func (t tripCustomerRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.TripCustomer) error) error {
+	if err := t.db.rlock(ctx); err != nil {
+		return err
+	}
+	rows, err := list(t.db.tripCustomers.all(), q, tripCustomerFields, t.db.tripCustomerSearch, byCreatedAt)
+	if err != nil {
+		t.db.mu.RUnlock()
+		return err
+	}
+
+	tripCustomers := []models.TripCustomer{}
+	for _, row := range rows {
+		tripCustomers = append(tripCustomers, t.db.tripCustomerModel(row))
+	}
+	t.db.mu.RUnlock()
+
+	for _, tripCustomer := range tripCustomers {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+
+		if err := fn(tripCustomer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByTrip returns every booking of the trip, cancelled ones included
+func (t tripCustomerRepo) GetByTrip(ctx context.Context, tripID string) (models.TripCustomersResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	tripID, err := parseID(tripID)
+	if err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+
+	return t.db.tripCustomerModels(func(row tripCustomer) bool {
+		return row.tripID == tripID
+	}), nil
+}
+
+// GetUnpaid returns the active bookings of the trip nobody has paid for yet
+func (t tripCustomerRepo) GetUnpaid(ctx context.Context, tripID string) (models.TripCustomersResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	tripID, err := parseID(tripID)
+	if err != nil {
+		return models.TripCustomersResponse{}, err
+	}
+
+	return t.db.tripCustomerModels(func(row tripCustomer) bool {
+		return row.tripID == tripID && row.status == models.BookingStatusBooked && row.paymentStatus == models.BookingUnpaid
+	}), nil
+}
+
+func (t tripCustomerRepo) Update(ctx context.Context, req models.TripCustomer) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	if err := parseIDs(&req.ID, &req.CustomerID); err != nil {
+		return "", err
+	}
+
+	row, ok := t.db.tripCustomers.get(req.ID)
+	if !ok {
+		return req.ID, nil
+	}
+
+	if _, ok := t.db.customers.get(req.CustomerID); !ok {
+		return "", errForeignKey("customer_id")
+	}
+
+	row.customerID = req.CustomerID
+	t.db.tripCustomers.set(row.id, row)
+
+	return req.ID, nil
+}
+
+func (t tripCustomerRepo) Delete(ctx context.Context, id string) error {
+	if err := t.db.lock(ctx); err != nil {
+		return err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	if t.db.payments.any(func(p payment) bool { return p.tripCustomerID == id }) {
+		return errForeignKey("trip_customer_id")
+	}
+
+	t.db.tripCustomers.delete(id)
+
+	return nil
+}
+
+// Cancel keeps the booking but marks it cancelled, which frees its seats,
+// and stores the refund the refund policy gives for it
+func (t tripCustomerRepo) Cancel(ctx context.Context, req models.CancelTripCustomer) error {
+	if err := t.db.lock(ctx); err != nil {
+		return err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	row, ok := t.db.tripCustomers.get(id)
+	if !ok {
+		return errNotFound()
+	}
+
+	trip, ok := t.db.trips.get(row.tripID)
+	if !ok {
+		return errNotFound()
+	}
+
+	if row.status == models.BookingStatusCancelled {
+		return storage.ErrBookingCancelled
+	}
+
+	if trip.status == models.TripStatusInProgress || trip.status == models.TripStatusCompleted {
+		return storage.ErrTripStarted
+	}
+
+	// when the trip itself is cancelled the customer gets everything back
+	refund := row.fare
+	if trip.status != models.TripStatusCancelled {
+		refund = t.refundPolicy.Refund(row.fare, time.Duration(secondsUntil(trip.departureAt))*time.Second)
+	}
+
+	at := now()
+	row.status = models.BookingStatusCancelled
+	row.cancelReason = &req.Reason
+	row.cancelledAt = &at
+	row.refundAmount = refund
+
+	t.db.tripCustomers.set(id, row)
+
+	// the money an authorized payment holds is not taken any more
+	for _, payment := range t.db.payments.all() {
+		if payment.tripCustomerID == id && payment.status == models.PaymentStatusAuthorized {
+			payment.status = models.PaymentStatusVoided
+			payment.updatedAt = at
+			t.db.payments.set(payment.id, payment)
+		}
+	}
+
+	return nil
+}
+
+func (t tripCustomerRepo) GetTripTotals(ctx context.Context, tripID string) (models.TripTotals, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripTotals{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	totals := models.TripTotals{
+		TripID:   tripID,
+		Currency: models.CurrencyUZS,
+	}
+
+	tripID, err := parseID(tripID)
+	if err != nil {
+		return models.TripTotals{}, err
+	}
+
+	for _, row := range t.db.tripCustomers.all() {
+		if row.tripID == tripID && row.status == models.BookingStatusBooked {
+			totals.Bookings++
+			totals.Passengers += row.seats
+			totals.TotalFare += row.fare
+		}
+	}
+
+	return totals, nil
+}
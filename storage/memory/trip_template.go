@@ -0,0 +1,269 @@
+package memory
+
+import (
+	"city2city/api/models"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type tripTemplate struct {
+	id         string
+	fromCityID string
+	toCityID   string
+	driverID   string
+	price      int
+	weekdays   []int
+	// departureTime is the time of day the trips depart at
+	departureTime   time.Duration
+	durationMinutes int
+	createdAt       time.Time
+}
+
+type tripTemplateRepo struct {
+	db *db
+}
+
+// parseTimeOfDay parses a value of a time column
+func parseTimeOfDay(value string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05", "15:04:05.999999"} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+		}
+	}
+
+	return 0, errInvalidText("time", value)
+}
+
+func (t tripTemplate) model(d *db) models.TripTemplate {
+	return models.TripTemplate{
+		ID:              t.id,
+		FromCityID:      t.fromCityID,
+		FromCityData:    d.cityModel(t.fromCityID),
+		ToCityID:        t.toCityID,
+		ToCityData:      d.cityModel(t.toCityID),
+		DriverID:        t.driverID,
+		Price:           t.price,
+		Weekdays:        append([]int{}, t.weekdays...),
+		DepartureTime:   fmt.Sprintf("%02d:%02d", int(t.departureTime.Hours()), int(t.departureTime.Minutes())%60),
+		DurationMinutes: t.durationMinutes,
+		CreatedAt:       formatTimestamp(t.createdAt),
+	}
+}
+
+// setTemplate fills the row from the request and checks it the way the
+// trip_templates table does
+func (d *db) setTemplate(row *tripTemplate, fromCityID, toCityID, driverID string, price int, weekdays []int, departureTime string, durationMinutes int) error {
+	if err := parseIDs(&fromCityID, &toCityID, &driverID); err != nil {
+		return err
+	}
+
+	departure, err := parseTimeOfDay(departureTime)
+	if err != nil {
+		return err
+	}
+
+	if weekdays == nil {
+		return errInvalidValue("weekdays")
+	}
+
+	if price < 0 {
+		return errInvalidValue("price")
+	}
+
+	if durationMinutes <= 0 {
+		return errInvalidValue("duration_minutes")
+	}
+
+	if _, ok := d.cities.get(fromCityID); !ok {
+		return errForeignKey("from_city_id")
+	}
+
+	if _, ok := d.cities.get(toCityID); !ok {
+		return errForeignKey("to_city_id")
+	}
+
+	if _, ok := d.drivers.get(driverID); !ok {
+		return errForeignKey("driver_id")
+	}
+
+	row.fromCityID = fromCityID
+	row.toCityID = toCityID
+	row.driverID = driverID
+	row.price = price
+	row.weekdays = append([]int{}, weekdays...)
+	row.departureTime = departure
+	row.durationMinutes = durationMinutes
+
+	return nil
+}
+
+func (t tripTemplateRepo) Create(ctx context.Context, req models.CreateTripTemplate) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	row := tripTemplate{
+		id:        uuid.New().String(),
+		createdAt: now(),
+	}
+
+	if err := t.db.setTemplate(&row, req.FromCityID, req.ToCityID, req.DriverID, req.Price, req.Weekdays, req.DepartureTime, req.DurationMinutes); err != nil {
+		return "", err
+	}
+
+	t.db.tripTemplates.set(row.id, row)
+
+	return row.id, nil
+}
+
+func (t tripTemplateRepo) Get(ctx context.Context, id string) (models.TripTemplate, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripTemplate{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return models.TripTemplate{}, err
+	}
+
+	row, ok := t.db.tripTemplates.get(id)
+	if !ok {
+		return models.TripTemplate{}, errNotFound()
+	}
+
+	return row.model(t.db), nil
+}
+
+func (t tripTemplateRepo) GetList(ctx context.Context, req models.GetListRequest) (models.TripTemplatesResponse, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return models.TripTemplatesResponse{}, err
+	}
+	defer t.db.mu.RUnlock()
+
+	rows := t.db.tripTemplates.all()
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].createdAt.After(rows[j].createdAt)
+	})
+
+	rows, err := page(rows, req.Limit, (req.Page-1)*req.Limit)
+	if err != nil {
+		return models.TripTemplatesResponse{}, err
+	}
+
+	templates := []models.TripTemplate{}
+	for _, row := range rows {
+		templates = append(templates, row.model(t.db))
+	}
+
+	return models.TripTemplatesResponse{
+		TripTemplates: templates,
+		Count:         len(t.db.tripTemplates.keys),
+	}, nil
+}
+
+func (t tripTemplateRepo) Update(ctx context.Context, req models.TripTemplate) (string, error) {
+	if err := t.db.lock(ctx); err != nil {
+		return "", err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(req.ID)
+	if err != nil {
+		return "", err
+	}
+
+	row, ok := t.db.tripTemplates.get(id)
+	if !ok {
+		return req.ID, nil
+	}
+
+	if err := t.db.setTemplate(&row, req.FromCityID, req.ToCityID, req.DriverID, req.Price, req.Weekdays, req.DepartureTime, req.DurationMinutes); err != nil {
+		return "", err
+	}
+
+	t.db.tripTemplates.set(id, row)
+
+	return req.ID, nil
+}
+
+func (t tripTemplateRepo) Delete(ctx context.Context, id string) error {
+	if err := t.db.lock(ctx); err != nil {
+		return err
+	}
+	defer t.db.mu.Unlock()
+
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	if t.db.trips.any(func(row trip) bool { return row.templateID != nil && *row.templateID == id }) {
+		return errForeignKey("template_id")
+	}
+
+	t.db.tripTemplates.delete(id)
+
+	return nil
+}
+
+// GeneratedDates returns the days in [fromDate, toDate] on which a trip of the
+// template already departs, cancelled trips included so they are not recreated
+func (t tripTemplateRepo) GeneratedDates(ctx context.Context, templateID, fromDate, toDate string) ([]string, error) {
+	if err := t.db.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer t.db.mu.RUnlock()
+
+	templateID, err := parseID(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseDate(fromDate)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseDate(toDate)
+	if err != nil {
+		return nil, err
+	}
+	to = to.AddDate(0, 0, 1)
+
+	seen := map[string]bool{}
+	dates := []string{}
+	for _, row := range t.db.trips.all() {
+		if row.templateID == nil || *row.templateID != templateID || row.departureAt.Before(from) || !row.departureAt.Before(to) {
+			continue
+		}
+
+		date := row.departureAt.In(time.Local).Format(time.DateOnly)
+		if !seen[date] {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+
+	sort.Strings(dates)
+
+	return dates, nil
+}
+
+// parseDate parses a value cast to date, the time of a timestamp is dropped.
+// The date starts at the local midnight, where a date compared with a
+// timestamptz starts.
+func parseDate(value string) (time.Time, error) {
+	t, err := parseTimestamp(value)
+	if err != nil {
+		return time.Time{}, errInvalidText("date", value)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local), nil
+}
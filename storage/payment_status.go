@@ -0,0 +1,61 @@
+package storage
+
+import "city2city/api/models"
+
+// paymentTransitions maps a payment status to the statuses a payment can
+// move to from it. Capturing and refunding hold the payment while the
+// provider is called, they move on when it succeeds and back when it fails.
+// An authorized payment is voided when its booking is cancelled.
+var paymentTransitions = map[string][]string{
+	models.PaymentStatusAuthorized: {models.PaymentStatusCapturing, models.PaymentStatusFailed, models.PaymentStatusVoided},
+	models.PaymentStatusCapturing:  {models.PaymentStatusCaptured, models.PaymentStatusAuthorized},
+	models.PaymentStatusCaptured:   {models.PaymentStatusRefunding},
+	models.PaymentStatusRefunding:  {models.PaymentStatusRefunded, models.PaymentStatusCaptured},
+}
+
+// activePaymentStatuses are the statuses of a payment which holds the money
+// of its booking, a booking can have one such payment at a time
+var activePaymentStatuses = []string{
+	models.PaymentStatusAuthorized,
+	models.PaymentStatusCapturing,
+	models.PaymentStatusCaptured,
+	models.PaymentStatusRefunding,
+}
+
+// IsPaymentStatus reports whether status is a status a payment can have
+func IsPaymentStatus(status string) bool {
+	_, ok := paymentTransitions[status]
+	return ok || status == models.PaymentStatusRefunded || status == models.PaymentStatusFailed || status == models.PaymentStatusVoided
+}
+
+// CheckPaymentTransition returns an error unless a payment can move from
+// the status from to the status to
+func CheckPaymentTransition(from, to string) error {
+	if !IsPaymentStatus(from) || !IsPaymentStatus(to) {
+		return ErrUnknownPaymentStatus
+	}
+
+	for _, status := range paymentTransitions[from] {
+		if status == to {
+			return nil
+		}
+	}
+
+	return ErrInvalidPaymentStatus
+}
+
+// IsActivePayment reports whether a payment in the status holds the money
+// of its booking
+func IsActivePayment(status string) bool {
+	for _, active := range activePaymentStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
+
+// ActivePaymentStatuses returns the statuses IsActivePayment reports true for
+func ActivePaymentStatuses() []string {
+	return append([]string(nil), activePaymentStatuses...)
+}
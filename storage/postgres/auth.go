@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type authRepo struct {
+	db conn
+}
+
+func NewAuthRepo(db conn) storage.IAuthRepo {
+	return authRepo{
+		db: db,
+	}
+}
+
+// userTables are the tables the users of each role are kept in
+var userTables = map[string]string{
+	models.RoleCustomer:   "customers",
+	models.RoleDriver:     "drivers",
+	models.RoleAdmin:      "admins",
+	models.RoleDispatcher: "admins",
+}
+
+func (a authRepo) CreateAdmin(ctx context.Context, login, passwordHash, role string) (string, error) {
+	uid := uuid.New()
+
+	if _, err := a.db.ExecContext(ctx, `
+		INSERT INTO admins (id, login, password_hash, role) VALUES ($1, $2, $3, $4)
+		`, uid, login, passwordHash, role,
+	); err != nil {
+		fmt.Println("error while inserting admin", err.Error())
+		return "", dbError(err)
+	}
+
+	return uid.String(), nil
+}
+
+func (a authRepo) GetAdminByLogin(ctx context.Context, login string) (models.Admin, error) {
+	admin := models.Admin{}
+
+	if err := a.db.QueryRowContext(ctx, `
+		SELECT id, login, role, password_hash, created_at FROM admins WHERE login = $1
+		`, login,
+	).Scan(
+		&admin.ID,
+		&admin.Login,
+		&admin.Role,
+		&admin.PasswordHash,
+		&admin.CreatedAt,
+	); err != nil {
+		fmt.Println("error while scanning admin", err.Error())
+		return models.Admin{}, dbError(err)
+	}
+
+	return admin, nil
+}
+
+func (a authRepo) UserIDByPhone(ctx context.Context, role, phone string) (string, error) {
+	table, ok := userTables[role]
+	if !ok || table == "admins" {
+		return "", storage.NewError(storage.KindValidation, "unknown_role", "role can not log in by phone")
+	}
+
+	id := ""
+	if err := a.db.QueryRowContext(ctx, `SELECT id FROM `+table+` WHERE phone = $1`, phone).Scan(&id); err != nil {
+		fmt.Println("error while scanning user by phone", err.Error())
+		return "", dbError(err)
+	}
+
+	return id, nil
+}
+
+func (a authRepo) UserExists(ctx context.Context, role, id string) (bool, error) {
+	table, ok := userTables[role]
+	if !ok {
+		return false, nil
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM ` + table + ` WHERE id = $1)`
+	args := []interface{}{id}
+	if table == "admins" {
+		// an admin whose role was changed has to log in again
+		query = `SELECT EXISTS (SELECT 1 FROM admins WHERE id = $1 AND role = $2)`
+		args = append(args, role)
+	}
+
+	exists := false
+	if err := a.db.QueryRowContext(ctx, query, args...).Scan(&exists); err != nil {
+		fmt.Println("error while checking user", err.Error())
+		return false, dbError(err)
+	}
+
+	return exists, nil
+}
+
+// SaveOTP replaces the code of the phone and role, a new code resets the attempts
+func (a authRepo) SaveOTP(ctx context.Context, otp models.OTP) error {
+	if _, err := a.db.ExecContext(ctx, `
+		INSERT INTO otp_codes (phone, role, code_hash, expires_at, attempts, created_at)
+		VALUES ($1, $2, $3, $4, 0, now())
+		ON CONFLICT (phone, role) DO UPDATE
+		SET code_hash = EXCLUDED.code_hash,
+			expires_at = EXCLUDED.expires_at,
+			attempts = 0,
+			created_at = now()
+		`, otp.Phone, otp.Role, otp.CodeHash, otp.ExpiresAt,
+	); err != nil {
+		fmt.Println("error while saving otp", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (a authRepo) GetOTP(ctx context.Context, phone, role string) (models.OTP, error) {
+	otp := models.OTP{}
+
+	if err := a.db.QueryRowContext(ctx, `
+		SELECT phone, role, code_hash, expires_at, attempts, created_at
+		FROM otp_codes WHERE phone = $1 AND role = $2
+		`, phone, role,
+	).Scan(
+		&otp.Phone,
+		&otp.Role,
+		&otp.CodeHash,
+		&otp.ExpiresAt,
+		&otp.Attempts,
+		&otp.CreatedAt,
+	); err != nil {
+		fmt.Println("error while scanning otp", err.Error())
+		return models.OTP{}, dbError(err)
+	}
+
+	return otp, nil
+}
+
+func (a authRepo) AddOTPAttempt(ctx context.Context, phone, role string) error {
+	if _, err := a.db.ExecContext(ctx, `
+		UPDATE otp_codes SET attempts = attempts + 1 WHERE phone = $1 AND role = $2
+		`, phone, role,
+	); err != nil {
+		fmt.Println("error while updating otp attempts", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (a authRepo) DeleteOTP(ctx context.Context, phone, role string) error {
+	if _, err := a.db.ExecContext(ctx, `DELETE FROM otp_codes WHERE phone = $1 AND role = $2`, phone, role); err != nil {
+		fmt.Println("error while deleting otp", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
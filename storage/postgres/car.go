@@ -3,79 +3,43 @@ package postgres
 import (
 	"city2city/api/models"
 	"city2city/storage"
-	"database/sql"
+	"context"
 	"fmt"
 
 	"github.com/google/uuid"
 )
 
+const defaultCarSeats = 4
+
 type carRepo struct {
-	db *sql.DB
+	db conn
 }
 
-func NewCarRepo(db *sql.DB) storage.ICarRepo {
+func NewCarRepo(db conn) storage.ICarRepo {
 	return carRepo{
 		db,
 	}
 }
 
-func (c carRepo) Create(car models.CreateCar) (string, error) {
+func (c carRepo) Create(ctx context.Context, car models.CreateCar) (string, error) {
 
 	uid := uuid.New().String()
-	query := `INSERT INTO cars (id, model, brand, number, driver_id) VALUES ($1, $2, $3, $4, $5)`
-	_, err := c.db.Exec(query, uid, car.Model, car.Brand, car.Number, car.DriverID)
+	if car.Seats == 0 {
+		car.Seats = defaultCarSeats
+	}
+
+	query := `INSERT INTO cars (id, model, brand, number, seats, driver_id) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := c.db.ExecContext(ctx, query, uid, car.Model, car.Brand, car.Number, car.Seats, car.DriverID)
 	if err != nil {
 		fmt.Println("error while inserting data ", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return uid, nil
 
 }
 
-func (c carRepo) Get(id string) (models.Car, error) {
-	car := models.Car{}
-	query := `
-		SELECT
-			c.id,
-			c.model,
-			c.brand,
-			c.number,
-			c.status,
-			d.full_name AS driver_full_name,
-			d.phone AS driver_phone,
-			d.from_city_id AS driver_from_city_id,
-			d.to_city_id AS driver_to_city_id,
-			d.created_at AS driver_created_at
-		FROM
-			cars c
-		JOIN
-			drivers d ON c.driver_id = d.id
-		WHERE
-			c.id = $1;
-	`
-
-	if err := c.db.QueryRow(query, id).Scan(
-		&car.ID,
-		&car.Model,
-		&car.Brand,
-		&car.Number,
-		&car.Status,
-		&car.DriverData.FullName,
-		&car.DriverData.Phone,
-		&car.DriverData.FromCityID,
-		&car.DriverData.ToCityID,
-		&car.DriverData.CreatedAt,
-	); err != nil {
-		fmt.Println("error while scanning user ", err.Error())
-		return models.Car{}, err
-	}
-
-	return car, nil
-}
-
-func (c carRepo) GetList(req models.GetListRequest) (models.CarsResponse, error) {
-	query := `
+const carSelect = `
         SELECT
             cars.id,
             cars.model,
@@ -83,6 +47,7 @@ func (c carRepo) GetList(req models.GetListRequest) (models.CarsResponse, error)
             cars.number,
             cars.driver_id,
             cars.status,
+            cars.seats,
             cars.created_at,
             drivers.full_name AS driver_name,
             drivers.phone AS driver_phone,
@@ -92,48 +57,97 @@ func (c carRepo) GetList(req models.GetListRequest) (models.CarsResponse, error)
         FROM
             cars
         JOIN
-            drivers ON cars.driver_id = drivers.id;
+            drivers ON cars.driver_id = drivers.id
     `
 
-	rows, err := c.db.Query(query)
+func scanCar(row rowScanner) (models.Car, error) {
+	var car models.Car
+
+	err := row.Scan(
+		&car.ID,
+		&car.Model,
+		&car.Brand,
+		&car.Number,
+		&car.DriverID,
+		&car.Status,
+		&car.Seats,
+		&car.CreatedAt,
+		&car.DriverData.FullName,
+		&car.DriverData.Phone,
+		&car.DriverData.FromCityID,
+		&car.DriverData.ToCityID,
+		&car.DriverData.CreatedAt,
+	)
+
+	return car, dbError(err)
+}
+
+func (c carRepo) Get(ctx context.Context, id string) (models.Car, error) {
+	car, err := scanCar(c.db.QueryRowContext(ctx, carSelect+` WHERE cars.id = $1`, id))
 	if err != nil {
-		return models.CarsResponse{}, fmt.Errorf("error executing SQL query: %v", err)
+		fmt.Println("error while scanning car ", err.Error())
+		return models.Car{}, dbError(err)
 	}
-	defer rows.Close()
 
-	var cars []models.Car
-	for rows.Next() {
-		var car models.Car
-		err := rows.Scan(
-			&car.ID,
-			&car.Model,
-			&car.Brand,
-			&car.Number,
-			&car.DriverID,
-			&car.Status,
-			&car.CreatedAt,
-			&car.DriverData.FullName,
-			&car.DriverData.Phone,
-			&car.DriverData.FromCityID,
-			&car.DriverData.ToCityID,
-			&car.DriverData.CreatedAt,
-		)
-		if err != nil {
-			return models.CarsResponse{}, fmt.Errorf("error scanning rows: %v", err)
-		}
+	return car, nil
+}
 
-		cars = append(cars, car)
+// carColumns are the fields the list of cars can be filtered and sorted by
+var carColumns = listColumns{
+	"id":         "cars.id",
+	"model":      "cars.model",
+	"brand":      "cars.brand",
+	"number":     "cars.number",
+	"status":     "cars.status",
+	"seats":      "cars.seats",
+	"driver_id":  "cars.driver_id",
+	"created_at": "cars.created_at",
+}
+
+// carSearch are the columns the search of cars looks in
+var carSearch = []string{"cars.model", "cars.brand", "cars.number"}
+
+func (c carRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CarsResponse, error) {
+	args := []interface{}{}
+
+	conditions, err := listFilter(req.ListQuery, carColumns, carSearch, &args)
+	if err != nil {
+		return models.CarsResponse{}, err
+	}
+
+	orderBy, err := listOrder(req.Sort, carColumns, byCreatedAt)
+	if err != nil {
+		return models.CarsResponse{}, err
 	}
 
 	countQuery := `
         SELECT COUNT(*)
         FROM cars
         JOIN drivers ON cars.driver_id = drivers.id
-    `
+    ` + where(conditions)
 	var count int
-	err = c.db.QueryRow(countQuery).Scan(&count)
+	err = c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count)
 	if err != nil {
-		return models.CarsResponse{}, fmt.Errorf("error executing COUNT query: %v", err)
+		return models.CarsResponse{}, dbError(fmt.Errorf("error executing COUNT query: %w", err))
+	}
+
+	args = append(args, req.Limit, (req.Page-1)*req.Limit)
+	query := carSelect + where(conditions) + orderBy + fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.CarsResponse{}, dbError(fmt.Errorf("error executing SQL query: %w", err))
+	}
+	defer rows.Close()
+
+	cars := []models.Car{}
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return models.CarsResponse{}, dbError(fmt.Errorf("error scanning rows: %w", err))
+		}
+
+		cars = append(cars, car)
 	}
 
 	return models.CarsResponse{
@@ -142,40 +156,78 @@ func (c carRepo) GetList(req models.GetListRequest) (models.CarsResponse, error)
 	}, nil
 }
 
-func (c carRepo) Update(car models.Car) (string, error) {
+func (c carRepo) Update(ctx context.Context, car models.Car) (string, error) {
 	query := `
 	UPDATE cars
-    SET model = $1, brand = $2, number = $3, driver_id = $4 
-    WHERE id = $5;
+    SET model = $1, brand = $2, number = $3, seats = COALESCE(NULLIF($4, 0), seats), driver_id = $5 
+    WHERE id = $6;
 	`
-	if _, err := c.db.Exec(query, car.Model, car.Brand, car.Number, car.DriverID, car.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, car.Model, car.Brand, car.Number, car.Seats, car.DriverID, car.ID); err != nil {
 		fmt.Println("error while updating car data ", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return car.ID, nil
 }
 
-func (c carRepo) Delete(id string) error {
+func (c carRepo) Delete(ctx context.Context, id string) error {
 
 	query := `delete from cars where id = $1`
 
-	if _, err := c.db.Exec(query, id); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, id); err != nil {
 		fmt.Println("error while deleting car by id ", err.Error())
-		return err
+		return dbError(err)
 	}
 	return nil
 
 }
 
-
-func (c carRepo) UpdateCarStatus(updateCarStatus models.UpdateCarStatus) error {
+func (c carRepo) UpdateCarStatus(ctx context.Context, updateCarStatus models.UpdateCarStatus) error {
 	query := `update cars set status = $1 where id = $2`
 
-	if _, err := c.db.Exec(query, updateCarStatus.Status, updateCarStatus.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, updateCarStatus.Status, updateCarStatus.ID); err != nil {
 		fmt.Println("error while updating car status ", err.Error())
-		return err
+		return dbError(err)
 	}
 
 	return nil
 }
+
+// Stream calls fn for every car matching the filters and the search of q in
+// the order of the list, rows are read one by one
+func (c carRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Car) error) error {
+	args := []interface{}{}
+
+	conditions, err := listFilter(q, carColumns, carSearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(q.Sort, carColumns, byCreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, carSelect+where(conditions)+orderBy, args...)
+	if err != nil {
+		return dbError(fmt.Errorf("error executing SQL query: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return dbError(fmt.Errorf("error scanning rows: %w", err))
+		}
+
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(car); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
@@ -3,58 +3,68 @@ package postgres
 import (
 	"city2city/api/models"
 	"city2city/storage"
-	"database/sql"
+	"context"
 	"fmt"
 
 	"github.com/google/uuid"
 )
 
 type cityRepo struct {
-	db *sql.DB
+	db conn
 }
 
-func NewCityRepo(db *sql.DB) storage.ICityRepo {
+func NewCityRepo(db conn) storage.ICityRepo {
 	return cityRepo{
 		db,
 	}
 }
 
-func (c cityRepo) Create(city models.CreateCity) (string, error) {
+func (c cityRepo) Create(ctx context.Context, city models.CreateCity) (string, error) {
 	uid := uuid.New()
 
 	query := `
      insert into cities values ($1, $2) 
    `
 
-	if _, err := c.db.Exec(query, uid, city.Name); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, uid, city.Name); err != nil {
 		fmt.Println("error while inserting data", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return uid.String(), nil
 
 }
 
-func (c cityRepo) Get(id string) (models.City, error) {
+func (c cityRepo) Get(ctx context.Context, id string) (models.City, error) {
 
 	city := models.City{}
 
 	query := `select id, name, created_at from cities where id = $1`
 
-	if err := c.db.QueryRow(query, id).Scan(
+	if err := c.db.QueryRowContext(ctx, query, id).Scan(
 		&city.ID,
 		&city.Name,
 		&city.CreatedAt,
 	); err != nil {
 		fmt.Println("error while scanning city", err.Error())
-		return models.City{}, err
+		return models.City{}, dbError(err)
 	}
 
 	return city, nil
 
 }
 
-func (c cityRepo) GetList(req models.GetListRequest) (models.CitiesResponse, error) {
+// cityColumns are the fields the list of cities can be filtered and sorted by
+var cityColumns = listColumns{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+// citySearch are the columns the search of cities looks in
+var citySearch = []string{"name"}
+
+func (c cityRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CitiesResponse, error) {
 
 	var (
 		cities            = []models.City{}
@@ -62,28 +72,41 @@ func (c cityRepo) GetList(req models.GetListRequest) (models.CitiesResponse, err
 		countQuery, query string
 		page              = req.Page
 		offset            = (page - 1) * req.Limit
+		args              = []interface{}{}
 	)
 
-	countQuery = `select count(1) from cities`
+	conditions, err := listFilter(req.ListQuery, cityColumns, citySearch, &args)
+	if err != nil {
+		return models.CitiesResponse{}, err
+	}
+
+	orderBy, err := listOrder(req.Sort, cityColumns, byCreatedAt)
+	if err != nil {
+		return models.CitiesResponse{}, err
+	}
+
+	countQuery = `select count(1) from cities` + where(conditions)
 
-	if err := c.db.QueryRow(countQuery).Scan(&count); err != nil {
+	if err := c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
 		fmt.Println("error while scanning count of cities", err.Error())
-		return models.CitiesResponse{}, err
+		return models.CitiesResponse{}, dbError(err)
 	}
 
 	query = `
 	select id, 
 	name,
 	 created_at from cities
-	`
+	` + where(conditions) + orderBy
 
-	query += ` limit $1 offset $2`
+	args = append(args, req.Limit, offset)
+	query += fmt.Sprintf(` limit $%d offset $%d`, len(args)-1, len(args))
 
-	rows, err := c.db.Query(query, req.Limit, offset)
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		fmt.Println("error while query rows", err.Error())
-		return models.CitiesResponse{}, err
+		return models.CitiesResponse{}, dbError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		city := models.City{}
@@ -94,7 +117,7 @@ func (c cityRepo) GetList(req models.GetListRequest) (models.CitiesResponse, err
 			&city.CreatedAt,
 		); err != nil {
 			fmt.Println("error while scanning row", err.Error())
-			return models.CitiesResponse{}, err
+			return models.CitiesResponse{}, dbError(err)
 		}
 
 		cities = append(cities, city)
@@ -107,33 +130,81 @@ func (c cityRepo) GetList(req models.GetListRequest) (models.CitiesResponse, err
 	}, nil
 }
 
-func (c cityRepo) Update(city models.City) (string, error) {
+func (c cityRepo) Update(ctx context.Context, city models.City) (string, error) {
 
 	query := `update
 	cities 
 	set name = $1 where 
 	id = $2`
 
-	if _, err := c.db.Exec(query, city.Name, city.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, city.Name, city.ID); err != nil {
 		fmt.Println("error while updating city data ", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return city.ID, nil
 
 }
 
-func (c cityRepo) Delete(id string) error {
+func (c cityRepo) Delete(ctx context.Context, id string) error {
 
 	query := `
      delete from cities 
      where id = $1 
   `
-	if _, err := c.db.Exec(query, id); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, id); err != nil {
 		fmt.Println("error while deleeting city by id", err.Error())
-		return err
+		return dbError(err)
 	}
 
 	return nil
 
 }
+
+// Stream calls fn for every city matching the filters and the search of q
+// in the order of its sort, by name when it has none. Rows are read one by
+// one.
+func (c cityRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.City) error) error {
+	args := []interface{}{}
+
+	conditions, err := listFilter(q, cityColumns, citySearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(q.Sort, cityColumns, []models.SortField{{Field: "name"}})
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `select id, name, created_at from cities`+where(conditions)+orderBy, args...)
+	if err != nil {
+		fmt.Println("error while query rows", err.Error())
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		city := models.City{}
+
+		if err = rows.Scan(
+			&city.ID,
+			&city.Name,
+			&city.CreatedAt,
+		); err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return dbError(err)
+		}
+
+		// rows already read keep coming after the context is done
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(city); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
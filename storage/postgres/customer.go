@@ -3,27 +3,27 @@ package postgres
 import (
 	"city2city/api/models"
 	"city2city/storage"
-	"database/sql"
+	"context"
 	"fmt"
 
 	"github.com/google/uuid"
 )
 
 type customerRepo struct {
-	db *sql.DB
+	db conn
 }
 
-func NewCustomerRepo(db *sql.DB) storage.ICustomerRepo {
+func NewCustomerRepo(db conn) storage.ICustomerRepo {
 	return customerRepo{
 		db,
 	}
 }
 
-func (c customerRepo) Create(customer models.CreateCustomer) (string, error) {
+func (c customerRepo) Create(ctx context.Context, customer models.CreateCustomer) (string, error) {
 
 	uid := uuid.New()
 
-	if _, err := c.db.Exec(`
+	if _, err := c.db.ExecContext(ctx, `
 	 insert into customers values ($1, $2, $3, $4)
 	 `,
 		uid,
@@ -32,21 +32,21 @@ func (c customerRepo) Create(customer models.CreateCustomer) (string, error) {
 		customer.Email,
 	); err != nil {
 		fmt.Println("error while inserting data", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return uid.String(), nil
 
 }
 
-func (c customerRepo) Get(id string) (models.Customer, error) {
+func (c customerRepo) Get(ctx context.Context, id string) (models.Customer, error) {
 
 	customer := models.Customer{}
 
 	query := `
 		select id, full_name, phone, email, created_at from customers where id = $1
 `
-	if err := c.db.QueryRow(query, id).Scan(
+	if err := c.db.QueryRowContext(ctx, query, id).Scan(
 		&customer.ID,
 		&customer.FullName,
 		&customer.Phone,
@@ -54,14 +54,27 @@ func (c customerRepo) Get(id string) (models.Customer, error) {
 		&customer.CreatedAt,
 	); err != nil {
 		fmt.Println("error while scanning user", err.Error())
-		return models.Customer{}, err
+		return models.Customer{}, dbError(err)
 	}
 
 	return customer, nil
 
 }
 
-func (c customerRepo) GetList(req models.GetListRequest) (models.CustomersResponse, error) {
+// customerColumns are the fields the list of customers can be filtered and
+// sorted by
+var customerColumns = listColumns{
+	"id":         "id",
+	"full_name":  "full_name",
+	"phone":      "phone",
+	"email":      "email",
+	"created_at": "created_at",
+}
+
+// customerSearch are the columns the search of customers looks in
+var customerSearch = []string{"full_name", "phone", "email"}
+
+func (c customerRepo) GetList(ctx context.Context, req models.GetListRequest) (models.CustomersResponse, error) {
 
 	var (
 		customers         = []models.Customer{}
@@ -69,28 +82,41 @@ func (c customerRepo) GetList(req models.GetListRequest) (models.CustomersRespon
 		countQuery, query string
 		page              = req.Page
 		offset            = (page - 1) * req.Limit
+		args              = []interface{}{}
 	)
 
+	conditions, err := listFilter(req.ListQuery, customerColumns, customerSearch, &args)
+	if err != nil {
+		return models.CustomersResponse{}, err
+	}
+
+	orderBy, err := listOrder(req.Sort, customerColumns, byCreatedAt)
+	if err != nil {
+		return models.CustomersResponse{}, err
+	}
+
 	countQuery = `
-	SELECT count(1) from customers `
+	SELECT count(1) from customers ` + where(conditions)
 
-	if err := c.db.QueryRow(countQuery).Scan(&count); err != nil {
+	if err := c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
 		fmt.Println("error while scanning count of users", err.Error())
-		return models.CustomersResponse{}, err
+		return models.CustomersResponse{}, dbError(err)
 	}
 
 	query = `
 	SELECT id, full_name, phone, email, created_at
 		FROM customers
-			`
+			` + where(conditions) + orderBy
 
-	query += ` LIMIT $1 OFFSET $2`
+	args = append(args, req.Limit, offset)
+	query += fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
 
-	rows, err := c.db.Query(query, req.Limit, offset)
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		fmt.Println("error while query rows", err.Error())
-		return models.CustomersResponse{}, err
+		return models.CustomersResponse{}, dbError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		customer := models.Customer{}
@@ -103,7 +129,7 @@ func (c customerRepo) GetList(req models.GetListRequest) (models.CustomersRespon
 			&customer.CreatedAt,
 		); err != nil {
 			fmt.Println("error while scanning row", err.Error())
-			return models.CustomersResponse{}, err
+			return models.CustomersResponse{}, dbError(err)
 		}
 
 		customers = append(customers, customer)
@@ -116,30 +142,78 @@ func (c customerRepo) GetList(req models.GetListRequest) (models.CustomersRespon
 
 }
 
-func (c customerRepo) Update(customer models.Customer) (string, error) {
+func (c customerRepo) Update(ctx context.Context, customer models.Customer) (string, error) {
 	query := `
 	update customers 
 		set full_name = $1, phone = $2, email = $3
 			where id = $4`
 
-	if _, err := c.db.Exec(query, customer.FullName, customer.Phone, customer.Email, customer.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, customer.FullName, customer.Phone, customer.Email, customer.ID); err != nil {
 		fmt.Println("error while updating customer data", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return customer.ID, nil
 
 }
 
-func (c customerRepo) Delete(id string) error {
+func (c customerRepo) Delete(ctx context.Context, id string) error {
 	query := `
 	delete from customers
 		where id = $1
 `
-	if _, err := c.db.Exec(query, id); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, id); err != nil {
 		fmt.Println("error while deleting customer by id", err.Error())
-		return err
+		return dbError(err)
 	}
 
 	return nil
 }
+
+// Stream calls fn for every customer matching the filters and the search of
+// q in the order of the list, rows are read one by one
+func (c customerRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Customer) error) error {
+	args := []interface{}{}
+
+	conditions, err := listFilter(q, customerColumns, customerSearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(q.Sort, customerColumns, byCreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `SELECT id, full_name, phone, email, created_at FROM customers`+where(conditions)+orderBy, args...)
+	if err != nil {
+		fmt.Println("error while query rows", err.Error())
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		customer := models.Customer{}
+
+		if err = rows.Scan(
+			&customer.ID,
+			&customer.FullName,
+			&customer.Phone,
+			&customer.Email,
+			&customer.CreatedAt,
+		); err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return dbError(err)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(customer); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
@@ -3,7 +3,7 @@ package postgres
 import (
 	"city2city/api/models"
 	"city2city/storage"
-	"database/sql"
+	"context"
 	"fmt"
 	"time"
 
@@ -11,52 +11,53 @@ import (
 )
 
 type driverRepo struct {
-	DB *sql.DB
+	DB conn
 }
 
-func NewDriverRepo(db *sql.DB) storage.IDriverRepo {
+func NewDriverRepo(db conn) storage.IDriverRepo {
 	return driverRepo{
 		DB: db,
 	}
 }
 
-func (d driverRepo) Create(driver models.CreateDriver) (string, error) {
+func (d driverRepo) Create(ctx context.Context, driver models.CreateDriver) (string, error) {
 	id := uuid.New()
 	createdAt := time.Now()
 
-	if _, err := d.DB.Exec(`INSERT INTO drivers VALUES ($1, $2, $3, $4, $5, $6)`,
+	if _, err := d.DB.ExecContext(ctx, `INSERT INTO drivers VALUES ($1, $2, $3, $4, $5, $6)`,
 		id, driver.FullName, driver.Phone, driver.FromCityID, driver.ToCityID, createdAt); err != nil {
 		fmt.Println("error while inserting data", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 	return id.String(), nil
 }
 
-func (d driverRepo) Get(pkey models.PrimaryKey) (models.Driver, error) {
-	driver := models.Driver{}
-	if err := d.DB.QueryRow(`
-        SELECT
-            drivers.id,
-            drivers.full_name,
-            drivers.phone,
+const driverSelect = `
+		SELECT
+			drivers.id,
+			drivers.full_name,
+			drivers.phone,
 			drivers.from_city_id,
 			cities_from.id AS from_city_data_id,
-            cities_from.name AS from_city_data_name,
+			cities_from.name AS from_city_data_name,
 			cities_from.created_at AS from_city_data_created_at,
 			drivers.to_city_id ,
 			cities_to.id AS to_city_data_id,
-            cities_to.name AS to_city_data_name,
+			cities_to.name AS to_city_data_name,
 			cities_to.created_at AS to_city_data_created_at,
 			drivers.created_at
-        FROM
-            drivers
-        LEFT JOIN
-            cities AS cities_from ON drivers.from_city_id = cities_from.id
-        LEFT JOIN
-            cities AS cities_to ON drivers.to_city_id = cities_to.id
-        WHERE
-            drivers.id = $1
-    `, pkey.ID).Scan(
+		FROM
+			drivers
+		LEFT JOIN
+			cities AS cities_from ON drivers.from_city_id = cities_from.id
+		LEFT JOIN
+			cities AS cities_to ON drivers.to_city_id = cities_to.id
+	`
+
+func scanDriver(row rowScanner) (models.Driver, error) {
+	driver := models.Driver{}
+
+	err := row.Scan(
 		&driver.ID,
 		&driver.FullName,
 		&driver.Phone,
@@ -69,79 +70,80 @@ func (d driverRepo) Get(pkey models.PrimaryKey) (models.Driver, error) {
 		&driver.ToCityData.Name,
 		&driver.ToCityData.CreatedAt,
 		&driver.CreatedAt,
-	); err != nil {
+	)
+
+	return driver, dbError(err)
+}
+
+func (d driverRepo) Get(ctx context.Context, pkey models.PrimaryKey) (models.Driver, error) {
+	driver, err := scanDriver(d.DB.QueryRowContext(ctx, driverSelect+`
+        WHERE
+            drivers.id = $1
+    `, pkey.ID))
+	if err != nil {
 		fmt.Println("error while querying driver by ID", err.Error())
-		return models.Driver{}, err
+		return models.Driver{}, dbError(err)
 	}
 
 	return driver, nil
 }
 
-func (d driverRepo) GetList(request models.GetListRequest) (models.DriversResponse, error) {
+// driverColumns are the fields the list of drivers can be filtered and
+// sorted by
+var driverColumns = listColumns{
+	"id":           "drivers.id",
+	"full_name":    "drivers.full_name",
+	"phone":        "drivers.phone",
+	"from_city_id": "drivers.from_city_id",
+	"to_city_id":   "drivers.to_city_id",
+	"created_at":   "drivers.created_at",
+}
+
+// driverSearch are the columns the search of drivers looks in
+var driverSearch = []string{"drivers.full_name", "drivers.phone"}
+
+func (d driverRepo) GetList(ctx context.Context, request models.GetListRequest) (models.DriversResponse, error) {
 	var (
 		drivers = []models.Driver{}
 		count   = 0
 		query   string
+		args    = []interface{}{}
 	)
 
+	conditions, err := listFilter(request.ListQuery, driverColumns, driverSearch, &args)
+	if err != nil {
+		return models.DriversResponse{}, err
+	}
+
+	orderBy, err := listOrder(request.Sort, driverColumns, byCreatedAt)
+	if err != nil {
+		return models.DriversResponse{}, err
+	}
+
 	countQuery := `
 		SELECT count(1) FROM drivers
-	`
+	` + where(conditions)
 
-	if err := d.DB.QueryRow(countQuery).Scan(&count); err != nil {
+	if err := d.DB.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
 		fmt.Println("error while scanning count of drivers", err.Error())
-		return models.DriversResponse{}, err
+		return models.DriversResponse{}, dbError(err)
 	}
 
-	query = `
-		SELECT
-			drivers.id,
-			drivers.full_name,
-			drivers.phone,
-			drivers.from_city_id,
-			cities_from.id AS from_city_data_id,
-			cities_from.name AS from_city_data_name,
-			cities_from.created_at AS from_city_data_created_at,
-			drivers.to_city_id ,
-			cities_to.id AS to_city_data_id,
-			cities_to.name AS to_city_data_name,
-			cities_to.created_at AS to_city_data_created_at,
-			drivers.created_at
-		FROM
-			drivers
-		LEFT JOIN
-			cities AS cities_from ON drivers.from_city_id = cities_from.id
-		LEFT JOIN
-			cities AS cities_to ON drivers.to_city_id = cities_to.id
-	`
-
-	query += ` LIMIT $1 OFFSET $2`
+	args = append(args, request.Limit, (request.Page-1)*request.Limit)
+	query = driverSelect + where(conditions) + orderBy + fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
 
-	rows, err := d.DB.Query(query, request.Limit, (request.Page-1)*request.Limit)
+	rows, err := d.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		fmt.Println("error while querying rows", err.Error())
-		return models.DriversResponse{}, err
+		return models.DriversResponse{}, dbError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var driver models.Driver
-
-		if err = rows.Scan(
-			&driver.ID,
-			&driver.FullName,
-			&driver.Phone,
-			&driver.FromCityID,
-			&driver.FromCityData.ID,
-			&driver.FromCityData.Name,
-			&driver.FromCityData.CreatedAt,
-			&driver.ToCityID,
-			&driver.ToCityData.ID,
-			&driver.ToCityData.Name,
-			&driver.ToCityData.CreatedAt,
-			&driver.CreatedAt,
-		); err != nil {
+		driver, err := scanDriver(rows)
+		if err != nil {
 			fmt.Println("error while scanning row:", err)
-			return models.DriversResponse{}, err
+			return models.DriversResponse{}, dbError(err)
 		}
 
 		drivers = append(drivers, driver)
@@ -153,25 +155,66 @@ func (d driverRepo) GetList(request models.GetListRequest) (models.DriversRespon
 	}, nil
 }
 
-func (d driverRepo) Update(request models.Driver) (string, error) {
+// Stream calls fn for every driver matching the filters and the search of q
+// in the order of the list, rows are read one by one
+func (d driverRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.Driver) error) error {
+	args := []interface{}{}
+
+	conditions, err := listFilter(q, driverColumns, driverSearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(q.Sort, driverColumns, byCreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := d.DB.QueryContext(ctx, driverSelect+where(conditions)+orderBy, args...)
+	if err != nil {
+		fmt.Println("error while querying rows", err.Error())
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		driver, err := scanDriver(rows)
+		if err != nil {
+			fmt.Println("error while scanning row:", err)
+			return dbError(err)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(driver); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
+
+func (d driverRepo) Update(ctx context.Context, request models.Driver) (string, error) {
 
 	query := `UPDATE drivers SET full_name = $1, phone = $2, from_city_id = $3, to_city_id = $4 WHERE id = $5`
 
-	if _, err := d.DB.Exec(query, request.FullName, request.Phone, request.FromCityID, request.ToCityID, request.ID); err != nil {
+	if _, err := d.DB.ExecContext(ctx, query, request.FullName, request.Phone, request.FromCityID, request.ToCityID, request.ID); err != nil {
 		fmt.Println("error while updating driver data", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 
 	return request.ID, nil
 }
 
-func (d driverRepo) Delete(request models.PrimaryKey) error {
+func (d driverRepo) Delete(ctx context.Context, request models.PrimaryKey) error {
 
 	query := `DELETE FROM drivers WHERE id = $1`
 
-	if _, err := d.DB.Exec(query, request.ID); err != nil {
+	if _, err := d.DB.ExecContext(ctx, query, request.ID); err != nil {
 		fmt.Println("error while deleting driver by ID", err.Error())
-		return err
+		return dbError(err)
 	}
 
 	return nil
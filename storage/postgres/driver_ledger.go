@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type driverLedgerRepo struct {
+	db conn
+}
+
+func NewDriverLedgerRepo(db conn) storage.IDriverLedgerRepo {
+	return driverLedgerRepo{
+		db: db,
+	}
+}
+
+// ledgerBalance sums the ledger of driver $1 for entries matching the extra condition
+const ledgerBalance = `
+	SELECT
+		COALESCE(SUM(amount) FILTER (WHERE kind = 'credit'), 0),
+		COALESCE(SUM(amount) FILTER (WHERE kind = 'payout'), 0)
+	FROM driver_ledger
+	WHERE driver_id = $1
+`
+
+func (d driverLedgerRepo) CreatePayout(ctx context.Context, req models.CreatePayout) (string, error) {
+	uid := uuid.New()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// the driver row lock keeps two payouts from spending the same balance
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM drivers WHERE id = $1 FOR UPDATE`, req.DriverID); err != nil {
+		tx.Rollback()
+		fmt.Println("error while locking driver", err.Error())
+		return "", dbError(err)
+	}
+
+	credited, paidOut := 0, 0
+	if err := tx.QueryRowContext(ctx, ledgerBalance, req.DriverID).Scan(&credited, &paidOut); err != nil {
+		tx.Rollback()
+		fmt.Println("error while scanning driver balance", err.Error())
+		return "", dbError(err)
+	}
+
+	if req.Amount > credited-paidOut {
+		tx.Rollback()
+		return "", storage.ErrInsufficientBalance
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO driver_ledger (id, driver_id, kind, amount, description)
+		VALUES ($1, $2, $3, $4, $5)
+		`, uid, req.DriverID, models.LedgerPayout, req.Amount, req.Description,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error while inserting payout", err.Error())
+		return "", dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return uid.String(), nil
+}
+
+func (d driverLedgerRepo) GetBalance(ctx context.Context, driverID string) (models.DriverBalance, error) {
+	balance := models.DriverBalance{
+		DriverID: driverID,
+	}
+
+	if err := d.db.QueryRowContext(ctx, ledgerBalance, driverID).Scan(&balance.Credited, &balance.PaidOut); err != nil {
+		fmt.Println("error while scanning driver balance", err.Error())
+		return models.DriverBalance{}, dbError(err)
+	}
+
+	balance.Balance = balance.Credited - balance.PaidOut
+
+	return balance, nil
+}
+
+func (d driverLedgerRepo) GetStatement(ctx context.Context, req models.GetStatementRequest) (models.DriverStatement, error) {
+	statement := models.DriverStatement{
+		DriverID: req.DriverID,
+		From:     req.From,
+		To:       req.To,
+		Entries:  []models.LedgerEntry{},
+	}
+
+	credited, paidOut := 0, 0
+	if err := d.db.QueryRowContext(ctx, ledgerBalance+` AND created_at < $2`, req.DriverID, req.From).Scan(&credited, &paidOut); err != nil {
+		fmt.Println("error while scanning opening balance", err.Error())
+		return models.DriverStatement{}, dbError(err)
+	}
+	statement.OpeningBalance = credited - paidOut
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, driver_id, trip_id, kind, amount, commission, COALESCE(description, ''), created_at
+		FROM driver_ledger
+		WHERE driver_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at
+	`, req.DriverID, req.From, req.To)
+	if err != nil {
+		fmt.Println("error while querying ledger entries", err.Error())
+		return models.DriverStatement{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := models.LedgerEntry{}
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.DriverID,
+			&entry.TripID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.Commission,
+			&entry.Description,
+			&entry.CreatedAt,
+		); err != nil {
+			fmt.Println("error while scanning ledger entry", err.Error())
+			return models.DriverStatement{}, dbError(err)
+		}
+
+		if entry.Kind == models.LedgerCredit {
+			statement.Credited += entry.Amount
+		} else {
+			statement.PaidOut += entry.Amount
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	statement.ClosingBalance = statement.OpeningBalance + statement.Credited - statement.PaidOut
+
+	return statement, nil
+}
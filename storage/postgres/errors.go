@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgres error codes, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+	pqNotNullViolation    = "23502"
+	pqInvalidText         = "22P02"
+	pqStringTooLong       = "22001"
+	pqInvalidDatetime     = "22007"
+	pqQueryCanceled       = "57014"
+)
+
+// dbError turns the errors of the database into storage errors, so the api
+// can tell a missing row or a duplicate from a failure. Other errors are
+// returned as they are.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var storageErr *storage.Error
+	if errors.As(err, &storageErr) {
+		return err
+	}
+
+	// a query stopped by the deadline of its context fails with the error
+	// of the cancelled statement or with the error of the context
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timeoutError(err)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &storage.Error{Kind: storage.KindNotFound, Code: "not_found", Message: "not found", Err: err}
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		return &storage.Error{
+			Kind:    storage.KindConflict,
+			Code:    "already_exists",
+			Message: constraintMessage(pqErr, "already exists"),
+			Err:     err,
+		}
+	case pqForeignKeyViolation:
+		return &storage.Error{
+			Kind:    storage.KindForeignKey,
+			Code:    "foreign_key",
+			Message: constraintMessage(pqErr, "references a missing or used row"),
+			Err:     err,
+		}
+	case pqCheckViolation, pqNotNullViolation, pqStringTooLong:
+		return &storage.Error{
+			Kind:    storage.KindValidation,
+			Code:    "invalid_value",
+			Message: constraintMessage(pqErr, "is not valid"),
+			Err:     err,
+		}
+	case pqQueryCanceled:
+		return timeoutError(err)
+	case pqInvalidText, pqInvalidDatetime:
+		return &storage.Error{Kind: storage.KindValidation, Code: "invalid_value", Message: pqErr.Message, Err: err}
+	}
+
+	return err
+}
+
+func timeoutError(err error) error {
+	return &storage.Error{Kind: storage.KindTimeout, Code: "timeout", Message: "the database took too long to answer", Err: err}
+}
+
+// constraintMessage names the column or constraint that failed, e.g.
+// "phone already exists" for the customers_phone_key constraint
+func constraintMessage(pqErr *pq.Error, message string) string {
+	name := pqErr.Column
+	if name == "" {
+		name = strings.TrimPrefix(pqErr.Constraint, pqErr.Table+"_")
+		name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_key"), "_fkey"), "_check")
+	}
+
+	if name == "" {
+		return pqErr.Message
+	}
+
+	return name + " " + message
+}
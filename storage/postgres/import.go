@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type importRepo struct {
+	db conn
+}
+
+func NewImportRepo(db conn) storage.IImportRepo {
+	return importRepo{
+		db: db,
+	}
+}
+
+// Import inserts all the rows in one transaction. Every row runs in its own
+// savepoint, so a failed row does not abort the transaction and the rest
+// are still checked. The transaction is committed only when no row failed
+// and it is not a dry run.
+func (i importRepo) Import(ctx context.Context, req models.Import) (models.ImportResult, error) {
+	result := models.ImportResult{DryRun: req.DryRun}
+
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ImportResult{}, fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	addError := func(file string, line int, err error) {
+		result.Errors = append(result.Errors, models.ImportError{File: file, Line: line, Error: err.Error()})
+	}
+
+	// city names are matched case insensitive, the ones created by this
+	// import are added so drivers can use them right away
+	cities := map[string]string{}
+	rows, err := tx.QueryContext(ctx, `SELECT id, name FROM cities`)
+	if err != nil {
+		tx.Rollback()
+		fmt.Println("error is while selecting cities", err.Error())
+		return models.ImportResult{}, dbError(err)
+	}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			tx.Rollback()
+			fmt.Println("error is while scanning cities", err.Error())
+			return models.ImportResult{}, dbError(err)
+		}
+		cities[strings.ToLower(name)] = id
+	}
+	rows.Close()
+
+	for _, city := range req.Cities {
+		if _, ok := cities[strings.ToLower(city.Name)]; ok {
+			continue
+		}
+
+		id := uuid.New().String()
+		if err := insertRow(ctx, tx, `INSERT INTO cities (id, name) VALUES ($1, $2)`, id, city.Name); err != nil {
+			addError(models.ImportCities, city.Line, err)
+			continue
+		}
+
+		cities[strings.ToLower(city.Name)] = id
+		result.Cities++
+	}
+
+	// drivers created by this import are found by phone for the cars
+	drivers := map[string]string{}
+	for _, driver := range req.Drivers {
+		fromCityID, ok := cities[strings.ToLower(driver.FromCity)]
+		if !ok {
+			addError(models.ImportDrivers, driver.Line, fmt.Errorf("unknown city %s", driver.FromCity))
+			continue
+		}
+
+		toCityID, ok := cities[strings.ToLower(driver.ToCity)]
+		if !ok {
+			addError(models.ImportDrivers, driver.Line, fmt.Errorf("unknown city %s", driver.ToCity))
+			continue
+		}
+
+		id := uuid.New().String()
+		if err := insertRow(ctx, tx, `INSERT INTO drivers (id, full_name, phone, from_city_id, to_city_id) VALUES ($1, $2, $3, $4, $5)`,
+			id, driver.FullName, driver.Phone, fromCityID, toCityID,
+		); err != nil {
+			addError(models.ImportDrivers, driver.Line, err)
+			continue
+		}
+
+		drivers[driver.Phone] = id
+		result.Drivers++
+	}
+
+	for _, car := range req.Cars {
+		driverID, ok := drivers[car.DriverPhone]
+		if !ok {
+			if err := tx.QueryRowContext(ctx, `SELECT id FROM drivers WHERE phone = $1`, car.DriverPhone).Scan(&driverID); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					tx.Rollback()
+					fmt.Println("error is while selecting driver", err.Error())
+					return models.ImportResult{}, dbError(err)
+				}
+
+				addError(models.ImportCars, car.Line, fmt.Errorf("unknown driver phone %s", car.DriverPhone))
+				continue
+			}
+		}
+
+		if car.Seats == 0 {
+			car.Seats = defaultCarSeats
+		}
+
+		if err := insertRow(ctx, tx, `INSERT INTO cars (id, model, brand, number, seats, driver_id) VALUES ($1, $2, $3, $4, $5, $6)`,
+			uuid.New().String(), car.Model, car.Brand, car.Number, car.Seats, driverID,
+		); err != nil {
+			addError(models.ImportCars, car.Line, err)
+			continue
+		}
+
+		result.Cars++
+	}
+
+	if req.DryRun || len(result.Errors) > 0 {
+		if err := tx.Rollback(); err != nil {
+			return models.ImportResult{}, fmt.Errorf("error rolling back transaction: %v", err)
+		}
+
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ImportResult{}, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return result, nil
+}
+
+// insertRow runs one insert inside a savepoint and rolls only it back when
+// it fails, the returned error is the one of the insert
+func insertRow(ctx context.Context, tx txn, query string, args ...interface{}) error {
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+		return dbError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); rbErr != nil {
+			return rbErr
+		}
+		return dbError(err)
+	}
+
+	_, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`)
+	return dbError(err)
+}
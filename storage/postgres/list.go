@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// listColumns are the fields a list can be filtered and sorted by with the
+// columns they are read from. Only these columns get into the query, the
+// values of filters and the search are always arguments.
+type listColumns map[string]string
+
+// likeEscaper escapes the wildcards of LIKE, so the search text matches as
+// it is
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// listFilter returns the conditions of the filters and the search of q and
+// appends their values to args. The search looks for the text in any of
+// the search columns, ignoring case.
+func listFilter(q models.ListQuery, columns listColumns, search []string, args *[]interface{}) ([]string, error) {
+	conditions := []string{}
+
+	// sorted so the same request makes the same query
+	fields := make([]string, 0, len(q.Filters))
+	for field := range q.Filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		column, ok := columns[field]
+		if !ok {
+			return nil, storage.UnknownFilterError(field)
+		}
+
+		*args = append(*args, q.Filters[field])
+		conditions = append(conditions, fmt.Sprintf(`%s = $%d`, column, len(*args)))
+	}
+
+	if q.Search != "" {
+		*args = append(*args, "%"+likeEscaper.Replace(q.Search)+"%")
+
+		matches := make([]string, 0, len(search))
+		for _, column := range search {
+			matches = append(matches, fmt.Sprintf(`%s ILIKE $%d`, column, len(*args)))
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	return conditions, nil
+}
+
+// where joins the conditions into a WHERE clause, empty without conditions
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+// byCreatedAt is the order of a list which is not sorted
+var byCreatedAt = []models.SortField{{Field: "created_at"}}
+
+// listOrder returns the ORDER BY clause of the sort fields, orderBy is used
+// when there are none. The id comes last, so rows which sort the same come
+// in the same order on every page.
+func listOrder(fields []models.SortField, columns listColumns, orderBy []models.SortField) (string, error) {
+	if len(fields) == 0 {
+		fields = orderBy
+	}
+
+	terms := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		column, ok := columns[field.Field]
+		if !ok {
+			return "", storage.UnknownSortError(field.Field)
+		}
+
+		if field.Desc {
+			column += ` DESC`
+		}
+		terms = append(terms, column)
+	}
+	terms = append(terms, columns["id"])
+
+	return ` ORDER BY ` + strings.Join(terms, `, `), nil
+}
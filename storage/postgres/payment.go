@@ -0,0 +1,256 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type paymentRepo struct {
+	db conn
+}
+
+func NewPaymentRepo(db conn) storage.IPaymentRepo {
+	return paymentRepo{
+		db: db,
+	}
+}
+
+// bookingPaymentStatus maps a payment status to the payment status its
+// booking gets, statuses which do not change the booking are missing
+var bookingPaymentStatus = map[string]string{
+	models.PaymentStatusCaptured: models.BookingPaid,
+	models.PaymentStatusRefunded: models.BookingRefunded,
+}
+
+func (p paymentRepo) Create(ctx context.Context, req models.CreatePayment) (string, error) {
+	uid := uuid.New()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// the lock on the booking makes concurrent payments of it wait for each
+	// other, so only one of them sees no active payment, and with a
+	// cancellation of it, so a cancelled booking is not paid
+	bookingStatus := ""
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM trip_customers WHERE id = $1 FOR UPDATE`, req.TripCustomerID).Scan(&bookingStatus); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", storage.NewError(storage.KindForeignKey, "foreign_key", "trip_customer_id references a missing or used row")
+		}
+		fmt.Println("error while locking booking", err.Error())
+		return "", dbError(err)
+	}
+
+	if bookingStatus == models.BookingStatusCancelled {
+		tx.Rollback()
+		return "", storage.ErrBookingCancelled
+	}
+
+	active := false
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM payments WHERE trip_customer_id = $1 AND status = ANY($2))
+		`, req.TripCustomerID, pq.Array(storage.ActivePaymentStatuses()),
+	).Scan(&active); err != nil {
+		tx.Rollback()
+		fmt.Println("error while checking payments of booking", err.Error())
+		return "", dbError(err)
+	}
+
+	if active {
+		tx.Rollback()
+		return "", storage.ErrPaymentExists
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO payments (id, trip_customer_id, provider, amount, currency, status, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, uid, req.TripCustomerID, req.Provider, req.Amount, req.Currency, req.Status, req.Reference,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error while inserting payment", err.Error())
+		return "", dbError(err)
+	}
+
+	if err := setBookingPaymentStatus(ctx, tx, req.TripCustomerID, req.Status); err != nil {
+		tx.Rollback()
+		return "", dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return uid.String(), nil
+}
+
+const paymentSelect = `
+	SELECT id, trip_customer_id, provider, amount, refunded_amount, currency, status,
+		COALESCE(reference, ''), created_at, updated_at
+	FROM payments
+`
+
+func scanPayment(row rowScanner) (models.Payment, error) {
+	payment := models.Payment{}
+
+	err := row.Scan(
+		&payment.ID,
+		&payment.TripCustomerID,
+		&payment.Provider,
+		&payment.Amount,
+		&payment.RefundedAmount,
+		&payment.Currency,
+		&payment.Status,
+		&payment.Reference,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+
+	return payment, dbError(err)
+}
+
+func (p paymentRepo) Get(ctx context.Context, id string) (models.Payment, error) {
+	payment, err := scanPayment(p.db.QueryRowContext(ctx, paymentSelect+` WHERE id = $1`, id))
+	if err != nil {
+		fmt.Println("error while scanning payment", err.Error())
+		return models.Payment{}, dbError(err)
+	}
+
+	return payment, nil
+}
+
+func (p paymentRepo) GetList(ctx context.Context, req models.GetPaymentListRequest) (models.PaymentsResponse, error) {
+	var (
+		payments = []models.Payment{}
+		count    = 0
+		offset   = (req.Page - 1) * req.Limit
+		filter   = ` WHERE ($1 = '' OR trip_customer_id::text = $1) AND ($2 = '' OR status = $2)`
+	)
+
+	if err := p.db.QueryRowContext(ctx, `SELECT count(1) FROM payments`+filter, req.TripCustomerID, req.Status).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of payments", err.Error())
+		return models.PaymentsResponse{}, dbError(err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, paymentSelect+filter+` ORDER BY created_at DESC LIMIT $3 OFFSET $4`, req.TripCustomerID, req.Status, req.Limit, offset)
+	if err != nil {
+		fmt.Println("error while querying payments", err.Error())
+		return models.PaymentsResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return models.PaymentsResponse{}, dbError(err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return models.PaymentsResponse{
+		Payments: payments,
+		Count:    count,
+	}, nil
+}
+
+// UpdateStatus changes the payment status and the payment status of its
+// booking together. The payment must still have the status it moves from,
+// so of two concurrent captures or refunds only one succeeds.
+func (p paymentRepo) UpdateStatus(ctx context.Context, req models.UpdatePaymentStatus) error {
+	if err := storage.CheckPaymentTransition(req.From, req.Status); err != nil {
+		return err
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	tripCustomerID := ""
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE payments SET status = $1, refunded_amount = $2, updated_at = now()
+		WHERE id = $3 AND status = $4
+		RETURNING trip_customer_id
+		`, req.Status, req.RefundedAmount, req.ID, req.From,
+	).Scan(&tripCustomerID); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return p.statusConflict(ctx, req.ID)
+		}
+		fmt.Println("error while updating payment status", err.Error())
+		return dbError(err)
+	}
+
+	if err := setBookingPaymentStatus(ctx, tx, tripCustomerID, req.Status); err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// statusConflict tells why no payment was updated: it is missing or it does
+// not have the status it has to move from
+func (p paymentRepo) statusConflict(ctx context.Context, id string) error {
+	exists := false
+	if err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM payments WHERE id = $1)`, id).Scan(&exists); err != nil {
+		fmt.Println("error while checking payment", err.Error())
+		return dbError(err)
+	}
+
+	if !exists {
+		return dbError(sql.ErrNoRows)
+	}
+
+	return storage.ErrInvalidPaymentStatus
+}
+
+func setBookingPaymentStatus(ctx context.Context, tx txn, tripCustomerID, paymentStatus string) error {
+	status, ok := bookingPaymentStatus[paymentStatus]
+	if !ok {
+		return nil
+	}
+
+	// a booking which is still active is unpaid again after a refund, so it
+	// can be paid once more
+	query := `UPDATE trip_customers SET payment_status = $1 WHERE id = $2`
+	args := []interface{}{status, tripCustomerID}
+	if paymentStatus == models.PaymentStatusRefunded {
+		query = `UPDATE trip_customers SET payment_status = CASE WHEN status = $3 THEN $4 ELSE $1 END WHERE id = $2`
+		args = append(args, models.BookingStatusBooked, models.BookingUnpaid)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		fmt.Println("error while updating booking payment status", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
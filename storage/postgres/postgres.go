@@ -2,52 +2,106 @@ package postgres
 
 import (
 	"city2city/config"
+	"city2city/pricing"
 	"city2city/storage"
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 type Store struct {
-	db *sql.DB
+	// db is the pool of connections, conn runs the queries on it or in the
+	// transaction of WithTx
+	db                *sql.DB
+	conn              conn
+	refundPolicy      pricing.RefundPolicy
+	commissionPercent int
 }
 
-func New(cfg config.Config) (storage.IStorage, error) {
+// Open returns a pool of connections to the database of the config
+func Open(cfg config.Config) (*sql.DB, error) {
 	url := fmt.Sprintf(`host = %s port = %s user = %s password = %s database = %s sslmode=disable`,
 		cfg.PostgresHost, cfg.PostgresPort, cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
 
-	db, err := sql.Open("postgres", url)
+	return sql.Open("postgres", url)
+}
+
+func New(cfg config.Config) (storage.IStorage, error) {
+	db, err := Open(cfg)
 	if err != nil {
 		return Store{}, err
 	}
 
 	return Store{
-		db: db,
+		db:   db,
+		conn: pool{db},
+		refundPolicy: pricing.RefundPolicy{
+			FullBefore:     time.Duration(cfg.RefundFullBeforeHours) * time.Hour,
+			PartialPercent: cfg.RefundPartialPercent,
+		},
+		commissionPercent: cfg.PlatformCommissionPercent,
 	}, nil
 }
 
 func (s Store) CloseDB() {
+	if s.db == nil {
+		return
+	}
 	s.db.Close()
 }
 
 func (s Store) City() storage.ICityRepo {
-	return NewCityRepo(s.db)
+	return NewCityRepo(s.conn)
 }
 
 func (s Store) Customer() storage.ICustomerRepo {
-	return NewCustomerRepo(s.db)
+	return NewCustomerRepo(s.conn)
 }
 
 func (s Store) Driver() storage.IDriverRepo {
-	return NewDriverRepo(s.db)
+	return NewDriverRepo(s.conn)
 }
 
 func (s Store) Car() storage.ICarRepo {
-	return NewCarRepo(s.db)
+	return NewCarRepo(s.conn)
 }
 
 func (s Store) Trip() storage.ITripRepo {
-	return NewTripRepo(s.db)
+	return NewTripRepo(s.conn, s.commissionPercent)
+}
+
+func (s Store) TripTemplate() storage.ITripTemplateRepo {
+	return NewTripTemplateRepo(s.conn)
+}
+
+func (s Store) RouteTariff() storage.IRouteTariffRepo {
+	return NewRouteTariffRepo(s.conn)
 }
+
+func (s Store) PriceRule() storage.IPriceRuleRepo {
+	return NewPriceRuleRepo(s.conn)
+}
+
 func (s Store) TripCustomer() storage.ITripCustomerRepo {
-	return NewTripCustomerRepo(s.db)
-}
\ No newline at end of file
+	return NewTripCustomerRepo(s.conn, s.refundPolicy)
+}
+
+func (s Store) Payment() storage.IPaymentRepo {
+	return NewPaymentRepo(s.conn)
+}
+
+func (s Store) DriverLedger() storage.IDriverLedgerRepo {
+	return NewDriverLedgerRepo(s.conn)
+}
+
+func (s Store) Report() storage.IReportRepo {
+	return NewReportRepo(s.conn)
+}
+
+func (s Store) Import() storage.IImportRepo {
+	return NewImportRepo(s.conn)
+}
+
+func (s Store) Auth() storage.IAuthRepo {
+	return NewAuthRepo(s.conn)
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"city2city/migrations"
+	"city2city/pricing"
+	"city2city/storage"
+	"city2city/storage/storagetest"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestStorage runs the conformance suite against the database of
+// POSTGRES_TEST_DSN, e.g.
+//
+//	POSTGRES_TEST_DSN="host=localhost user=postgres password=password dbname=city2city_test sslmode=disable" go test ./storage/postgres
+//
+// The tables of that database are truncated, do not point it at real data.
+func TestStorage(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.IStorage {
+		if _, err := db.Exec(`
+			TRUNCATE cities, customers, drivers, cars, trips, trip_customers, trip_templates,
+				route_tariffs, price_rules, payments, driver_ledger, admins, otp_codes CASCADE
+		`); err != nil {
+			t.Fatal(err)
+		}
+
+		// without db CloseDB leaves the pool open for the next test
+		return Store{
+			conn: pool{db},
+			refundPolicy: pricing.RefundPolicy{
+				FullBefore:     24 * time.Hour,
+				PartialPercent: 50,
+			},
+			commissionPercent: 10,
+		}
+	})
+}
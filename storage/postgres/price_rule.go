@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type priceRuleRepo struct {
+	db conn
+}
+
+func NewPriceRuleRepo(db conn) storage.IPriceRuleRepo {
+	return priceRuleRepo{
+		db: db,
+	}
+}
+
+func (p priceRuleRepo) Create(ctx context.Context, req models.CreatePriceRule) (string, error) {
+	uid := uuid.New()
+
+	query := `INSERT INTO price_rules (id, name, kind, threshold, percent, active) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := p.db.ExecContext(ctx, query, uid, req.Name, req.Kind, req.Threshold, req.Percent, req.Active); err != nil {
+		fmt.Println("error while inserting price rule", err.Error())
+		return "", dbError(err)
+	}
+
+	return uid.String(), nil
+}
+
+func (p priceRuleRepo) Get(ctx context.Context, id string) (models.PriceRule, error) {
+	rule := models.PriceRule{}
+
+	query := `SELECT id, name, kind, threshold, percent, active, created_at FROM price_rules WHERE id = $1`
+	if err := p.db.QueryRowContext(ctx, query, id).Scan(
+		&rule.ID,
+		&rule.Name,
+		&rule.Kind,
+		&rule.Threshold,
+		&rule.Percent,
+		&rule.Active,
+		&rule.CreatedAt,
+	); err != nil {
+		fmt.Println("error while scanning price rule", err.Error())
+		return models.PriceRule{}, dbError(err)
+	}
+
+	return rule, nil
+}
+
+func (p priceRuleRepo) GetList(ctx context.Context, req models.GetListRequest) (models.PriceRulesResponse, error) {
+	var (
+		count  = 0
+		offset = (req.Page - 1) * req.Limit
+	)
+
+	if err := p.db.QueryRowContext(ctx, `SELECT count(1) FROM price_rules`).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of price rules", err.Error())
+		return models.PriceRulesResponse{}, dbError(err)
+	}
+
+	rules, err := queryPriceRules(context.Background(), p.db, `
+		SELECT id, name, kind, threshold, percent, active, created_at FROM price_rules
+		ORDER BY created_at DESC LIMIT $1 OFFSET $2
+	`, req.Limit, offset)
+	if err != nil {
+		return models.PriceRulesResponse{}, dbError(err)
+	}
+
+	return models.PriceRulesResponse{
+		PriceRules: rules,
+		Count:      count,
+	}, nil
+}
+
+func (p priceRuleRepo) Update(ctx context.Context, req models.PriceRule) (string, error) {
+	query := `UPDATE price_rules SET name = $1, kind = $2, threshold = $3, percent = $4, active = $5 WHERE id = $6`
+	if _, err := p.db.ExecContext(ctx, query, req.Name, req.Kind, req.Threshold, req.Percent, req.Active, req.ID); err != nil {
+		fmt.Println("error while updating price rule", err.Error())
+		return "", dbError(err)
+	}
+
+	return req.ID, nil
+}
+
+func (p priceRuleRepo) Delete(ctx context.Context, id string) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM price_rules WHERE id = $1`, id); err != nil {
+		fmt.Println("error while deleting price rule", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func queryPriceRules(ctx context.Context, q querier, query string, args ...interface{}) ([]models.PriceRule, error) {
+	rules := []models.PriceRule{}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		fmt.Println("error while querying price rules", err.Error())
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := models.PriceRule{}
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.Name,
+			&rule.Kind,
+			&rule.Threshold,
+			&rule.Percent,
+			&rule.Active,
+			&rule.CreatedAt,
+		); err != nil {
+			fmt.Println("error while scanning price rule", err.Error())
+			return nil, dbError(err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"fmt"
+)
+
+type reportRepo struct {
+	db conn
+}
+
+func NewReportRepo(db conn) storage.IReportRepo {
+	return reportRepo{
+		db: db,
+	}
+}
+
+// reportTripStats is one row per not cancelled trip departing in [$2, $3)
+// with its period ($1 is the date_trunc unit), booked seats and revenue
+const reportTripStats = `
+	WITH trip_stats AS (
+		SELECT
+			t.id,
+			t.from_city_id,
+			t.to_city_id,
+			t.driver_id,
+			t.seats,
+			date_trunc($1, t.departure_at) AS period,
+			COALESCE(SUM(tc.seats), 0) AS passengers,
+			COALESCE(SUM(tc.fare), 0) AS revenue
+		FROM trips t
+		LEFT JOIN trip_customers tc ON tc.trip_id = t.id AND tc.status = 'booked'
+		WHERE t.status <> 'cancelled' AND t.departure_at >= $2 AND t.departure_at < $3
+		GROUP BY t.id
+	)
+`
+
+const reportTotals = `
+		COUNT(1),
+		SUM(ts.passengers),
+		SUM(ts.seats),
+		ROUND(AVG(ts.passengers * 100.0 / ts.seats), 2)::float8,
+		SUM(ts.revenue)
+`
+
+func (r reportRepo) Routes(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error) {
+	query := reportTripStats + `
+	SELECT
+		to_char(ts.period, 'YYYY-MM-DD'),
+		ts.from_city_id,
+		cities_from.name,
+		ts.to_city_id,
+		cities_to.name,` + reportTotals + `
+	FROM trip_stats ts
+	JOIN cities cities_from ON ts.from_city_id = cities_from.id
+	JOIN cities cities_to ON ts.to_city_id = cities_to.id
+	GROUP BY ts.period, ts.from_city_id, cities_from.name, ts.to_city_id, cities_to.name
+	ORDER BY ts.period, cities_from.name, cities_to.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, req.Period, req.From, req.To)
+	if err != nil {
+		fmt.Println("error while querying route report", err.Error())
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	report := []models.ReportRow{}
+	for rows.Next() {
+		row := models.ReportRow{}
+		if err := rows.Scan(
+			&row.Period,
+			&row.FromCityID,
+			&row.FromCityName,
+			&row.ToCityID,
+			&row.ToCityName,
+			&row.Trips,
+			&row.Passengers,
+			&row.Seats,
+			&row.Occupancy,
+			&row.Revenue,
+		); err != nil {
+			fmt.Println("error while scanning route report row", err.Error())
+			return nil, dbError(err)
+		}
+		report = append(report, row)
+	}
+
+	return report, nil
+}
+
+func (r reportRepo) Drivers(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error) {
+	query := reportTripStats + `
+	SELECT
+		to_char(ts.period, 'YYYY-MM-DD'),
+		ts.driver_id,
+		COALESCE(drivers.full_name, ''),` + reportTotals + `
+	FROM trip_stats ts
+	JOIN drivers ON ts.driver_id = drivers.id
+	GROUP BY ts.period, ts.driver_id, drivers.full_name
+	ORDER BY ts.period, drivers.full_name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, req.Period, req.From, req.To)
+	if err != nil {
+		fmt.Println("error while querying driver report", err.Error())
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	report := []models.ReportRow{}
+	for rows.Next() {
+		row := models.ReportRow{}
+		if err := rows.Scan(
+			&row.Period,
+			&row.DriverID,
+			&row.DriverName,
+			&row.Trips,
+			&row.Passengers,
+			&row.Seats,
+			&row.Occupancy,
+			&row.Revenue,
+		); err != nil {
+			fmt.Println("error while scanning driver report row", err.Error())
+			return nil, dbError(err)
+		}
+		report = append(report, row)
+	}
+
+	return report, nil
+}
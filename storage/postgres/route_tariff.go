@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type routeTariffRepo struct {
+	db conn
+}
+
+func NewRouteTariffRepo(db conn) storage.IRouteTariffRepo {
+	return routeTariffRepo{
+		db: db,
+	}
+}
+
+// Create starts a new tariff of the route. The tariff in effect at valid_from
+// is closed at that moment instead of being changed, so older fares stay in history.
+func (r routeTariffRepo) Create(ctx context.Context, req models.CreateRouteTariff) (string, error) {
+	uid := uuid.New()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	validFrom := ""
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(NULLIF($1, '')::timestamptz, now())::text`, req.ValidFrom).Scan(&validFrom); err != nil {
+		tx.Rollback()
+		fmt.Println("error while parsing valid_from", err.Error())
+		return "", dbError(err)
+	}
+
+	// the lock is keyed by the route rather than taken on its rows, so two
+	// first tariffs of a route are created one after the other as well
+	if _, err := tx.ExecContext(ctx, `
+		SELECT pg_advisory_xact_lock(hashtext($1::text || '/' || $2::text))
+		`, req.FromCityID, req.ToCityID,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error while locking route tariffs", err.Error())
+		return "", dbError(err)
+	}
+
+	later := false
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM route_tariffs
+			WHERE from_city_id = $1 AND to_city_id = $2 AND valid_from >= $3
+		)
+		`, req.FromCityID, req.ToCityID, validFrom,
+	).Scan(&later); err != nil {
+		tx.Rollback()
+		fmt.Println("error while checking route tariffs", err.Error())
+		return "", dbError(err)
+	}
+
+	if later {
+		tx.Rollback()
+		return "", storage.ErrTariffOverlap
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE route_tariffs SET valid_to = $3
+		WHERE from_city_id = $1 AND to_city_id = $2 AND (valid_to IS NULL OR valid_to > $3)
+		`, req.FromCityID, req.ToCityID, validFrom,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error while closing previous route tariff", err.Error())
+		return "", dbError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO route_tariffs (id, from_city_id, to_city_id, base_price, seat_price, valid_from)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`, uid, req.FromCityID, req.ToCityID, req.BasePrice, req.SeatPrice, validFrom,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error while inserting route tariff", err.Error())
+		return "", dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return uid.String(), nil
+}
+
+const routeTariffSelect = `
+	SELECT
+		rt.id,
+		rt.from_city_id,
+		cities_from.id AS from_city_data_id,
+		cities_from.name AS from_city_data_name,
+		cities_from.created_at AS from_city_data_created_at,
+		rt.to_city_id,
+		cities_to.id AS to_city_data_id,
+		cities_to.name AS to_city_data_name,
+		cities_to.created_at AS to_city_data_created_at,
+		rt.base_price,
+		rt.seat_price,
+		rt.valid_from,
+		rt.valid_to,
+		rt.created_at
+	FROM route_tariffs rt
+	JOIN cities cities_from ON rt.from_city_id = cities_from.id
+	JOIN cities cities_to ON rt.to_city_id = cities_to.id
+`
+
+func scanRouteTariff(row rowScanner) (models.RouteTariff, error) {
+	tariff := models.RouteTariff{}
+
+	err := row.Scan(
+		&tariff.ID,
+		&tariff.FromCityID,
+		&tariff.FromCityData.ID,
+		&tariff.FromCityData.Name,
+		&tariff.FromCityData.CreatedAt,
+		&tariff.ToCityID,
+		&tariff.ToCityData.ID,
+		&tariff.ToCityData.Name,
+		&tariff.ToCityData.CreatedAt,
+		&tariff.BasePrice,
+		&tariff.SeatPrice,
+		&tariff.ValidFrom,
+		&tariff.ValidTo,
+		&tariff.CreatedAt,
+	)
+
+	return tariff, dbError(err)
+}
+
+func (r routeTariffRepo) Get(ctx context.Context, id string) (models.RouteTariff, error) {
+	tariff, err := scanRouteTariff(r.db.QueryRowContext(ctx, routeTariffSelect+` WHERE rt.id = $1`, id))
+	if err != nil {
+		fmt.Println("error while scanning route tariff", err.Error())
+		return models.RouteTariff{}, dbError(err)
+	}
+
+	return tariff, nil
+}
+
+func (r routeTariffRepo) GetList(ctx context.Context, req models.GetRouteTariffListRequest) (models.RouteTariffsResponse, error) {
+	var (
+		tariffs = []models.RouteTariff{}
+		count   = 0
+		offset  = (req.Page - 1) * req.Limit
+		filter  = ` WHERE ($1 = '' OR rt.from_city_id::text = $1) AND ($2 = '' OR rt.to_city_id::text = $2)`
+	)
+
+	if err := r.db.QueryRowContext(ctx, `SELECT count(1) FROM route_tariffs rt`+filter, req.FromCityID, req.ToCityID).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of route tariffs", err.Error())
+		return models.RouteTariffsResponse{}, dbError(err)
+	}
+
+	query := routeTariffSelect + filter + ` ORDER BY rt.valid_from DESC LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.QueryContext(ctx, query, req.FromCityID, req.ToCityID, req.Limit, offset)
+	if err != nil {
+		fmt.Println("error while querying route tariffs", err.Error())
+		return models.RouteTariffsResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tariff, err := scanRouteTariff(rows)
+		if err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return models.RouteTariffsResponse{}, dbError(err)
+		}
+		tariffs = append(tariffs, tariff)
+	}
+
+	return models.RouteTariffsResponse{
+		RouteTariffs: tariffs,
+		Count:        count,
+	}, nil
+}
+
+// GetActive returns the tariff of the route in effect at the given time,
+// storage.ErrNoTariff if there is none
+func (r routeTariffRepo) GetActive(ctx context.Context, fromCityID, toCityID, at string) (models.RouteTariff, error) {
+	query := routeTariffSelect + `
+		WHERE rt.from_city_id = $1 AND rt.to_city_id = $2
+			AND rt.valid_from <= COALESCE(NULLIF($3, '')::timestamptz, now())
+			AND (rt.valid_to IS NULL OR rt.valid_to > COALESCE(NULLIF($3, '')::timestamptz, now()))
+		ORDER BY rt.valid_from DESC
+		LIMIT 1
+	`
+
+	tariff, err := scanRouteTariff(r.db.QueryRowContext(ctx, query, fromCityID, toCityID, at))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.RouteTariff{}, storage.ErrNoTariff
+		}
+		fmt.Println("error while scanning active route tariff", err.Error())
+		return models.RouteTariff{}, dbError(err)
+	}
+
+	return tariff, nil
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"city2city/api/models"
 	"city2city/storage"
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -11,19 +12,21 @@ import (
 )
 
 type tripRepo struct {
-	db *sql.DB
+	db                conn
+	commissionPercent int
 }
 
-func NewTripRepo(db *sql.DB) storage.ITripRepo {
+func NewTripRepo(db conn, commissionPercent int) storage.ITripRepo {
 	return &tripRepo{
-		db: db,
+		db:                db,
+		commissionPercent: commissionPercent,
 	}
 }
-func (t tripRepo) Create(req models.CreateTrip) (string, error) {
+func (t tripRepo) Create(ctx context.Context, req models.CreateTrip) (string, error) {
 	uid := uuid.New()
 	createdAt := time.Now()
 
-	tx, err := t.db.Begin()
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", fmt.Errorf("could not begin transaction: %v", err)
 	}
@@ -31,16 +34,47 @@ func (t tripRepo) Create(req models.CreateTrip) (string, error) {
 	defer func() {
 		if p := recover(); p != nil {
 			tx.Rollback()
+			panic(p)
 		}
 	}()
 
-	if _, err := tx.Exec(`
-		INSERT INTO trips (id, from_city_id, to_city_id, driver_id, price, created_at) 
-		VALUES ($1, $2, $3, $4, $5, $6)
-		`, uid, req.FromCityID, req.ToCityID, req.DriverID, req.Price, createdAt,
+	seats, err := driverSeats(ctx, tx, req.DriverID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := checkDriverSchedule(ctx, tx, req.DriverID, "", req.DepartureAt, req.ArrivalAt); err != nil {
+		tx.Rollback()
+		return "", dbError(err)
+	}
+
+	// a trip without an explicit price takes the fare of the route tariff
+	// in effect at departure and remembers which tariff it came from
+	tariffID := sql.NullString{}
+	if req.Price == 0 {
+		if err := tx.QueryRowContext(ctx, `
+			SELECT id, base_price + seat_price FROM route_tariffs
+			WHERE from_city_id = $1 AND to_city_id = $2
+				AND valid_from <= $3::timestamptz AND (valid_to IS NULL OR valid_to > $3::timestamptz)
+			ORDER BY valid_from DESC LIMIT 1
+			`, req.FromCityID, req.ToCityID, req.DepartureAt,
+		).Scan(&tariffID, &req.Price); err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				return "", storage.ErrNoTariff
+			}
+			return "", dbError(fmt.Errorf("error while selecting route tariff: %w", err))
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO trips (id, from_city_id, to_city_id, driver_id, price, seats, departure_at, arrival_at, template_id, tariff_id, created_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid, $10, $11)
+		`, uid, req.FromCityID, req.ToCityID, req.DriverID, req.Price, seats, req.DepartureAt, req.ArrivalAt, req.TemplateID, tariffID, createdAt,
 	); err != nil {
 		tx.Rollback()
-		return "", fmt.Errorf("error while inserting data: %v", err)
+		return "", dbError(fmt.Errorf("error while inserting data: %w", err))
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -50,52 +84,137 @@ func (t tripRepo) Create(req models.CreateTrip) (string, error) {
 	return uid.String(), nil
 }
 
-func (c tripRepo) Get(id models.PrimaryKey) (models.Trip, error) {
-	trip := models.Trip{}
+// driverSeats returns the seats of the active and newest car of the driver,
+// storage.ErrDriverNoCar if the driver has no car
+func driverSeats(ctx context.Context, tx txn, driverID string) (int, error) {
+	seats := 0
+	if err := tx.QueryRowContext(ctx, `
+		SELECT seats FROM cars WHERE driver_id = $1 
+		ORDER BY status DESC, created_at DESC LIMIT 1
+		`, driverID,
+	).Scan(&seats); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, storage.ErrDriverNoCar
+		}
+		return 0, dbError(fmt.Errorf("error while selecting driver car seats: %w", err))
+	}
 
-	query := `
+	return seats, nil
+}
+
+// checkDriverSchedule locks the driver and fails with storage.ErrDriverBusy if
+// another not cancelled trip of the driver overlaps the given time range
+func checkDriverSchedule(ctx context.Context, tx txn, driverID, tripID, departureAt, arrivalAt string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM drivers WHERE id = $1 FOR UPDATE`, driverID); err != nil {
+		fmt.Println("error while locking driver", err.Error())
+		return dbError(err)
+	}
+
+	busy := false
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM trips
+			WHERE driver_id = $1
+				AND id::text <> $2
+				AND status <> 'cancelled'
+				AND departure_at < $4
+				AND arrival_at > $3
+		)
+		`, driverID, tripID, departureAt, arrivalAt,
+	).Scan(&busy); err != nil {
+		fmt.Println("error while checking driver schedule", err.Error())
+		return dbError(err)
+	}
+
+	if busy {
+		return storage.ErrDriverBusy
+	}
+
+	return nil
+}
+
+// tripFreeSeats is the number of seats of trip t that are not booked yet
+const tripFreeSeats = `t.seats - (
+            SELECT COALESCE(SUM(tc.seats), 0) FROM trip_customers tc 
+            WHERE tc.trip_id = t.id AND tc.status = 'booked'
+        )`
+
+const tripSelect = `
         SELECT
             t.id, 
-			t.trip_number_id, 
-			t.from_city_id, 
-			t.to_city_id, 
-			t.driver_id, 
-			t.price, 
-			t.created_at,
+            t.trip_number_id, 
+            t.from_city_id, 
+            t.to_city_id, 
+            t.driver_id, 
+            t.price, 
+            t.seats,
+            ` + tripFreeSeats + ` AS free_seats,
+            t.template_id,
+            t.tariff_id,
+            t.departure_at,
+            t.arrival_at,
+            t.status,
+            t.boarding_at,
+            t.started_at,
+            t.completed_at,
+            t.cancelled_at,
+            t.created_at,
             cities_from.id AS from_city_data_id,
             cities_from.name AS from_city_data_name,
-			cities_from.created_at AS from_city_data_created_at,
+            cities_from.created_at AS from_city_data_created_at,
             cities_to.id AS to_city_data_id,
             cities_to.name AS to_city_data_name,
-			cities_to.created_at AS to_city_data_created_at,
+            cities_to.created_at AS to_city_data_created_at,
             drivers.id AS driver_data_id, 
-			drivers.full_name AS driver_data_name,
-			drivers.phone AS driver_data_phone,
-			drivers.from_city_id AS driver_from_city_id,
-			driver_from_cities.id AS driver_from_city_data_id,
-			driver_from_cities.name AS driver_from_city_data_name,
-			driver_from_cities.created_at AS driver_from_city_data_created_at,
-			drivers.to_city_id AS driver_to_city_id,
-			driver_to_cities.id AS driver_to_city_data_id,
-			driver_to_cities.name AS driver_to_city_data_name,
-			driver_to_cities.created_at AS driver_to_city_data_created_at,
-			drivers.created_at AS driver_data_created_at
+            drivers.full_name AS driver_data_name,
+            drivers.phone AS driver_data_phone,
+            drivers.from_city_id AS driver_from_city_id,
+            driver_from_cities.id AS driver_from_city_data_id,
+            driver_from_cities.name AS driver_from_city_data_name,
+            driver_from_cities.created_at AS driver_from_city_data_created_at,
+            drivers.to_city_id AS driver_to_city_id,
+            driver_to_cities.id AS driver_to_city_data_id,
+            driver_to_cities.name AS driver_to_city_data_name,
+            driver_to_cities.created_at AS driver_to_city_data_created_at,
+            drivers.created_at AS driver_data_created_at
+` + tripFrom
+
+// tripFrom are the tables of tripSelect, counts of trips join the same ones
+// so they count the rows the list returns
+const tripFrom = `
         FROM trips t
         JOIN cities cities_from ON t.from_city_id = cities_from.id
         JOIN cities cities_to ON t.to_city_id = cities_to.id
         JOIN drivers drivers ON t.driver_id = drivers.id
         JOIN cities driver_from_cities ON drivers.from_city_id = driver_from_cities.id
         JOIN cities driver_to_cities ON drivers.to_city_id = driver_to_cities.id
-        WHERE t.id = $1
-    `
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	err := c.db.QueryRow(query, id.ID).Scan(
+func scanTrip(row rowScanner) (models.Trip, error) {
+	trip := models.Trip{}
+
+	err := row.Scan(
 		&trip.ID,
 		&trip.TripNumberID,
 		&trip.FromCityID,
 		&trip.ToCityID,
 		&trip.DriverID,
 		&trip.Price,
+		&trip.Seats,
+		&trip.FreeSeats,
+		&trip.TemplateID,
+		&trip.TariffID,
+		&trip.DepartureAt,
+		&trip.ArrivalAt,
+		&trip.Status,
+		&trip.BoardingAt,
+		&trip.StartedAt,
+		&trip.CompletedAt,
+		&trip.CancelledAt,
 		&trip.CreatedAt,
 		&trip.FromCityData.ID,
 		&trip.FromCityData.Name,
@@ -117,107 +236,96 @@ func (c tripRepo) Get(id models.PrimaryKey) (models.Trip, error) {
 		&trip.DriverData.CreatedAt,
 	)
 
+	return trip, dbError(err)
+}
+
+func (c tripRepo) Get(ctx context.Context, id models.PrimaryKey) (models.Trip, error) {
+	query := tripSelect + `
+        WHERE t.id = $1
+    `
+
+	trip, err := scanTrip(c.db.QueryRowContext(ctx, query, id.ID))
 	if err != nil {
 		fmt.Println("error while scanning trip and related data", err.Error())
-		return models.Trip{}, err
+		return models.Trip{}, dbError(err)
 	}
 
 	return trip, nil
 }
 
-func (c tripRepo) GetList(req models.GetListRequest) (models.TripsResponse, error) {
+// tripColumns are the fields the list of trips can be filtered and sorted by
+var tripColumns = listColumns{
+	"id":             "t.id",
+	"trip_number_id": "t.trip_number_id",
+	"from_city_id":   "t.from_city_id",
+	"to_city_id":     "t.to_city_id",
+	"driver_id":      "t.driver_id",
+	"price":          "t.price",
+	"seats":          "t.seats",
+	"free_seats":     tripFreeSeats,
+	"status":         "t.status",
+	"departure_at":   "t.departure_at",
+	"arrival_at":     "t.arrival_at",
+	"created_at":     "t.created_at",
+}
+
+// tripSearch are the columns the search of trips looks in
+var tripSearch = []string{"t.trip_number_id", "cities_from.name", "cities_to.name", "drivers.full_name", "drivers.phone"}
+
+var (
+	// tripsByNewest is the order of the list of trips which is not sorted
+	tripsByNewest = []models.SortField{{Field: "created_at", Desc: true}}
+
+	// tripsByDeparture is the order of the export of trips which is not sorted
+	tripsByDeparture = []models.SortField{{Field: "departure_at"}}
+)
+
+func (c tripRepo) GetList(ctx context.Context, req models.GetTripListRequest) (models.TripsResponse, error) {
 	var (
 		trips  = []models.Trip{}
 		count  = 0
 		page   = req.Page
 		limit  = req.Limit
 		offset = (page - 1) * limit
+		args   = []interface{}{req.Status, req.DriverID}
 	)
 
-	countQuery := `
-        SELECT COUNT(1) FROM trips
-    `
+	conditions, err := listFilter(req.ListQuery, tripColumns, tripSearch, &args)
+	if err != nil {
+		return models.TripsResponse{}, err
+	}
 
-	if err := c.db.QueryRow(countQuery).Scan(&count); err != nil {
-		fmt.Println("error while scanning count of trips", err.Error())
+	orderBy, err := listOrder(req.Sort, tripColumns, tripsByNewest)
+	if err != nil {
 		return models.TripsResponse{}, err
 	}
 
-	query := `
-        SELECT
-            t.id, 
-            t.trip_number_id, 
-            t.from_city_id, 
-            t.to_city_id, 
-            t.driver_id, 
-            t.price, 
-            t.created_at,
-            cities_from.id AS from_city_data_id,
-            cities_from.name AS from_city_data_name,
-            cities_from.created_at AS from_city_data_created_at,
-            cities_to.id AS to_city_data_id,
-            cities_to.name AS to_city_data_name,
-            cities_to.created_at AS to_city_data_created_at,
-            drivers.id AS driver_data_id, 
-            drivers.full_name AS driver_data_name,
-            drivers.phone AS driver_data_phone,
-            drivers.from_city_id AS driver_from_city_id,
-            driver_from_cities.id AS driver_from_city_data_id,
-            driver_from_cities.name AS driver_from_city_data_name,
-            driver_from_cities.created_at AS driver_from_city_data_created_at,
-            drivers.to_city_id AS driver_to_city_id,
-            driver_to_cities.id AS driver_to_city_data_id,
-            driver_to_cities.name AS driver_to_city_data_name,
-            driver_to_cities.created_at AS driver_to_city_data_created_at,
-            drivers.created_at AS driver_data_created_at
-        FROM trips t
-        JOIN cities cities_from ON t.from_city_id = cities_from.id
-        JOIN cities cities_to ON t.to_city_id = cities_to.id
-        JOIN drivers drivers ON t.driver_id = drivers.id
-        JOIN cities driver_from_cities ON drivers.from_city_id = driver_from_cities.id
-        JOIN cities driver_to_cities ON drivers.to_city_id = driver_to_cities.id
-        ORDER BY t.created_at DESC
-        LIMIT $1 OFFSET $2
-    `
+	filter := where(append([]string{`($1 = '' OR t.status = $1) AND ($2 = '' OR t.driver_id::text = $2)`}, conditions...))
+
+	countQuery := `SELECT COUNT(1)` + tripFrom + filter
+
+	if err := c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of trips", err.Error())
+		return models.TripsResponse{}, dbError(err)
+	}
+
+	args = append(args, limit, offset)
+	query := tripSelect + filter + orderBy + fmt.Sprintf(`
+        LIMIT $%d OFFSET $%d
+    `, len(args)-1, len(args))
 
-	rows, err := c.db.Query(query, limit, offset)
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		fmt.Println("error while querying rows", err.Error())
-		return models.TripsResponse{}, err
+		return models.TripsResponse{}, dbError(err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		trip := models.Trip{}
-		if err := rows.Scan(
-			&trip.ID,
-			&trip.TripNumberID,
-			&trip.FromCityID,
-			&trip.ToCityID,
-			&trip.DriverID,
-			&trip.Price,
-			&trip.CreatedAt,
-			&trip.FromCityData.ID,
-			&trip.FromCityData.Name,
-			&trip.FromCityData.CreatedAt,
-			&trip.ToCityData.ID,
-			&trip.ToCityData.Name,
-			&trip.ToCityData.CreatedAt,
-			&trip.DriverData.ID,
-			&trip.DriverData.FullName,
-			&trip.DriverData.Phone,
-			&trip.DriverData.FromCityID,
-			&trip.DriverData.FromCityData.ID,
-			&trip.DriverData.FromCityData.Name,
-			&trip.DriverData.FromCityData.CreatedAt,
-			&trip.DriverData.ToCityID,
-			&trip.DriverData.ToCityData.ID,
-			&trip.DriverData.ToCityData.Name,
-			&trip.DriverData.ToCityData.CreatedAt,
-			&trip.DriverData.CreatedAt,
-		); err != nil {
+		trip, err := scanTrip(rows)
+		if err != nil {
 			fmt.Println("error while scanning row", err.Error())
-			return models.TripsResponse{}, err
+			return models.TripsResponse{}, dbError(err)
 		}
 		trips = append(trips, trip)
 	}
@@ -228,33 +336,298 @@ func (c tripRepo) GetList(req models.GetListRequest) (models.TripsResponse, erro
 	}, nil
 }
 
-func (c tripRepo) Update(req models.Trip) (string, error) {
+// Stream calls fn for every trip of the list of req in the order of its
+// sort, by departure when it has none. Page and limit are ignored and rows
+// are read one by one.
+func (c tripRepo) Stream(ctx context.Context, req models.GetTripListRequest, fn func(models.Trip) error) error {
+	args := []interface{}{req.Status, req.DriverID}
+
+	conditions, err := listFilter(req.ListQuery, tripColumns, tripSearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(req.Sort, tripColumns, tripsByDeparture)
+	if err != nil {
+		return err
+	}
+
+	filter := where(append([]string{`($1 = '' OR t.status = $1) AND ($2 = '' OR t.driver_id::text = $2)`}, conditions...))
+
+	rows, err := c.db.QueryContext(ctx, tripSelect+filter+orderBy, args...)
+	if err != nil {
+		fmt.Println("error while querying rows", err.Error())
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return dbError(err)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(trip); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
+
+func (c tripRepo) Search(ctx context.Context, req models.SearchTripRequest) (models.TripsResponse, error) {
+	var (
+		trips  = []models.Trip{}
+		count  = 0
+		filter = ` WHERE t.status IN ('scheduled', 'boarding') AND t.departure_at >= $1`
+		args   = []interface{}{req.DepartureFrom}
+		offset = (req.Page - 1) * req.Limit
+	)
+
+	if req.DepartureTo != "" {
+		args = append(args, req.DepartureTo)
+		filter += fmt.Sprintf(` AND t.departure_at < $%d`, len(args))
+	}
+
+	if req.FromCityID != "" {
+		args = append(args, req.FromCityID)
+		filter += fmt.Sprintf(` AND t.from_city_id = $%d`, len(args))
+	}
+
+	if req.ToCityID != "" {
+		args = append(args, req.ToCityID)
+		filter += fmt.Sprintf(` AND t.to_city_id = $%d`, len(args))
+	}
+
+	if req.MaxPrice > 0 {
+		args = append(args, req.MaxPrice)
+		filter += fmt.Sprintf(` AND t.price <= $%d`, len(args))
+	}
+
+	if req.MinFreeSeats > 0 {
+		args = append(args, req.MinFreeSeats)
+		filter += fmt.Sprintf(` AND %s >= $%d`, tripFreeSeats, len(args))
+	}
+
+	countQuery := `SELECT COUNT(1)` + tripFrom + filter
+	if err := c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of found trips", err.Error())
+		return models.TripsResponse{}, dbError(err)
+	}
+
+	args = append(args, req.Limit, offset)
+	query := tripSelect + filter + fmt.Sprintf(`
+        ORDER BY t.departure_at, t.price
+        LIMIT $%d OFFSET $%d
+    `, len(args)-1, len(args))
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		fmt.Println("error while searching trips", err.Error())
+		return models.TripsResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return models.TripsResponse{}, dbError(err)
+		}
+		trips = append(trips, trip)
+	}
+
+	return models.TripsResponse{
+		Trips: trips,
+		Count: count,
+	}, nil
+}
+
+func (c tripRepo) Update(ctx context.Context, req models.Trip) (string, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	var (
+		driverID, status string
+		seats, booked    = 0, 0
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT driver_id, status, seats, (
+			SELECT COALESCE(SUM(tc.seats), 0) FROM trip_customers tc
+			WHERE tc.trip_id = trips.id AND tc.status = $2
+		)
+		FROM trips WHERE id = $1 FOR UPDATE
+		`, req.ID, models.BookingStatusBooked,
+	).Scan(&driverID, &status, &seats, &booked); err != nil {
+		tx.Rollback()
+		fmt.Println("error while locking trip", err.Error())
+		return "", dbError(err)
+	}
+
+	// a trip which is boarding or on the road keeps its driver and times
+	if status != models.TripStatusScheduled {
+		tx.Rollback()
+		return "", storage.ErrTripNotEditable
+	}
+
+	// another driver comes with another car, the bookings must fit in it
+	if req.DriverID != driverID {
+		if seats, err = driverSeats(ctx, tx, req.DriverID); err != nil {
+			tx.Rollback()
+			return "", err
+		}
+
+		if booked > seats {
+			tx.Rollback()
+			return "", storage.ErrCarTooSmall
+		}
+	}
+
+	if err := checkDriverSchedule(ctx, tx, req.DriverID, req.ID, req.DepartureAt, req.ArrivalAt); err != nil {
+		tx.Rollback()
+		return "", dbError(err)
+	}
+
 	query := `
         UPDATE trips 
         SET  from_city_id = $1, 
             to_city_id = $2, 
             driver_id = $3, 
-            price = $4 
-        WHERE id = $5
+            price = $4,
+            departure_at = $5,
+            arrival_at = $6,
+            seats = $7
+        WHERE id = $8
     `
 
-	_, err := c.db.Exec(query, req.FromCityID, req.ToCityID, req.DriverID, req.Price, req.ID)
-	if err != nil {
+	if _, err := tx.ExecContext(ctx, query, req.FromCityID, req.ToCityID, req.DriverID, req.Price, req.DepartureAt, req.ArrivalAt, seats, req.ID); err != nil {
+		tx.Rollback()
 		fmt.Println("error while updating trips data:", err.Error())
-		return " ", err
+		return " ", dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
 	}
 
 	return req.ID, nil
 }
 
-func (c tripRepo) Delete(id models.PrimaryKey) error {
+// tripStatusColumns keeps the column in which the time of reaching a status is stored
+var tripStatusColumns = map[string]string{
+	models.TripStatusBoarding:   "boarding_at",
+	models.TripStatusInProgress: "started_at",
+	models.TripStatusCompleted:  "completed_at",
+	models.TripStatusCancelled:  "cancelled_at",
+}
+
+func (c tripRepo) UpdateStatus(ctx context.Context, req models.UpdateTripStatus) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	status := ""
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM trips WHERE id = $1 FOR UPDATE`, req.ID).Scan(&status); err != nil {
+		tx.Rollback()
+		fmt.Println("error while locking trip", err.Error())
+		return dbError(err)
+	}
+
+	if err := storage.CheckTripTransition(status, req.Status); err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+
+	query := fmt.Sprintf(`UPDATE trips SET status = $1, %s = now() WHERE id = $2`, tripStatusColumns[req.Status])
+	if _, err := tx.ExecContext(ctx, query, req.Status, req.ID); err != nil {
+		tx.Rollback()
+		fmt.Println("error while updating trip status", err.Error())
+		return dbError(err)
+	}
+
+	if req.Status == models.TripStatusCompleted {
+		if err := c.creditDriver(ctx, tx, req.ID); err != nil {
+			tx.Rollback()
+			return dbError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// creditDriver adds the earnings of a completed trip to the driver ledger:
+// the fares of paid bookings and the part of the fares of paid bookings
+// cancelled late that is not refunded, minus the platform commission
+func (c tripRepo) creditDriver(ctx context.Context, tx txn, tripID string) error {
+	var (
+		driverID, tripNumberID string
+		paid                   = 0
+	)
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT t.driver_id, COALESCE(t.trip_number_id, ''),
+			COALESCE(SUM(CASE WHEN tc.status = $2 THEN tc.fare ELSE tc.fare - tc.refund_amount END), 0)
+		FROM trips t
+		LEFT JOIN trip_customers tc ON tc.trip_id = t.id AND (
+			(tc.status = $2 AND tc.payment_status = $3) OR
+			(tc.status = $4 AND tc.payment_status IN ($3, $5))
+		)
+		WHERE t.id = $1
+		GROUP BY t.id
+		`, tripID, models.BookingStatusBooked, models.BookingPaid, models.BookingStatusCancelled, models.BookingRefunded,
+	).Scan(&driverID, &tripNumberID, &paid); err != nil {
+		fmt.Println("error while summing paid bookings", err.Error())
+		return dbError(err)
+	}
+
+	commission := paid * c.commissionPercent / 100
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO driver_ledger (id, driver_id, trip_id, kind, amount, commission, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, uuid.New(), driverID, tripID, models.LedgerCredit, paid-commission, commission, "trip "+tripNumberID,
+	); err != nil {
+		fmt.Println("error while crediting driver", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (c tripRepo) Delete(ctx context.Context, id models.PrimaryKey) error {
 	query := `
         delete from trips
         WHERE id = $1
     `
-	if _, err := c.db.Exec(query, id.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, id.ID); err != nil {
 		fmt.Println("error while deleting trip by id", err.Error())
-		return err
+		return dbError(err)
 	}
 
 	return nil
@@ -2,90 +2,210 @@ package postgres
 
 import (
 	"city2city/api/models"
+	"city2city/pricing"
 	"city2city/storage"
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 )
 
 type tripCustomerRepo struct {
-	db *sql.DB
+	db           conn
+	refundPolicy pricing.RefundPolicy
 }
 
-func NewTripCustomerRepo(db *sql.DB) storage.ITripCustomerRepo {
+func NewTripCustomerRepo(db conn, refundPolicy pricing.RefundPolicy) storage.ITripCustomerRepo {
 	return &tripCustomerRepo{
-		db: db,
+		db:           db,
+		refundPolicy: refundPolicy,
 	}
 }
 
-func (c *tripCustomerRepo) Create(req models.CreateTripCustomer) (string, error) {
+func (c *tripCustomerRepo) Create(ctx context.Context, req models.CreateTripCustomer) (string, error) {
 	id := uuid.New()
-	query := `INSERT INTO trip_customers (id, trip_id, customer_id) values($1, $2, $3)`
-	if _, err := c.db.Exec(query, id, req.TripID, req.CustomerID); err != nil {
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// locking the trip row serializes concurrent bookings of the same trip,
+	// so the booked seats below can not change until this transaction ends
+	var (
+		seats, booked, price, minutesToDeparture = 0, 0, 0, 0
+		seatPrice                                = sql.NullInt64{}
+		status                                   string
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT t.seats, t.status, t.price, rt.seat_price,
+			EXTRACT(EPOCH FROM t.departure_at - now())::int / 60
+		FROM trips t
+		LEFT JOIN route_tariffs rt ON t.tariff_id = rt.id
+		WHERE t.id = $1
+		FOR UPDATE OF t
+		`, req.TripID,
+	).Scan(&seats, &status, &price, &seatPrice, &minutesToDeparture); err != nil {
+		tx.Rollback()
+		fmt.Println("error is while locking trip", err.Error())
+		return "", dbError(err)
+	}
+
+	if status != models.TripStatusScheduled && status != models.TripStatusBoarding {
+		tx.Rollback()
+		return "", storage.ErrTripNotBookable
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(seats), 0) FROM trip_customers WHERE trip_id = $1 AND status = $2
+		`, req.TripID, models.BookingStatusBooked,
+	).Scan(&booked); err != nil {
+		tx.Rollback()
+		fmt.Println("error is while counting booked seats", err.Error())
+		return "", dbError(err)
+	}
+
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+
+	if booked+req.Seats > seats {
+		tx.Rollback()
+		return "", storage.ErrTripFull
+	}
+
+	// trips priced from a tariff already include the base price once,
+	// every extra seat adds the tariff seat price
+	fare := price * req.Seats
+	if seatPrice.Valid {
+		fare = price + int(seatPrice.Int64)*(req.Seats-1)
+	}
+
+	// the fare is quoted now and kept on the booking, so later changes
+	// of the price rules do not change what the customer pays
+	rules, err := queryPriceRules(ctx, tx, `
+		SELECT id, name, kind, threshold, percent, active, created_at FROM price_rules WHERE active
+	`)
+	if err != nil {
+		tx.Rollback()
+		return "", dbError(err)
+	}
+
+	fare = pricing.Quote(fare, seats, booked, minutesToDeparture, rules)
+
+	query := `
+		INSERT INTO trip_customers (id, trip_id, customer_id, seats, fare, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := tx.ExecContext(ctx, query, id, req.TripID, req.CustomerID, req.Seats, fare, models.CurrencyUZS, models.BookingStatusBooked); err != nil {
+		tx.Rollback()
 		fmt.Println("error is while inserting trip customer", err.Error())
-		return "", err
+		return "", dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
 	}
+
 	return id.String(), nil
 }
 
-func (c *tripCustomerRepo) Get(id string) (models.TripCustomer, error) {
-	trip := models.TripCustomer{}
-	query := `SELECT tr.id, tr.trip_id, tr.customer_id, 
+const tripCustomerSelect = `SELECT tr.id, tr.trip_id, tr.customer_id, 
        				 c.id as customer_id,c.full_name as customer_name, c.phone as customer_phone, 
        				 c.email as customer_email, c.created_at as customer_date,
-       				 tr.created_at
+       				 tr.seats, tr.fare, tr.currency, tr.status,
+       				 tr.cancel_reason, tr.cancelled_at, tr.refund_amount, tr.payment_status, tr.created_at
 					FROM trip_customers as tr 
-					LEFT JOIN customers as c ON tr.customer_id = c.id WHERE tr.id = $1`
+					LEFT JOIN customers as c ON tr.customer_id = c.id `
 
-	if err := c.db.QueryRow(query, id).Scan(
+func scanTripCustomer(row rowScanner) (models.TripCustomer, error) {
+	trip := models.TripCustomer{}
+
+	err := row.Scan(
 		&trip.ID, &trip.TripID, &trip.CustomerID,
 		&trip.CustomerData.ID, &trip.CustomerData.FullName, &trip.CustomerData.Phone, &trip.CustomerData.Email,
-		&trip.CustomerData.CreatedAt, &trip.CreatedAt,
-	); err != nil {
+		&trip.CustomerData.CreatedAt, &trip.Seats, &trip.Fare, &trip.Currency, &trip.Status,
+		&trip.CancelReason, &trip.CancelledAt, &trip.RefundAmount, &trip.PaymentStatus, &trip.CreatedAt,
+	)
+
+	return trip, dbError(err)
+}
+
+func (c *tripCustomerRepo) Get(ctx context.Context, id string) (models.TripCustomer, error) {
+	trip, err := scanTripCustomer(c.db.QueryRowContext(ctx, tripCustomerSelect+` WHERE tr.id = $1`, id))
+	if err != nil {
 		fmt.Println("error is while scanning trip customer", err.Error())
-		return models.TripCustomer{}, err
+		return models.TripCustomer{}, dbError(err)
 	}
 	return trip, nil
 }
 
-func (c *tripCustomerRepo) GetList(req models.GetListRequest) (models.TripCustomersResponse, error) {
+// tripCustomerColumns are the fields the list of bookings can be filtered
+// and sorted by
+var tripCustomerColumns = listColumns{
+	"id":             "tr.id",
+	"trip_id":        "tr.trip_id",
+	"customer_id":    "tr.customer_id",
+	"seats":          "tr.seats",
+	"fare":           "tr.fare",
+	"status":         "tr.status",
+	"payment_status": "tr.payment_status",
+	"created_at":     "tr.created_at",
+}
+
+// tripCustomerSearch are the columns the search of bookings looks in
+var tripCustomerSearch = []string{"c.full_name", "c.phone"}
+
+func (c *tripCustomerRepo) GetList(ctx context.Context, req models.GetListRequest) (models.TripCustomersResponse, error) {
 	var (
-		page              = req.Page
-		offset            = (page - 1) * req.Limit
-		tripCustomers     = []models.TripCustomer{}
-		query, countQuery string
-		count             = 0
+		page          = req.Page
+		offset        = (page - 1) * req.Limit
+		tripCustomers = []models.TripCustomer{}
+		countQuery    string
+		count         = 0
+		args          = []interface{}{}
 	)
 
-	countQuery = `SELECT count(1) FROM trip_customers`
-	if err := c.db.QueryRow(countQuery).Scan(&count); err != nil {
-		fmt.Println("error is while scanning count", err.Error())
+	conditions, err := listFilter(req.ListQuery, tripCustomerColumns, tripCustomerSearch, &args)
+	if err != nil {
 		return models.TripCustomersResponse{}, err
 	}
 
-	query = `SELECT tr.id, tr.trip_id, tr.customer_id, 
-       				 c.id as customer_id,c.full_name as customer_name, c.phone as customer_phone, 
-       				 c.email as customer_email, c.created_at as customer_date,
-       				 tr.created_at
-					FROM trip_customers as tr 
-					LEFT JOIN customers as c ON tr.customer_id = c.id `
-	query += ` LIMIT $1 OFFSET $2`
+	orderBy, err := listOrder(req.Sort, tripCustomerColumns, byCreatedAt)
+	if err != nil {
+		return models.TripCustomersResponse{}, err
+	}
 
-	rows, err := c.db.Query(query, req.Limit, offset)
+	countQuery = `SELECT count(1) FROM trip_customers as tr LEFT JOIN customers as c ON tr.customer_id = c.id` + where(conditions)
+	if err := c.db.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
+		fmt.Println("error is while scanning count", err.Error())
+		return models.TripCustomersResponse{}, dbError(err)
+	}
+
+	args = append(args, req.Limit, offset)
+	query := tripCustomerSelect + where(conditions) + orderBy + fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		fmt.Println("error is while selecting trip customers", err.Error())
-		return models.TripCustomersResponse{}, err
+		return models.TripCustomersResponse{}, dbError(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		trip := models.TripCustomer{}
-		if err = rows.Scan(
-			&trip.ID, &trip.TripID, &trip.CustomerID,
-			&trip.CustomerData.ID, &trip.CustomerData.FullName, &trip.CustomerData.Phone, &trip.CustomerData.Email,
-			&trip.CustomerData.CreatedAt, &trip.CreatedAt,
-		); err != nil {
+		trip, err := scanTripCustomer(rows)
+		if err != nil {
 			fmt.Println("error is while scanning rows", err.Error())
-			return models.TripCustomersResponse{}, err
+			return models.TripCustomersResponse{}, dbError(err)
 		}
 		tripCustomers = append(tripCustomers, trip)
 	}
@@ -96,21 +216,219 @@ func (c *tripCustomerRepo) GetList(req models.GetListRequest) (models.TripCustom
 	}, nil
 }
 
-func (c *tripCustomerRepo) Update(req models.TripCustomer) (string, error) {
+// Stream calls fn for every trip customer matching the filters and the
+// search of q in the order of the list, rows are read one by one
+func (c *tripCustomerRepo) Stream(ctx context.Context, q models.ListQuery, fn func(models.TripCustomer) error) error {
+	args := []interface{}{}
+
+	conditions, err := listFilter(q, tripCustomerColumns, tripCustomerSearch, &args)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := listOrder(q.Sort, tripCustomerColumns, byCreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, tripCustomerSelect+where(conditions)+orderBy, args...)
+	if err != nil {
+		fmt.Println("error is while selecting trip customers", err.Error())
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trip, err := scanTripCustomer(rows)
+		if err != nil {
+			fmt.Println("error is while scanning rows", err.Error())
+			return dbError(err)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return dbError(err)
+		}
+
+		if err = fn(trip); err != nil {
+			return dbError(err)
+		}
+	}
+
+	return dbError(rows.Err())
+}
+
+// GetByTrip returns every booking of the trip, cancelled ones included
+func (c *tripCustomerRepo) GetByTrip(ctx context.Context, tripID string) (models.TripCustomersResponse, error) {
+	tripCustomers := []models.TripCustomer{}
+
+	rows, err := c.db.QueryContext(ctx, tripCustomerSelect+` WHERE tr.trip_id = $1 ORDER BY tr.created_at`, tripID)
+	if err != nil {
+		fmt.Println("error is while selecting trip customers of trip", err.Error())
+		return models.TripCustomersResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trip, err := scanTripCustomer(rows)
+		if err != nil {
+			fmt.Println("error is while scanning rows", err.Error())
+			return models.TripCustomersResponse{}, dbError(err)
+		}
+		tripCustomers = append(tripCustomers, trip)
+	}
+
+	return models.TripCustomersResponse{
+		TripCustomers: tripCustomers,
+		Count:         len(tripCustomers),
+	}, nil
+}
+
+// GetUnpaid returns the active bookings of the trip nobody has paid for yet,
+// the customers the driver has to collect cash from at boarding
+func (c *tripCustomerRepo) GetUnpaid(ctx context.Context, tripID string) (models.TripCustomersResponse, error) {
+	tripCustomers := []models.TripCustomer{}
+
+	rows, err := c.db.QueryContext(ctx, tripCustomerSelect+`
+		WHERE tr.trip_id = $1 AND tr.status = $2 AND tr.payment_status = $3
+		ORDER BY tr.created_at
+		`, tripID, models.BookingStatusBooked, models.BookingUnpaid,
+	)
+	if err != nil {
+		fmt.Println("error is while selecting unpaid trip customers", err.Error())
+		return models.TripCustomersResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trip, err := scanTripCustomer(rows)
+		if err != nil {
+			fmt.Println("error is while scanning rows", err.Error())
+			return models.TripCustomersResponse{}, dbError(err)
+		}
+		tripCustomers = append(tripCustomers, trip)
+	}
+
+	return models.TripCustomersResponse{
+		TripCustomers: tripCustomers,
+		Count:         len(tripCustomers),
+	}, nil
+}
+
+func (c *tripCustomerRepo) Update(ctx context.Context, req models.TripCustomer) (string, error) {
 	query := `UPDATE trip_customers SET customer_id = $1 WHERE id = $2`
-	if _, err := c.db.Exec(query, req.CustomerID, req.ID); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, req.CustomerID, req.ID); err != nil {
 		fmt.Println("error is while updating trip customer", err.Error())
-		return "", err
+		return "", dbError(err)
 	}
 	return req.ID, nil
 }
 
-func (c *tripCustomerRepo) Delete(id string) error {
+func (c *tripCustomerRepo) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM trip_customers WHERE id = $1`
 
-	if _, err := c.db.Exec(query, id); err != nil {
+	if _, err := c.db.ExecContext(ctx, query, id); err != nil {
 		fmt.Println("error is while deleting trip customer", err.Error())
-		return err
+		return dbError(err)
+	}
+	return nil
+}
+
+// Cancel keeps the booking but marks it cancelled, which frees its seats,
+// and stores the refund the refund policy gives for it
+func (c *tripCustomerRepo) Cancel(ctx context.Context, req models.CancelTripCustomer) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	var (
+		fare, secondsToDeparture = 0, 0
+		status, tripStatus       string
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT tc.fare, tc.status, t.status, EXTRACT(EPOCH FROM t.departure_at - now())::int
+		FROM trip_customers tc
+		JOIN trips t ON tc.trip_id = t.id
+		WHERE tc.id = $1
+		FOR UPDATE OF tc
+		`, req.ID,
+	).Scan(&fare, &status, &tripStatus, &secondsToDeparture); err != nil {
+		tx.Rollback()
+		fmt.Println("error is while locking trip customer", err.Error())
+		return dbError(err)
+	}
+
+	if status == models.BookingStatusCancelled {
+		tx.Rollback()
+		return storage.ErrBookingCancelled
+	}
+
+	if tripStatus == models.TripStatusInProgress || tripStatus == models.TripStatusCompleted {
+		tx.Rollback()
+		return storage.ErrTripStarted
+	}
+
+	// when the trip itself is cancelled the customer gets everything back
+	refund := fare
+	if tripStatus != models.TripStatusCancelled {
+		refund = c.refundPolicy.Refund(fare, time.Duration(secondsToDeparture)*time.Second)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE trip_customers
+		SET status = $1, cancel_reason = $2, cancelled_at = now(), refund_amount = $3
+		WHERE id = $4
+		`, models.BookingStatusCancelled, req.Reason, refund, req.ID,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error is while cancelling trip customer", err.Error())
+		return dbError(err)
+	}
+
+	// the money an authorized payment holds is not taken any more
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE payments SET status = $1, updated_at = now()
+		WHERE trip_customer_id = $2 AND status = $3
+		`, models.PaymentStatusVoided, req.ID, models.PaymentStatusAuthorized,
+	); err != nil {
+		tx.Rollback()
+		fmt.Println("error is while voiding payments of trip customer", err.Error())
+		return dbError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
 	}
+
 	return nil
-}
\ No newline at end of file
+}
+
+func (c *tripCustomerRepo) GetTripTotals(ctx context.Context, tripID string) (models.TripTotals, error) {
+	totals := models.TripTotals{
+		TripID:   tripID,
+		Currency: models.CurrencyUZS,
+	}
+
+	query := `
+		SELECT count(1), COALESCE(SUM(seats), 0), COALESCE(SUM(fare), 0)
+		FROM trip_customers
+		WHERE trip_id = $1 AND status = $2
+	`
+	if err := c.db.QueryRowContext(ctx, query, tripID, models.BookingStatusBooked).Scan(
+		&totals.Bookings,
+		&totals.Passengers,
+		&totals.TotalFare,
+	); err != nil {
+		fmt.Println("error is while scanning trip totals", err.Error())
+		return models.TripTotals{}, dbError(err)
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type tripTemplateRepo struct {
+	db conn
+}
+
+func NewTripTemplateRepo(db conn) storage.ITripTemplateRepo {
+	return tripTemplateRepo{
+		db: db,
+	}
+}
+
+func (t tripTemplateRepo) Create(ctx context.Context, req models.CreateTripTemplate) (string, error) {
+	uid := uuid.New()
+
+	query := `
+		INSERT INTO trip_templates (id, from_city_id, to_city_id, driver_id, price, weekdays, departure_time, duration_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	if _, err := t.db.ExecContext(ctx, query,
+		uid,
+		req.FromCityID,
+		req.ToCityID,
+		req.DriverID,
+		req.Price,
+		pq.Array(req.Weekdays),
+		req.DepartureTime,
+		req.DurationMinutes,
+	); err != nil {
+		fmt.Println("error while inserting trip template", err.Error())
+		return "", dbError(err)
+	}
+
+	return uid.String(), nil
+}
+
+const tripTemplateSelect = `
+	SELECT
+		tt.id,
+		tt.from_city_id,
+		cities_from.id AS from_city_data_id,
+		cities_from.name AS from_city_data_name,
+		cities_from.created_at AS from_city_data_created_at,
+		tt.to_city_id,
+		cities_to.id AS to_city_data_id,
+		cities_to.name AS to_city_data_name,
+		cities_to.created_at AS to_city_data_created_at,
+		tt.driver_id,
+		tt.price,
+		tt.weekdays,
+		to_char(tt.departure_time, 'HH24:MI'),
+		tt.duration_minutes,
+		tt.created_at
+	FROM trip_templates tt
+	JOIN cities cities_from ON tt.from_city_id = cities_from.id
+	JOIN cities cities_to ON tt.to_city_id = cities_to.id
+`
+
+func scanTripTemplate(row rowScanner) (models.TripTemplate, error) {
+	var (
+		template = models.TripTemplate{}
+		weekdays = pq.Int64Array{}
+	)
+
+	if err := row.Scan(
+		&template.ID,
+		&template.FromCityID,
+		&template.FromCityData.ID,
+		&template.FromCityData.Name,
+		&template.FromCityData.CreatedAt,
+		&template.ToCityID,
+		&template.ToCityData.ID,
+		&template.ToCityData.Name,
+		&template.ToCityData.CreatedAt,
+		&template.DriverID,
+		&template.Price,
+		&weekdays,
+		&template.DepartureTime,
+		&template.DurationMinutes,
+		&template.CreatedAt,
+	); err != nil {
+		return models.TripTemplate{}, dbError(err)
+	}
+
+	template.Weekdays = make([]int, 0, len(weekdays))
+	for _, day := range weekdays {
+		template.Weekdays = append(template.Weekdays, int(day))
+	}
+
+	return template, nil
+}
+
+func (t tripTemplateRepo) Get(ctx context.Context, id string) (models.TripTemplate, error) {
+	template, err := scanTripTemplate(t.db.QueryRowContext(ctx, tripTemplateSelect+` WHERE tt.id = $1`, id))
+	if err != nil {
+		fmt.Println("error while scanning trip template", err.Error())
+		return models.TripTemplate{}, dbError(err)
+	}
+
+	return template, nil
+}
+
+func (t tripTemplateRepo) GetList(ctx context.Context, req models.GetListRequest) (models.TripTemplatesResponse, error) {
+	var (
+		templates = []models.TripTemplate{}
+		count     = 0
+		offset    = (req.Page - 1) * req.Limit
+	)
+
+	if err := t.db.QueryRowContext(ctx, `SELECT count(1) FROM trip_templates`).Scan(&count); err != nil {
+		fmt.Println("error while scanning count of trip templates", err.Error())
+		return models.TripTemplatesResponse{}, dbError(err)
+	}
+
+	query := tripTemplateSelect + ` ORDER BY tt.created_at DESC LIMIT $1 OFFSET $2`
+
+	rows, err := t.db.QueryContext(ctx, query, req.Limit, offset)
+	if err != nil {
+		fmt.Println("error while querying trip templates", err.Error())
+		return models.TripTemplatesResponse{}, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		template, err := scanTripTemplate(rows)
+		if err != nil {
+			fmt.Println("error while scanning row", err.Error())
+			return models.TripTemplatesResponse{}, dbError(err)
+		}
+		templates = append(templates, template)
+	}
+
+	return models.TripTemplatesResponse{
+		TripTemplates: templates,
+		Count:         count,
+	}, nil
+}
+
+func (t tripTemplateRepo) Update(ctx context.Context, req models.TripTemplate) (string, error) {
+	query := `
+		UPDATE trip_templates
+		SET from_city_id = $1,
+			to_city_id = $2,
+			driver_id = $3,
+			price = $4,
+			weekdays = $5,
+			departure_time = $6,
+			duration_minutes = $7
+		WHERE id = $8
+	`
+
+	if _, err := t.db.ExecContext(ctx, query,
+		req.FromCityID,
+		req.ToCityID,
+		req.DriverID,
+		req.Price,
+		pq.Array(req.Weekdays),
+		req.DepartureTime,
+		req.DurationMinutes,
+		req.ID,
+	); err != nil {
+		fmt.Println("error while updating trip template", err.Error())
+		return "", dbError(err)
+	}
+
+	return req.ID, nil
+}
+
+func (t tripTemplateRepo) Delete(ctx context.Context, id string) error {
+	if _, err := t.db.ExecContext(ctx, `DELETE FROM trip_templates WHERE id = $1`, id); err != nil {
+		fmt.Println("error while deleting trip template", err.Error())
+		return dbError(err)
+	}
+
+	return nil
+}
+
+// GeneratedDates returns the days in [fromDate, toDate] on which a trip of the
+// template already departs, cancelled trips included so they are not recreated
+func (t tripTemplateRepo) GeneratedDates(ctx context.Context, templateID, fromDate, toDate string) ([]string, error) {
+	dates := []string{}
+
+	rows, err := t.db.QueryContext(ctx, `
+		SELECT DISTINCT to_char(departure_at, 'YYYY-MM-DD')
+		FROM trips
+		WHERE template_id = $1 AND departure_at >= $2::date AND departure_at < $3::date + 1
+	`, templateID, fromDate, toDate)
+	if err != nil {
+		fmt.Println("error while querying generated dates", err.Error())
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		date := ""
+		if err := rows.Scan(&date); err != nil {
+			fmt.Println("error while scanning generated date", err.Error())
+			return nil, dbError(err)
+		}
+		dates = append(dates, date)
+	}
+
+	return dates, nil
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"city2city/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// querier runs queries, *sql.DB and *sql.Tx are both one
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txn is a transaction begun by a repo
+type txn interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// conn is what the repos run their queries on, the pool or the transaction
+// of Store.WithTx
+type conn interface {
+	querier
+	Begin() (txn, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (txn, error)
+}
+
+// pool runs the queries on the connections of the pool, a transaction of a
+// repo is a transaction of the database
+type pool struct {
+	*sql.DB
+}
+
+func (p pool) Begin() (txn, error) {
+	return p.BeginTx(context.Background(), nil)
+}
+
+func (p pool) BeginTx(ctx context.Context, opts *sql.TxOptions) (txn, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// txConn runs the queries in a transaction of WithTx. The transactions the
+// repos begin in it are savepoints, so a repo still rolls back only its own
+// changes when it fails and the transaction can go on.
+type txConn struct {
+	txn
+}
+
+// savepointID names the savepoints, the names only have to differ within
+// a transaction
+var savepointID atomic.Int64
+
+func (t txConn) Begin() (txn, error) {
+	return t.BeginTx(context.Background(), nil)
+}
+
+func (t txConn) BeginTx(ctx context.Context, _ *sql.TxOptions) (txn, error) {
+	sp := savepoint{
+		querier: t.txn,
+		name:    fmt.Sprintf("tx_%d", savepointID.Add(1)),
+	}
+
+	if _, err := t.ExecContext(ctx, `SAVEPOINT `+sp.name); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+type savepoint struct {
+	querier
+	name string
+}
+
+func (s savepoint) Commit() error {
+	_, err := s.Exec(`RELEASE SAVEPOINT ` + s.name)
+	return err
+}
+
+func (s savepoint) Rollback() error {
+	_, err := s.Exec(`ROLLBACK TO SAVEPOINT ` + s.name)
+	return err
+}
+
+// WithTx runs fn with a store whose repos run in one transaction. It is
+// committed when fn returns nil, rolled back when fn fails or panics. In
+// the store of a transaction WithTx runs fn in a savepoint.
+func (s Store) WithTx(ctx context.Context, fn func(tx storage.IStorage) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// the pool belongs to the store WithTx was called on
+	txStore := s
+	txStore.db = nil
+	txStore.conn = txConn{tx}
+
+	if err := fn(txStore); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			fmt.Println("error while rolling back transaction", rbErr.Error())
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"city2city/api/models"
+	"context"
 )
 
 type IStorage interface {
@@ -11,54 +12,139 @@ type IStorage interface {
 	Driver() IDriverRepo
 	Car() ICarRepo
 	Trip() ITripRepo
+	TripTemplate() ITripTemplateRepo
+	RouteTariff() IRouteTariffRepo
+	PriceRule() IPriceRuleRepo
+	Payment() IPaymentRepo
+	DriverLedger() IDriverLedgerRepo
+	Report() IReportRepo
+	Import() IImportRepo
+	Auth() IAuthRepo
 	TripCustomer() ITripCustomerRepo
+
+	// WithTx runs fn in one transaction. The changes made through tx are
+	// committed when fn returns nil and rolled back when it returns an error
+	// or panics. fn must only use tx, not the store it was called on, and
+	// must not use tx after it returned. A failed query leaves a postgres
+	// transaction aborted, so fn should return the errors of the repos.
+	WithTx(ctx context.Context, fn func(tx IStorage) error) error
 }
 
 type ICityRepo interface {
-	Create(city models.CreateCity) (string, error)
-	Get(id string) (models.City, error)
-	GetList(req models.GetListRequest) (models.CitiesResponse, error)
-	Update(city models.City) (string, error)
-	Delete(id string) error
+	Create(ctx context.Context, city models.CreateCity) (string, error)
+	Get(ctx context.Context, id string) (models.City, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.CitiesResponse, error)
+	Stream(ctx context.Context, q models.ListQuery, fn func(models.City) error) error
+	Update(ctx context.Context, city models.City) (string, error)
+	Delete(ctx context.Context, id string) error
 }
 
 type ICustomerRepo interface {
-	Create(customer models.CreateCustomer) (string, error)
-	Get(id string) (models.Customer, error)
-	GetList(req models.GetListRequest) (models.CustomersResponse, error)
-	Update(customer models.Customer) (string, error)
-	Delete(id string) error
+	Create(ctx context.Context, customer models.CreateCustomer) (string, error)
+	Get(ctx context.Context, id string) (models.Customer, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.CustomersResponse, error)
+	Stream(ctx context.Context, q models.ListQuery, fn func(models.Customer) error) error
+	Update(ctx context.Context, customer models.Customer) (string, error)
+	Delete(ctx context.Context, id string) error
 }
 
 type IDriverRepo interface {
-	Create(driver models.CreateDriver) (string, error)
-	Get(id models.PrimaryKey) (models.Driver, error)
-	GetList(req models.GetListRequest) (models.DriversResponse, error)
-	Update(driver models.Driver) (string, error)
-	Delete(id models.PrimaryKey) error
+	Create(ctx context.Context, driver models.CreateDriver) (string, error)
+	Get(ctx context.Context, id models.PrimaryKey) (models.Driver, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.DriversResponse, error)
+	Stream(ctx context.Context, q models.ListQuery, fn func(models.Driver) error) error
+	Update(ctx context.Context, driver models.Driver) (string, error)
+	Delete(ctx context.Context, id models.PrimaryKey) error
 }
 
 type ICarRepo interface {
-	Create(car models.CreateCar) (string, error)
-	Get(id string) (models.Car, error)
-	GetList(req models.GetListRequest) (models.CarsResponse, error)
-	Update(car models.Car) (string, error)
-	Delete(id string) error
-	UpdateCarStatus(models.UpdateCarStatus) error
-	}
+	Create(ctx context.Context, car models.CreateCar) (string, error)
+	Get(ctx context.Context, id string) (models.Car, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.CarsResponse, error)
+	Stream(ctx context.Context, q models.ListQuery, fn func(models.Car) error) error
+	Update(ctx context.Context, car models.Car) (string, error)
+	Delete(ctx context.Context, id string) error
+	UpdateCarStatus(ctx context.Context, req models.UpdateCarStatus) error
+}
 
 type ITripRepo interface {
-	Create(trip models.CreateTrip) (string, error)
-	Get(id models.PrimaryKey) (models.Trip, error)
-	GetList(req models.GetListRequest) (models.TripsResponse, error)
-	Update(trip models.Trip) (string, error)
-	Delete(id models.PrimaryKey) error
+	Create(ctx context.Context, trip models.CreateTrip) (string, error)
+	Get(ctx context.Context, id models.PrimaryKey) (models.Trip, error)
+	GetList(ctx context.Context, req models.GetTripListRequest) (models.TripsResponse, error)
+	Stream(ctx context.Context, req models.GetTripListRequest, fn func(models.Trip) error) error
+	Search(ctx context.Context, req models.SearchTripRequest) (models.TripsResponse, error)
+	Update(ctx context.Context, trip models.Trip) (string, error)
+	UpdateStatus(ctx context.Context, req models.UpdateTripStatus) error
+	Delete(ctx context.Context, id models.PrimaryKey) error
+}
+
+type ITripTemplateRepo interface {
+	Create(ctx context.Context, template models.CreateTripTemplate) (string, error)
+	Get(ctx context.Context, id string) (models.TripTemplate, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.TripTemplatesResponse, error)
+	Update(ctx context.Context, template models.TripTemplate) (string, error)
+	Delete(ctx context.Context, id string) error
+	GeneratedDates(ctx context.Context, templateID, fromDate, toDate string) ([]string, error)
+}
+
+type IRouteTariffRepo interface {
+	Create(ctx context.Context, tariff models.CreateRouteTariff) (string, error)
+	Get(ctx context.Context, id string) (models.RouteTariff, error)
+	GetList(ctx context.Context, req models.GetRouteTariffListRequest) (models.RouteTariffsResponse, error)
+	GetActive(ctx context.Context, fromCityID, toCityID, at string) (models.RouteTariff, error)
+}
+
+type IPriceRuleRepo interface {
+	Create(ctx context.Context, rule models.CreatePriceRule) (string, error)
+	Get(ctx context.Context, id string) (models.PriceRule, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.PriceRulesResponse, error)
+	Update(ctx context.Context, rule models.PriceRule) (string, error)
+	Delete(ctx context.Context, id string) error
 }
 
 type ITripCustomerRepo interface {
-	Create(tripCustomer models.CreateTripCustomer) (string, error)
-	Get(id string) (models.TripCustomer, error)
-	GetList(req models.GetListRequest) (models.TripCustomersResponse, error)
-	Update(tripCustomer models.TripCustomer) (string, error)
-	Delete(id string) error
-}
\ No newline at end of file
+	Create(ctx context.Context, tripCustomer models.CreateTripCustomer) (string, error)
+	Get(ctx context.Context, id string) (models.TripCustomer, error)
+	GetList(ctx context.Context, req models.GetListRequest) (models.TripCustomersResponse, error)
+	Stream(ctx context.Context, q models.ListQuery, fn func(models.TripCustomer) error) error
+	Update(ctx context.Context, tripCustomer models.TripCustomer) (string, error)
+	Delete(ctx context.Context, id string) error
+	Cancel(ctx context.Context, req models.CancelTripCustomer) error
+	GetByTrip(ctx context.Context, tripID string) (models.TripCustomersResponse, error)
+	GetUnpaid(ctx context.Context, tripID string) (models.TripCustomersResponse, error)
+	GetTripTotals(ctx context.Context, tripID string) (models.TripTotals, error)
+}
+
+type IPaymentRepo interface {
+	Create(ctx context.Context, payment models.CreatePayment) (string, error)
+	Get(ctx context.Context, id string) (models.Payment, error)
+	GetList(ctx context.Context, req models.GetPaymentListRequest) (models.PaymentsResponse, error)
+	UpdateStatus(ctx context.Context, req models.UpdatePaymentStatus) error
+}
+
+type IDriverLedgerRepo interface {
+	CreatePayout(ctx context.Context, payout models.CreatePayout) (string, error)
+	GetBalance(ctx context.Context, driverID string) (models.DriverBalance, error)
+	GetStatement(ctx context.Context, req models.GetStatementRequest) (models.DriverStatement, error)
+}
+
+type IReportRepo interface {
+	Routes(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error)
+	Drivers(ctx context.Context, req models.ReportRequest) ([]models.ReportRow, error)
+}
+
+type IImportRepo interface {
+	Import(ctx context.Context, req models.Import) (models.ImportResult, error)
+}
+
+type IAuthRepo interface {
+	CreateAdmin(ctx context.Context, login, passwordHash, role string) (string, error)
+	GetAdminByLogin(ctx context.Context, login string) (models.Admin, error)
+	// UserIDByPhone returns the id of the customer or driver with the phone
+	UserIDByPhone(ctx context.Context, role, phone string) (string, error)
+	UserExists(ctx context.Context, role, id string) (bool, error)
+	SaveOTP(ctx context.Context, otp models.OTP) error
+	GetOTP(ctx context.Context, phone, role string) (models.OTP, error)
+	AddOTPAttempt(ctx context.Context, phone, role string) error
+	DeleteOTP(ctx context.Context, phone, role string) error
+}
@@ -0,0 +1,1440 @@
+// Package storagetest is the conformance suite of storage.IStorage. Every
+// backend has to pass it, so the handlers behave the same whichever store
+// they run on. A backend runs it from a test of its own:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.IStorage {
+//			return memory.New(config.Config{})
+//		})
+//	}
+//
+// newStore must return an empty store for every call, a postgres backend
+// truncates its tables before returning it.
+package storagetest
+
+import (
+	"city2city/api/models"
+	"city2city/storage"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run runs every test of the suite against stores made by newStore
+func Run(t *testing.T, newStore func(t *testing.T) storage.IStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.IStorage)
+	}{
+		{"City", testCity},
+		{"Pagination", testPagination},
+		{"ListQuery", testListQuery},
+		{"Customer", testCustomer},
+		{"Driver", testDriver},
+		{"Car", testCar},
+		{"Trip", testTrip},
+		{"TripSearch", testTripSearch},
+		{"TripStatus", testTripStatus},
+		{"Booking", testBooking},
+		{"ConcurrentBookings", testConcurrentBookings},
+		{"Payment", testPayment},
+		{"DriverLedger", testDriverLedger},
+		{"RouteTariff", testRouteTariff},
+		{"PriceRule", testPriceRule},
+		{"Report", testReport},
+		{"TripTemplate", testTripTemplate},
+		{"Import", testImport},
+		{"Auth", testAuth},
+		{"Context", testContext},
+		{"Tx", testTx},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newStore(t)
+			defer s.CloseDB()
+
+			defer func() {
+				if r := recover(); r != nil {
+					f, ok := r.(failure)
+					if !ok {
+						panic(r)
+					}
+					t.Fatalf("unexpected error: %v", f.err)
+				}
+			}()
+
+			test.fn(t, s)
+		})
+	}
+}
+
+// timestamp formats a time the way the api sends timestamps
+func timestamp(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// failure is the panic of must, Run turns it into a failed test
+type failure struct {
+	err error
+}
+
+// must returns the value of a call which should not fail
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(failure{err})
+	}
+	return value
+}
+
+func wantKind(t *testing.T, err error, kind storage.ErrorKind) {
+	t.Helper()
+	if !storage.IsKind(err, kind) {
+		t.Fatalf("want a %s error, got %v", kind, err)
+	}
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("want error %v, got %v", want, err)
+	}
+}
+
+// fixture is a route with a driver who has a car
+type fixture struct {
+	from, to models.City
+	driver   models.Driver
+	car      models.Car
+}
+
+func newFixture(t *testing.T, s storage.IStorage, phone string) fixture {
+	t.Helper()
+
+	f := fixture{}
+	fromID := must(s.City().Create(t.Context(), models.CreateCity{Name: "Tashkent"}))
+	f.from = must(s.City().Get(t.Context(), fromID))
+
+	toID := must(s.City().Create(t.Context(), models.CreateCity{Name: "Samarkand"}))
+	f.to = must(s.City().Get(t.Context(), toID))
+
+	driverID := must(s.Driver().Create(t.Context(), models.CreateDriver{
+		FullName:   "Driver " + phone,
+		Phone:      phone,
+		FromCityID: f.from.ID,
+		ToCityID:   f.to.ID,
+	}))
+	f.driver = must(s.Driver().Get(t.Context(), models.PrimaryKey{ID: driverID}))
+
+	carID := must(s.Car().Create(t.Context(), models.CreateCar{
+		Model:    "Cobalt",
+		Brand:    "Chevrolet",
+		Number:   "01A" + phone[len(phone)-3:],
+		Seats:    3,
+		DriverID: f.driver.ID,
+	}))
+	f.car = must(s.Car().Get(t.Context(), carID))
+
+	return f
+}
+
+// trip creates a trip of the fixture departing in the given time from now
+func (f fixture) trip(t *testing.T, s storage.IStorage, in time.Duration, price int) models.Trip {
+	t.Helper()
+
+	departure := time.Now().Add(in)
+	id := must(s.Trip().Create(t.Context(), models.CreateTrip{
+		FromCityID:  f.from.ID,
+		ToCityID:    f.to.ID,
+		DriverID:    f.driver.ID,
+		Price:       price,
+		DepartureAt: timestamp(departure),
+		ArrivalAt:   timestamp(departure.Add(4 * time.Hour)),
+	}))
+
+	return must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: id}))
+}
+
+func newCustomer(t *testing.T, s storage.IStorage, phone string) models.Customer {
+	t.Helper()
+
+	id := must(s.Customer().Create(t.Context(), models.CreateCustomer{
+		FullName: "Customer " + phone,
+		Phone:    phone,
+		Email:    phone + "@example.com",
+	}))
+
+	return must(s.Customer().Get(t.Context(), id))
+}
+
+func testCity(t *testing.T, s storage.IStorage) {
+	id := must(s.City().Create(t.Context(), models.CreateCity{Name: "Bukhara"}))
+
+	city := must(s.City().Get(t.Context(), id))
+	if city.ID != id || city.Name != "Bukhara" || city.CreatedAt == "" {
+		t.Fatalf("unexpected city %+v", city)
+	}
+
+	must(s.City().Update(t.Context(), models.City{ID: id, Name: "Khiva city"}))
+	if city := must(s.City().Get(t.Context(), id)); city.Name != "Khiva city" {
+		t.Fatalf("city was not updated: %+v", city)
+	}
+
+	_, err := s.City().Create(t.Context(), models.CreateCity{Name: "Ab"})
+	wantKind(t, err, storage.KindValidation)
+
+	_, err = s.City().Get(t.Context(), "not a uuid")
+	wantKind(t, err, storage.KindValidation)
+
+	if err := s.City().Delete(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.City().Get(t.Context(), id)
+	wantKind(t, err, storage.KindNotFound)
+}
+
+func testPagination(t *testing.T, s storage.IStorage) {
+	for i := 0; i < 5; i++ {
+		must(s.City().Create(t.Context(), models.CreateCity{Name: fmt.Sprintf("City %d", i)}))
+	}
+
+	seen := map[string]bool{}
+	for page := 1; page <= 3; page++ {
+		cities := must(s.City().GetList(t.Context(), models.GetListRequest{Page: page, Limit: 2}))
+		if cities.Count != 5 {
+			t.Fatalf("page %d: want count 5, got %d", page, cities.Count)
+		}
+
+		want := 2
+		if page == 3 {
+			want = 1
+		}
+		if len(cities.Cities) != want {
+			t.Fatalf("page %d: want %d cities, got %d", page, want, len(cities.Cities))
+		}
+
+		for _, city := range cities.Cities {
+			if seen[city.ID] {
+				t.Fatalf("page %d: city %s was on an earlier page", page, city.Name)
+			}
+			seen[city.ID] = true
+		}
+	}
+
+	cities := must(s.City().GetList(t.Context(), models.GetListRequest{Page: 4, Limit: 2}))
+	if len(cities.Cities) != 0 || cities.Count != 5 {
+		t.Fatalf("want an empty page past the end, got %+v", cities)
+	}
+
+	if _, err := s.City().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: -1}); err == nil {
+		t.Fatal("want an error for a negative limit")
+	}
+
+	if _, err := s.City().GetList(t.Context(), models.GetListRequest{Page: 0, Limit: 2}); err == nil {
+		t.Fatal("want an error for a negative offset")
+	}
+}
+
+func testListQuery(t *testing.T, s storage.IStorage) {
+	for _, name := range []string{"Samarkand", "Andijan", "Bukhara", "Navoiy"} {
+		must(s.City().Create(t.Context(), models.CreateCity{Name: name}))
+	}
+
+	cities := must(s.City().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     2,
+		ListQuery: models.ListQuery{Sort: []models.SortField{{Field: "name", Desc: true}}},
+	}))
+	if cities.Count != 4 || len(cities.Cities) != 2 || cities.Cities[0].Name != "Samarkand" || cities.Cities[1].Name != "Navoiy" {
+		t.Fatalf("want Samarkand and Navoiy of 4 cities, got %+v", cities)
+	}
+
+	// the search ignores case and matches a part of the name, the count is
+	// the one of the matching rows
+	cities = must(s.City().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Search: "AN", Sort: []models.SortField{{Field: "name"}}},
+	}))
+	if cities.Count != 2 || len(cities.Cities) != 2 || cities.Cities[0].Name != "Andijan" || cities.Cities[1].Name != "Samarkand" {
+		t.Fatalf("want Andijan and Samarkand, got %+v", cities)
+	}
+
+	// wildcards of LIKE are searched as they are
+	cities = must(s.City().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10, ListQuery: models.ListQuery{Search: "%"}}))
+	if cities.Count != 0 {
+		t.Fatalf("want no city with %%, got %+v", cities)
+	}
+
+	// a list which is not sorted is ordered by creation and then by id
+	cities = must(s.City().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10}))
+	for i := 1; i < len(cities.Cities); i++ {
+		prev, city := cities.Cities[i-1], cities.Cities[i]
+		c := must(time.Parse(time.RFC3339Nano, prev.CreatedAt)).Compare(must(time.Parse(time.RFC3339Nano, city.CreatedAt)))
+		if c > 0 || (c == 0 && prev.ID > city.ID) {
+			t.Fatalf("want the cities by created_at and id, got %+v", cities)
+		}
+	}
+
+	// the export takes the filters, the search and the sort of the list
+	streamed := []string{}
+	if err := s.City().Stream(t.Context(), models.ListQuery{Search: "an", Sort: []models.SortField{{Field: "name", Desc: true}}}, func(city models.City) error {
+		streamed = append(streamed, city.Name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 2 || streamed[0] != "Samarkand" || streamed[1] != "Andijan" {
+		t.Fatalf("want Samarkand and Andijan, got %v", streamed)
+	}
+
+	wantKind(t, s.City().Stream(t.Context(), models.ListQuery{Filters: map[string]string{"size": "big"}}, func(models.City) error {
+		return nil
+	}), storage.KindValidation)
+
+	f := newFixture(t, s, "+998901120101")
+	for _, car := range []models.CreateCar{
+		{Model: "Malibu", Brand: "Chevrolet", Number: "01B101", Seats: 4},
+		{Model: "K5", Brand: "Kia", Number: "01B102", Seats: 4},
+	} {
+		car.DriverID = f.driver.ID
+		id := must(s.Car().Create(t.Context(), car))
+		if err := s.Car().UpdateCarStatus(t.Context(), models.UpdateCarStatus{ID: id, Status: false}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	cars := must(s.Car().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Filters: map[string]string{"brand": "Chevrolet", "status": "false"}},
+	}))
+	if cars.Count != 1 || len(cars.Cars) != 1 || cars.Cars[0].Model != "Malibu" {
+		t.Fatalf("want the Malibu, got %+v", cars)
+	}
+
+	cars = must(s.Car().GetList(t.Context(), models.GetListRequest{
+		Page:  2,
+		Limit: 1,
+		ListQuery: models.ListQuery{
+			Filters: map[string]string{"driver_id": f.driver.ID},
+			Search:  "01b1",
+			Sort:    []models.SortField{{Field: "seats", Desc: true}, {Field: "model"}},
+		},
+	}))
+	if cars.Count != 2 || len(cars.Cars) != 1 || cars.Cars[0].Model != "Malibu" {
+		t.Fatalf("want the Malibu on the second page of 2 cars, got %+v", cars)
+	}
+
+	// cars with the same seats come by id, the same on every page
+	cars = must(s.Car().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Sort: []models.SortField{{Field: "seats", Desc: true}}},
+	}))
+	if len(cars.Cars) != 3 || cars.Cars[0].Seats != 4 || cars.Cars[1].Seats != 4 || cars.Cars[0].ID > cars.Cars[1].ID {
+		t.Fatalf("want the cars with 4 seats by id first, got %+v", cars)
+	}
+
+	trip := f.trip(t, s, 24*time.Hour, 150)
+	f.trip(t, s, 48*time.Hour, 120)
+
+	trips := must(s.Trip().GetList(t.Context(), models.GetTripListRequest{
+		Page:  1,
+		Limit: 10,
+		ListQuery: models.ListQuery{
+			Filters: map[string]string{"price": "150"},
+			Search:  "samarkand",
+		},
+	}))
+	if trips.Count != 1 || len(trips.Trips) != 1 || trips.Trips[0].ID != trip.ID {
+		t.Fatalf("want the trip for 150, got %+v", trips)
+	}
+
+	trips = must(s.Trip().GetList(t.Context(), models.GetTripListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Sort: []models.SortField{{Field: "price"}}},
+	}))
+	if len(trips.Trips) != 2 || trips.Trips[0].Price != 120 {
+		t.Fatalf("want the trip for 120 first, got %+v", trips)
+	}
+
+	_, err := s.Car().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Filters: map[string]string{"driver_phone": "+998901120101"}},
+	})
+	wantKind(t, err, storage.KindValidation)
+
+	_, err = s.Customer().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Sort: []models.SortField{{Field: "password"}}},
+	})
+	wantKind(t, err, storage.KindValidation)
+
+	_, err = s.Car().GetList(t.Context(), models.GetListRequest{
+		Page:      1,
+		Limit:     10,
+		ListQuery: models.ListQuery{Filters: map[string]string{"seats": "many"}},
+	})
+	wantKind(t, err, storage.KindValidation)
+}
+
+func testCustomer(t *testing.T, s storage.IStorage) {
+	customer := newCustomer(t, s, "+998901000001")
+	if customer.FullName != "Customer +998901000001" || customer.Email != "+998901000001@example.com" {
+		t.Fatalf("unexpected customer %+v", customer)
+	}
+
+	_, err := s.Customer().Create(t.Context(), models.CreateCustomer{FullName: "Other", Phone: customer.Phone, Email: "other@example.com"})
+	wantKind(t, err, storage.KindConflict)
+
+	other := newCustomer(t, s, "+998901000002")
+	_, err = s.Customer().Update(t.Context(), models.Customer{ID: other.ID, FullName: "Other", Phone: customer.Phone, Email: other.Email})
+	wantKind(t, err, storage.KindConflict)
+
+	count := 0
+	if err := s.Customer().Stream(t.Context(), models.ListQuery{}, func(models.Customer) error { count++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("want 2 streamed customers, got %d", count)
+	}
+}
+
+func testDriver(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998902000001")
+
+	driver := f.driver
+	if driver.FromCityData.ID != f.from.ID || driver.FromCityData.Name != f.from.Name {
+		t.Fatalf("want from city data %+v, got %+v", f.from, driver.FromCityData)
+	}
+	if driver.ToCityData.ID != f.to.ID || driver.ToCityData.Name != f.to.Name {
+		t.Fatalf("want to city data %+v, got %+v", f.to, driver.ToCityData)
+	}
+
+	drivers := must(s.Driver().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10}))
+	if drivers.Count != 1 || len(drivers.Drivers) != 1 || drivers.Drivers[0].FromCityData.Name != f.from.Name {
+		t.Fatalf("unexpected drivers %+v", drivers)
+	}
+
+	_, err := s.Driver().Create(t.Context(), models.CreateDriver{FullName: "Other", Phone: "+998902000002", FromCityID: f.from.ID, ToCityID: "00000000-0000-0000-0000-000000000001"})
+	wantKind(t, err, storage.KindForeignKey)
+
+	_, err = s.Driver().Create(t.Context(), models.CreateDriver{FullName: "Other", Phone: driver.Phone, FromCityID: f.from.ID, ToCityID: f.to.ID})
+	wantKind(t, err, storage.KindConflict)
+
+	// the driver has a car and the cities have a driver
+	wantKind(t, s.Driver().Delete(t.Context(), models.PrimaryKey{ID: driver.ID}), storage.KindForeignKey)
+	wantKind(t, s.City().Delete(t.Context(), f.from.ID), storage.KindForeignKey)
+}
+
+func testCar(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998903000001")
+
+	car := f.car
+	if car.DriverID != f.driver.ID || car.DriverData.FullName != f.driver.FullName || car.DriverData.Phone != f.driver.Phone {
+		t.Fatalf("unexpected car driver data %+v", car)
+	}
+	if car.Seats != 3 || car.Status != "true" {
+		t.Fatalf("unexpected car %+v", car)
+	}
+
+	id := must(s.Car().Create(t.Context(), models.CreateCar{Model: "Nexia", Brand: "Chevrolet", Number: "01B001", DriverID: f.driver.ID}))
+	if car := must(s.Car().Get(t.Context(), id)); car.Seats != 4 {
+		t.Fatalf("want the default 4 seats, got %d", car.Seats)
+	}
+
+	must(s.Car().Update(t.Context(), models.Car{ID: id, Model: "Nexia 3", Brand: "Chevrolet", Number: "01B001", DriverID: f.driver.ID}))
+	if car := must(s.Car().Get(t.Context(), id)); car.Model != "Nexia 3" || car.Seats != 4 {
+		t.Fatalf("want an updated model and kept seats, got %+v", car)
+	}
+
+	if err := s.Car().UpdateCarStatus(t.Context(), models.UpdateCarStatus{ID: id, Status: false}); err != nil {
+		t.Fatal(err)
+	}
+	if car := must(s.Car().Get(t.Context(), id)); car.Status != "false" {
+		t.Fatalf("want status false, got %s", car.Status)
+	}
+
+	_, err := s.Car().Create(t.Context(), models.CreateCar{Model: "Spark", Brand: "Chevrolet", Number: car.Number, DriverID: f.driver.ID})
+	wantKind(t, err, storage.KindConflict)
+
+	_, err = s.Car().Create(t.Context(), models.CreateCar{Model: "Spark", Brand: "Chevrolet", Number: "01C001", Seats: -1, DriverID: f.driver.ID})
+	wantKind(t, err, storage.KindValidation)
+
+	cars := must(s.Car().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10}))
+	if cars.Count != 2 || len(cars.Cars) != 2 {
+		t.Fatalf("want 2 cars, got %+v", cars)
+	}
+}
+
+func testTrip(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998904000001")
+
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+	if trip.TripNumberID == "" || trip.Status != models.TripStatusScheduled {
+		t.Fatalf("unexpected trip %+v", trip)
+	}
+	if trip.Seats != f.car.Seats || trip.FreeSeats != f.car.Seats {
+		t.Fatalf("want the %d seats of the car, got %d and %d free", f.car.Seats, trip.Seats, trip.FreeSeats)
+	}
+	if trip.FromCityData.Name != f.from.Name || trip.ToCityData.Name != f.to.Name {
+		t.Fatalf("unexpected city data %+v %+v", trip.FromCityData, trip.ToCityData)
+	}
+	if trip.DriverData.ID != f.driver.ID || trip.DriverData.FromCityData.Name != f.from.Name {
+		t.Fatalf("unexpected driver data %+v", trip.DriverData)
+	}
+
+	// the driver is on the road at that time
+	departure := time.Now().Add(49 * time.Hour)
+	_, err := s.Trip().Create(t.Context(), models.CreateTrip{
+		FromCityID:  f.to.ID,
+		ToCityID:    f.from.ID,
+		DriverID:    f.driver.ID,
+		Price:       100000,
+		DepartureAt: timestamp(departure),
+		ArrivalAt:   timestamp(departure.Add(time.Hour)),
+	})
+	wantErr(t, err, storage.ErrDriverBusy)
+
+	// no tariff for a trip without a price
+	_, err = s.Trip().Create(t.Context(), models.CreateTrip{
+		FromCityID:  f.from.ID,
+		ToCityID:    f.to.ID,
+		DriverID:    f.driver.ID,
+		DepartureAt: timestamp(departure.Add(24 * time.Hour)),
+		ArrivalAt:   timestamp(departure.Add(25 * time.Hour)),
+	})
+	wantErr(t, err, storage.ErrNoTariff)
+
+	carless := must(s.Driver().Create(t.Context(), models.CreateDriver{FullName: "No car", Phone: "+998904000002", FromCityID: f.from.ID, ToCityID: f.to.ID}))
+	_, err = s.Trip().Create(t.Context(), models.CreateTrip{
+		FromCityID:  f.from.ID,
+		ToCityID:    f.to.ID,
+		DriverID:    carless,
+		Price:       100000,
+		DepartureAt: timestamp(departure),
+		ArrivalAt:   timestamp(departure.Add(time.Hour)),
+	})
+	wantErr(t, err, storage.ErrDriverNoCar)
+
+	second := f.trip(t, s, 96*time.Hour, 90000)
+
+	trips := must(s.Trip().GetList(t.Context(), models.GetTripListRequest{Page: 1, Limit: 10, DriverID: f.driver.ID}))
+	if trips.Count != 2 || len(trips.Trips) != 2 || trips.Trips[0].ID != second.ID {
+		t.Fatalf("want the newest trip first, got %+v", trips)
+	}
+
+	trips = must(s.Trip().GetList(t.Context(), models.GetTripListRequest{Page: 1, Limit: 10, Status: models.TripStatusCancelled}))
+	if trips.Count != 0 {
+		t.Fatalf("want no cancelled trips, got %d", trips.Count)
+	}
+
+	streamed := []string{}
+	if err := s.Trip().Stream(t.Context(), models.GetTripListRequest{}, func(trip models.Trip) error {
+		streamed = append(streamed, trip.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 2 || streamed[0] != trip.ID {
+		t.Fatalf("want the trips by departure, got %v", streamed)
+	}
+
+	wantKind(t, s.Driver().Delete(t.Context(), models.PrimaryKey{ID: f.driver.ID}), storage.KindForeignKey)
+	if err := s.Trip().Delete(t.Context(), models.PrimaryKey{ID: second.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a trip given to another driver takes the seats of their car, as long
+	// as the bookings fit in it
+	changed := f.trip(t, s, 150*time.Hour, 100000)
+	customer := newCustomer(t, s, "+998904000005")
+	must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: changed.ID, CustomerID: customer.ID, Seats: 2}))
+
+	newDriver := func(phone string, seats int) string {
+		id := must(s.Driver().Create(t.Context(), models.CreateDriver{FullName: "Driver " + phone, Phone: phone, FromCityID: f.from.ID, ToCityID: f.to.ID}))
+		must(s.Car().Create(t.Context(), models.CreateCar{Model: "Damas", Brand: "Chevrolet", Number: "01B" + phone[len(phone)-3:], Seats: seats, DriverID: id}))
+		return id
+	}
+
+	update := changed
+	update.DriverID = newDriver("+998904000003", 1)
+	_, err = s.Trip().Update(t.Context(), update)
+	wantErr(t, err, storage.ErrCarTooSmall)
+
+	update.DriverID = newDriver("+998904000004", 5)
+	must(s.Trip().Update(t.Context(), update))
+	if got := must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: changed.ID})); got.DriverID != update.DriverID || got.Seats != 5 || got.FreeSeats != 3 {
+		t.Fatalf("want the 5 seats of the new car with 3 free, got %d with %d free", got.Seats, got.FreeSeats)
+	}
+
+	// only scheduled trips can be changed
+	if err := s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: changed.ID, Status: models.TripStatusBoarding}); err != nil {
+		t.Fatal(err)
+	}
+	update.Price = 110000
+	_, err = s.Trip().Update(t.Context(), update)
+	wantErr(t, err, storage.ErrTripNotEditable)
+
+	update.ID = "7d2b8a4e-3c1f-4e55-8a90-1f6b2d3c4e5f"
+	_, err = s.Trip().Update(t.Context(), update)
+	wantKind(t, err, storage.KindNotFound)
+}
+
+func testTripSearch(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998905000001")
+
+	cheap := f.trip(t, s, 48*time.Hour, 80000)
+	dear := f.trip(t, s, 72*time.Hour, 120000)
+	f.trip(t, s, 240*time.Hour, 80000)
+
+	found := must(s.Trip().Search(t.Context(), models.SearchTripRequest{
+		FromCityID:    f.from.ID,
+		ToCityID:      f.to.ID,
+		DepartureFrom: timestamp(time.Now()),
+		DepartureTo:   timestamp(time.Now().Add(120 * time.Hour)),
+		Page:          1,
+		Limit:         10,
+	}))
+	if found.Count != 2 || found.Trips[0].ID != cheap.ID || found.Trips[1].ID != dear.ID {
+		t.Fatalf("want the two trips of the window by departure, got %+v", found)
+	}
+
+	// a window given in another time zone keeps its offset
+	departure := must(time.Parse(time.RFC3339Nano, cheap.DepartureAt))
+	zone := time.FixedZone("UTC+9", 9*60*60)
+	found = must(s.Trip().Search(t.Context(), models.SearchTripRequest{
+		DepartureFrom: departure.Add(-time.Minute).In(zone).Format(time.RFC3339),
+		DepartureTo:   departure.Add(time.Minute).In(zone).Format(time.RFC3339),
+		Page:          1,
+		Limit:         10,
+	}))
+	if found.Count != 1 || found.Trips[0].ID != cheap.ID {
+		t.Fatalf("want the trip departing in the window, got %+v", found)
+	}
+
+	found = must(s.Trip().Search(t.Context(), models.SearchTripRequest{
+		DepartureFrom: timestamp(time.Now()),
+		MaxPrice:      100000,
+		Page:          1,
+		Limit:         10,
+	}))
+	if found.Count != 2 {
+		t.Fatalf("want 2 trips up to the max price, got %d", found.Count)
+	}
+
+	found = must(s.Trip().Search(t.Context(), models.SearchTripRequest{
+		DepartureFrom: timestamp(time.Now()),
+		MinFreeSeats:  f.car.Seats + 1,
+		Page:          1,
+		Limit:         10,
+	}))
+	if found.Count != 0 {
+		t.Fatalf("want no trip with more free seats than the car has, got %d", found.Count)
+	}
+}
+
+func testTripStatus(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998906000001")
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+
+	wantErr(t, s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: trip.ID, Status: models.TripStatusCompleted}), storage.ErrInvalidTripStatus)
+	wantErr(t, s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: trip.ID, Status: "flying"}), storage.ErrUnknownTripStatus)
+
+	for _, status := range []string{models.TripStatusBoarding, models.TripStatusInProgress, models.TripStatusCompleted} {
+		if err := s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: trip.ID, Status: status}); err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+	}
+
+	trip = must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: trip.ID}))
+	if trip.Status != models.TripStatusCompleted || trip.BoardingAt == nil || trip.StartedAt == nil || trip.CompletedAt == nil || trip.CancelledAt != nil {
+		t.Fatalf("unexpected status times %+v", trip)
+	}
+
+	missing := models.PrimaryKey{ID: "00000000-0000-0000-0000-000000000000"}
+	wantKind(t, s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: missing.ID, Status: models.TripStatusBoarding}), storage.KindNotFound)
+}
+
+func testBooking(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998907000001")
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+	customer := newCustomer(t, s, "+998907000002")
+
+	id := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID, Seats: 2}))
+
+	booking := must(s.TripCustomer().Get(t.Context(), id))
+	if booking.Seats != 2 || booking.Status != models.BookingStatusBooked || booking.PaymentStatus != models.BookingUnpaid || booking.Fare != 200000 {
+		t.Fatalf("unexpected booking %+v", booking)
+	}
+	if booking.CustomerData.ID != customer.ID || booking.CustomerData.Phone != customer.Phone {
+		t.Fatalf("unexpected customer data %+v", booking.CustomerData)
+	}
+
+	if trip := must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: trip.ID})); trip.FreeSeats != f.car.Seats-2 {
+		t.Fatalf("want %d free seats, got %d", f.car.Seats-2, trip.FreeSeats)
+	}
+
+	_, err := s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID, Seats: 2})
+	wantErr(t, err, storage.ErrTripFull)
+
+	totals := must(s.TripCustomer().GetTripTotals(t.Context(), trip.ID))
+	if totals.Bookings != 1 || totals.Passengers != 2 || totals.TotalFare != 200000 {
+		t.Fatalf("unexpected totals %+v", totals)
+	}
+
+	if unpaid := must(s.TripCustomer().GetUnpaid(t.Context(), trip.ID)); unpaid.Count != 1 {
+		t.Fatalf("want 1 unpaid booking, got %d", unpaid.Count)
+	}
+
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: id, Reason: "changed plans"}); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: id}), storage.ErrBookingCancelled)
+
+	booking = must(s.TripCustomer().Get(t.Context(), id))
+	if booking.Status != models.BookingStatusCancelled || booking.CancelReason == nil || *booking.CancelReason != "changed plans" || booking.CancelledAt == nil {
+		t.Fatalf("unexpected cancelled booking %+v", booking)
+	}
+
+	// cancelled bookings stay in the trip list but free their seats
+	if byTrip := must(s.TripCustomer().GetByTrip(t.Context(), trip.ID)); byTrip.Count != 1 {
+		t.Fatalf("want 1 booking of the trip, got %d", byTrip.Count)
+	}
+	if trip := must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: trip.ID})); trip.FreeSeats != f.car.Seats {
+		t.Fatalf("want %d free seats, got %d", f.car.Seats, trip.FreeSeats)
+	}
+
+	wantKind(t, s.Customer().Delete(t.Context(), customer.ID), storage.KindForeignKey)
+
+	if err := s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: trip.ID, Status: models.TripStatusCancelled}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID})
+	wantErr(t, err, storage.ErrTripNotBookable)
+}
+
+func testConcurrentBookings(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998908000001")
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+
+	customers := []models.Customer{}
+	for i := 0; i < 2*f.car.Seats; i++ {
+		customers = append(customers, newCustomer(t, s, fmt.Sprintf("+9989080001%02d", i)))
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		booked = 0
+	)
+	for _, customer := range customers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID})
+			if err != nil && !errors.Is(err, storage.ErrTripFull) {
+				t.Error(err)
+				return
+			}
+
+			if err == nil {
+				mu.Lock()
+				booked++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if booked != f.car.Seats {
+		t.Fatalf("want %d bookings of a %d seat trip, got %d", f.car.Seats, f.car.Seats, booked)
+	}
+
+	if trip := must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: trip.ID})); trip.FreeSeats != 0 {
+		t.Fatalf("want no free seats, got %d", trip.FreeSeats)
+	}
+}
+
+func testPayment(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998909000001")
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+	customer := newCustomer(t, s, "+998909000002")
+	booking := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID}))
+
+	id := must(s.Payment().Create(t.Context(), models.CreatePayment{
+		TripCustomerID: booking,
+		Provider:       "cash",
+		Amount:         100000,
+		Currency:       models.CurrencyUZS,
+		Status:         models.PaymentStatusCaptured,
+	}))
+
+	payment := must(s.Payment().Get(t.Context(), id))
+	if payment.TripCustomerID != booking || payment.Amount != 100000 || payment.Status != models.PaymentStatusCaptured {
+		t.Fatalf("unexpected payment %+v", payment)
+	}
+
+	if booking := must(s.TripCustomer().Get(t.Context(), booking)); booking.PaymentStatus != models.BookingPaid {
+		t.Fatalf("want a paid booking, got %s", booking.PaymentStatus)
+	}
+
+	_, err := s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Amount: 100000, Status: models.PaymentStatusAuthorized})
+	wantErr(t, err, storage.ErrPaymentExists)
+
+	wantErr(t, s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusCaptured, Status: models.PaymentStatusCaptured}), storage.ErrInvalidPaymentStatus)
+	wantKind(t, s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusCaptured, Status: "stolen"}), storage.KindValidation)
+
+	// a claimed payment can not be claimed again until it is released
+	refunding := models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusCaptured, Status: models.PaymentStatusRefunding}
+	if err := s.Payment().UpdateStatus(t.Context(), refunding); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, s.Payment().UpdateStatus(t.Context(), refunding), storage.ErrInvalidPaymentStatus)
+
+	_, err = s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Amount: 100000, Status: models.PaymentStatusAuthorized})
+	wantErr(t, err, storage.ErrPaymentExists)
+
+	if err := s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusRefunding, Status: models.PaymentStatusCaptured}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Payment().UpdateStatus(t.Context(), refunding); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusRefunding, Status: models.PaymentStatusRefunded, RefundedAmount: 100000}); err != nil {
+		t.Fatal(err)
+	}
+	// the booking is still active, so it can be paid again
+	if booking := must(s.TripCustomer().Get(t.Context(), booking)); booking.PaymentStatus != models.BookingUnpaid {
+		t.Fatalf("want an unpaid booking, got %s", booking.PaymentStatus)
+	}
+
+	wantErr(t, s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: id, From: models.PaymentStatusRefunding, Status: models.PaymentStatusRefunded, RefundedAmount: 100000}), storage.ErrInvalidPaymentStatus)
+	wantKind(t, s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: "00000000-0000-0000-0000-000000000000", From: models.PaymentStatusCaptured, Status: models.PaymentStatusRefunding}), storage.KindNotFound)
+
+	payments := must(s.Payment().GetList(t.Context(), models.GetPaymentListRequest{Page: 1, Limit: 10, TripCustomerID: booking}))
+	if payments.Count != 1 || payments.Payments[0].RefundedAmount != 100000 {
+		t.Fatalf("unexpected payments %+v", payments)
+	}
+
+	_, err = s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Status: "stolen"})
+	wantKind(t, err, storage.KindValidation)
+
+	// cancelling the booking voids its authorized payment, a cancelled
+	// booking can not be paid
+	authorized := must(s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Amount: 100000, Currency: models.CurrencyUZS, Status: models.PaymentStatusAuthorized}))
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: booking, Reason: "changed plans"}); err != nil {
+		t.Fatal(err)
+	}
+	if payment := must(s.Payment().Get(t.Context(), authorized)); payment.Status != models.PaymentStatusVoided {
+		t.Fatalf("want a voided payment, got %s", payment.Status)
+	}
+
+	_, err = s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Amount: 100000, Status: models.PaymentStatusAuthorized})
+	wantErr(t, err, storage.ErrBookingCancelled)
+
+	// a refund of a cancelled booking leaves it refunded
+	other := newCustomer(t, s, "+998909000003")
+	paid := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: other.ID}))
+	captured := must(s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: paid, Provider: "cash", Amount: 100000, Currency: models.CurrencyUZS, Status: models.PaymentStatusCaptured}))
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: paid, Reason: "changed plans"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: captured, From: models.PaymentStatusCaptured, Status: models.PaymentStatusRefunding}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Payment().UpdateStatus(t.Context(), models.UpdatePaymentStatus{ID: captured, From: models.PaymentStatusRefunding, Status: models.PaymentStatusRefunded, RefundedAmount: 100000}); err != nil {
+		t.Fatal(err)
+	}
+	if booking := must(s.TripCustomer().Get(t.Context(), paid)); booking.PaymentStatus != models.BookingRefunded {
+		t.Fatalf("want a refunded booking, got %s", booking.PaymentStatus)
+	}
+
+	_, err = s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: "00000000-0000-0000-0000-000000000000", Provider: "cash", Status: models.PaymentStatusAuthorized})
+	wantKind(t, err, storage.KindForeignKey)
+
+	wantKind(t, s.TripCustomer().Delete(t.Context(), booking), storage.KindForeignKey)
+}
+
+func testDriverLedger(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998910000001")
+	trip := f.trip(t, s, 12*time.Hour, 100000)
+	customer := newCustomer(t, s, "+998910000002")
+	booking := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID}))
+	must(s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: booking, Provider: "cash", Amount: 100000, Currency: models.CurrencyUZS, Status: models.PaymentStatusCaptured}))
+
+	// a paid booking cancelled less than a day before departure gets half
+	// of its fare back, the other half is earned by the driver
+	late := newCustomer(t, s, "+998910000003")
+	cancelled := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: late.ID}))
+	must(s.Payment().Create(t.Context(), models.CreatePayment{TripCustomerID: cancelled, Provider: "cash", Amount: 100000, Currency: models.CurrencyUZS, Status: models.PaymentStatusCaptured}))
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: cancelled}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unpaid booking cancelled late earns nothing
+	unpaid := newCustomer(t, s, "+998910000004")
+	unpaidBooking := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: unpaid.ID}))
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: unpaidBooking}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.DriverLedger().CreatePayout(t.Context(), models.CreatePayout{DriverID: f.driver.ID, Amount: 1})
+	wantErr(t, err, storage.ErrInsufficientBalance)
+
+	for _, status := range []string{models.TripStatusBoarding, models.TripStatusInProgress, models.TripStatusCompleted} {
+		if err := s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: trip.ID, Status: status}); err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+	}
+
+	// 100000 + 50000 minus the commission of 10%
+	balance := must(s.DriverLedger().GetBalance(t.Context(), f.driver.ID))
+	if balance.Credited != 135000 || balance.Balance != balance.Credited {
+		t.Fatalf("want the paid and retained fares minus commission credited, got %+v", balance)
+	}
+
+	must(s.DriverLedger().CreatePayout(t.Context(), models.CreatePayout{DriverID: f.driver.ID, Amount: balance.Balance, Description: "all of it"}))
+
+	balance = must(s.DriverLedger().GetBalance(t.Context(), f.driver.ID))
+	if balance.Balance != 0 || balance.PaidOut != balance.Credited {
+		t.Fatalf("want an empty balance after the payout, got %+v", balance)
+	}
+
+	statement := must(s.DriverLedger().GetStatement(t.Context(), models.GetStatementRequest{
+		DriverID: f.driver.ID,
+		From:     timestamp(time.Now().Add(-time.Hour)),
+		To:       timestamp(time.Now().Add(time.Hour)),
+	}))
+	if statement.OpeningBalance != 0 || statement.ClosingBalance != 0 || len(statement.Entries) != 2 || statement.Entries[0].Kind != models.LedgerCredit {
+		t.Fatalf("unexpected statement %+v", statement)
+	}
+	if statement.Entries[0].TripID == nil || *statement.Entries[0].TripID != trip.ID || statement.Entries[1].TripID != nil {
+		t.Fatalf("unexpected trips of the entries %+v", statement.Entries)
+	}
+}
+
+func testRouteTariff(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998911000001")
+
+	first := must(s.RouteTariff().Create(t.Context(), models.CreateRouteTariff{
+		FromCityID: f.from.ID,
+		ToCityID:   f.to.ID,
+		BasePrice:  20000,
+		SeatPrice:  80000,
+		ValidFrom:  timestamp(time.Now().Add(-24 * time.Hour)),
+	}))
+
+	_, err := s.RouteTariff().Create(t.Context(), models.CreateRouteTariff{
+		FromCityID: f.from.ID,
+		ToCityID:   f.to.ID,
+		SeatPrice:  1,
+		ValidFrom:  timestamp(time.Now().Add(-48 * time.Hour)),
+	})
+	wantErr(t, err, storage.ErrTariffOverlap)
+
+	second := must(s.RouteTariff().Create(t.Context(), models.CreateRouteTariff{
+		FromCityID: f.from.ID,
+		ToCityID:   f.to.ID,
+		BasePrice:  30000,
+		SeatPrice:  90000,
+		ValidFrom:  timestamp(time.Now().Add(24 * time.Hour)),
+	}))
+
+	if tariff := must(s.RouteTariff().Get(t.Context(), first)); tariff.ValidTo == nil {
+		t.Fatal("want the first tariff closed by the second one")
+	}
+
+	if active := must(s.RouteTariff().GetActive(t.Context(), f.from.ID, f.to.ID, "")); active.ID != first {
+		t.Fatalf("want the first tariff active now, got %s", active.ID)
+	}
+
+	_, err = s.RouteTariff().GetActive(t.Context(), f.to.ID, f.from.ID, "")
+	wantErr(t, err, storage.ErrNoTariff)
+
+	tariffs := must(s.RouteTariff().GetList(t.Context(), models.GetRouteTariffListRequest{Page: 1, Limit: 10, FromCityID: f.from.ID}))
+	if tariffs.Count != 2 || tariffs.RouteTariffs[0].ID != second {
+		t.Fatalf("want the newest tariff first, got %+v", tariffs)
+	}
+
+	// a trip without a price takes the fare of the tariff at departure
+	trip := f.trip(t, s, 48*time.Hour, 0)
+	if trip.Price != 120000 || trip.TariffID == nil || *trip.TariffID != second {
+		t.Fatalf("want the price of the second tariff, got %d from %v", trip.Price, trip.TariffID)
+	}
+
+	// every extra seat adds the seat price of the tariff
+	customer := newCustomer(t, s, "+998911000002")
+	id := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: customer.ID, Seats: 2}))
+	booking := must(s.TripCustomer().Get(t.Context(), id))
+	if booking.Fare != 210000 {
+		t.Fatalf("want a fare of 210000, got %d", booking.Fare)
+	}
+}
+
+func testPriceRule(t *testing.T, s storage.IStorage) {
+	id := must(s.PriceRule().Create(t.Context(), models.CreatePriceRule{
+		Name:      "Almost full",
+		Kind:      models.PriceRuleOccupancy,
+		Threshold: 30,
+		Percent:   10,
+		Active:    true,
+	}))
+
+	rule := must(s.PriceRule().Get(t.Context(), id))
+	if rule.Name != "Almost full" || rule.Kind != models.PriceRuleOccupancy || rule.Threshold != 30 || rule.Percent != 10 || !rule.Active {
+		t.Fatalf("unexpected price rule %+v", rule)
+	}
+
+	lastMinute := must(s.PriceRule().Create(t.Context(), models.CreatePriceRule{
+		Name:      "Last hours",
+		Kind:      models.PriceRuleLastMinute,
+		Threshold: 120,
+		Percent:   -20,
+		Active:    true,
+	}))
+
+	if rules := must(s.PriceRule().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10})); rules.Count != 2 {
+		t.Fatalf("want 2 price rules, got %+v", rules)
+	}
+
+	_, err := s.PriceRule().Create(t.Context(), models.CreatePriceRule{Name: "Unknown", Kind: "weekend", Percent: 10})
+	wantKind(t, err, storage.KindValidation)
+
+	// last minute rules only make fares cheaper
+	_, err = s.PriceRule().Create(t.Context(), models.CreatePriceRule{Name: "Surcharge", Kind: models.PriceRuleLastMinute, Threshold: 60, Percent: 10})
+	wantKind(t, err, storage.KindValidation)
+
+	// the fare is quoted with the rules when the booking is made, the seats
+	// booked before it count for the occupancy
+	f := newFixture(t, s, "+998913000001")
+	trip := f.trip(t, s, 48*time.Hour, 100000)
+
+	first := newCustomer(t, s, "+998913000002")
+	booking := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: first.ID, Seats: 1}))
+	if booking := must(s.TripCustomer().Get(t.Context(), booking)); booking.Fare != 100000 {
+		t.Fatalf("want the regular fare of an empty trip, got %d", booking.Fare)
+	}
+
+	second := newCustomer(t, s, "+998913000003")
+	expensive := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: second.ID, Seats: 1}))
+	if booking := must(s.TripCustomer().Get(t.Context(), expensive)); booking.Fare != 110000 {
+		t.Fatalf("want 10%% more once a third of the seats is booked, got %d", booking.Fare)
+	}
+
+	soon := f.trip(t, s, time.Hour, 100000)
+	cheap := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: soon.ID, CustomerID: first.ID, Seats: 1}))
+	if booking := must(s.TripCustomer().Get(t.Context(), cheap)); booking.Fare != 80000 {
+		t.Fatalf("want 20%% less shortly before departure, got %d", booking.Fare)
+	}
+
+	// inactive rules are not used and the fares already quoted stay
+	rule.Active = false
+	must(s.PriceRule().Update(t.Context(), rule))
+	if rule := must(s.PriceRule().Get(t.Context(), id)); rule.Active {
+		t.Fatal("want the price rule deactivated")
+	}
+
+	third := newCustomer(t, s, "+998913000004")
+	regular := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: trip.ID, CustomerID: third.ID, Seats: 1}))
+	if booking := must(s.TripCustomer().Get(t.Context(), regular)); booking.Fare != 100000 {
+		t.Fatalf("want the regular fare without active rules, got %d", booking.Fare)
+	}
+	if booking := must(s.TripCustomer().Get(t.Context(), expensive)); booking.Fare != 110000 {
+		t.Fatalf("want the quoted fare kept, got %d", booking.Fare)
+	}
+
+	if err := s.PriceRule().Delete(t.Context(), lastMinute); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PriceRule().Get(t.Context(), lastMinute)
+	wantKind(t, err, storage.KindNotFound)
+}
+
+func testReport(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998914000001")
+
+	// trips of a Monday, the Wednesday after it, the next Monday and the
+	// next month, departing at noon so the day does not depend on the zone
+	trip := func(date string) models.Trip {
+		departure := must(time.ParseInLocation(time.DateOnly, date, time.Local)).Add(12 * time.Hour)
+		id := must(s.Trip().Create(t.Context(), models.CreateTrip{
+			FromCityID:  f.from.ID,
+			ToCityID:    f.to.ID,
+			DriverID:    f.driver.ID,
+			Price:       100000,
+			DepartureAt: timestamp(departure),
+			ArrivalAt:   timestamp(departure.Add(4 * time.Hour)),
+		}))
+		return must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: id}))
+	}
+
+	monday, wednesday := trip("2030-01-07"), trip("2030-01-09")
+	trip("2030-01-14")
+	february := trip("2030-02-01")
+
+	cancelled := trip("2030-01-08")
+	if err := s.Trip().UpdateStatus(t.Context(), models.UpdateTripStatus{ID: cancelled.ID, Status: models.TripStatusCancelled}); err != nil {
+		t.Fatal(err)
+	}
+
+	customer := newCustomer(t, s, "+998914000002")
+	must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: monday.ID, CustomerID: customer.ID, Seats: 1}))
+	must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: wednesday.ID, CustomerID: customer.ID, Seats: 2}))
+
+	// cancelled bookings are not counted
+	booking := must(s.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: february.ID, CustomerID: customer.ID, Seats: 1}))
+	if err := s.TripCustomer().Cancel(t.Context(), models.CancelTripCustomer{ID: booking, Reason: "changed plans"}); err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		period                   string
+		trips, passengers, seats int
+		occupancy                float64
+		revenue                  int
+	}
+
+	tests := []struct {
+		period string
+		want   []row
+	}{
+		{"day", []row{
+			{"2030-01-07", 1, 1, 3, 33.33, 100000},
+			{"2030-01-09", 1, 2, 3, 66.67, 200000},
+			{"2030-01-14", 1, 0, 3, 0, 0},
+			{"2030-02-01", 1, 0, 3, 0, 0},
+		}},
+		{"week", []row{
+			{"2030-01-07", 2, 3, 6, 50, 300000},
+			{"2030-01-14", 1, 0, 3, 0, 0},
+			{"2030-01-28", 1, 0, 3, 0, 0},
+		}},
+		{"month", []row{
+			{"2030-01-01", 3, 3, 9, 33.33, 300000},
+			{"2030-02-01", 1, 0, 3, 0, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		req := models.ReportRequest{Period: tt.period, From: "2030-01-01", To: "2030-03-01"}
+
+		routes := must(s.Report().Routes(t.Context(), req))
+		drivers := must(s.Report().Drivers(t.Context(), req))
+		if len(routes) != len(tt.want) || len(drivers) != len(tt.want) {
+			t.Fatalf("%s: want %d rows, got routes %+v and drivers %+v", tt.period, len(tt.want), routes, drivers)
+		}
+
+		for i, want := range tt.want {
+			for _, got := range []models.ReportRow{routes[i], drivers[i]} {
+				if got.Period != want.period || got.Trips != want.trips || got.Passengers != want.passengers ||
+					got.Seats != want.seats || got.Occupancy != want.occupancy || got.Revenue != want.revenue {
+					t.Fatalf("%s: want %+v, got %+v", tt.period, want, got)
+				}
+			}
+		}
+
+		if routes[0].FromCityName != f.from.Name || routes[0].ToCityName != f.to.Name {
+			t.Fatalf("%s: want the route of the fixture, got %+v", tt.period, routes[0])
+		}
+		if drivers[0].DriverID != f.driver.ID || drivers[0].DriverName != f.driver.FullName {
+			t.Fatalf("%s: want the driver of the fixture, got %+v", tt.period, drivers[0])
+		}
+	}
+
+	// the range is [From, To)
+	routes := must(s.Report().Routes(t.Context(), models.ReportRequest{Period: "day", From: "2030-01-08", To: "2030-01-14"}))
+	if len(routes) != 1 || routes[0].Period != "2030-01-09" {
+		t.Fatalf("want only the Wednesday trip, got %+v", routes)
+	}
+}
+
+func testTripTemplate(t *testing.T, s storage.IStorage) {
+	f := newFixture(t, s, "+998912000001")
+
+	id := must(s.TripTemplate().Create(t.Context(), models.CreateTripTemplate{
+		FromCityID:      f.from.ID,
+		ToCityID:        f.to.ID,
+		DriverID:        f.driver.ID,
+		Price:           100000,
+		Weekdays:        []int{1, 3, 5},
+		DepartureTime:   "08:30",
+		DurationMinutes: 240,
+	}))
+
+	template := must(s.TripTemplate().Get(t.Context(), id))
+	if template.DepartureTime != "08:30" || len(template.Weekdays) != 3 || template.FromCityData.Name != f.from.Name {
+		t.Fatalf("unexpected template %+v", template)
+	}
+
+	_, err := s.TripTemplate().Create(t.Context(), models.CreateTripTemplate{
+		FromCityID:      f.from.ID,
+		ToCityID:        f.to.ID,
+		DriverID:        f.driver.ID,
+		Weekdays:        []int{1},
+		DepartureTime:   "08:30",
+		DurationMinutes: 0,
+	})
+	wantKind(t, err, storage.KindValidation)
+
+	departure := time.Now().Add(72 * time.Hour)
+	must(s.Trip().Create(t.Context(), models.CreateTrip{
+		FromCityID:  f.from.ID,
+		ToCityID:    f.to.ID,
+		DriverID:    f.driver.ID,
+		Price:       100000,
+		DepartureAt: timestamp(departure),
+		ArrivalAt:   timestamp(departure.Add(4 * time.Hour)),
+		TemplateID:  id,
+	}))
+
+	dates := must(s.TripTemplate().GeneratedDates(t.Context(), id, time.Now().Format(time.DateOnly), time.Now().AddDate(0, 0, 7).Format(time.DateOnly)))
+	if len(dates) != 1 || dates[0] != departure.Format(time.DateOnly) {
+		t.Fatalf("want the date of the trip, got %v", dates)
+	}
+
+	wantKind(t, s.TripTemplate().Delete(t.Context(), id), storage.KindForeignKey)
+}
+
+func testImport(t *testing.T, s storage.IStorage) {
+	req := models.Import{
+		Cities: []models.ImportCity{
+			{Line: 2, Name: "Namangan"},
+			{Line: 3, Name: "Andijan"},
+		},
+		Drivers: []models.ImportDriver{
+			{Line: 2, FullName: "Imported", Phone: "+998913000001", FromCity: "namangan", ToCity: "ANDIJAN"},
+		},
+		Cars: []models.ImportCar{
+			{Line: 2, Model: "Cobalt", Brand: "Chevrolet", Number: "50A001", DriverPhone: "+998913000001"},
+		},
+		DryRun: true,
+	}
+
+	result := must(s.Import().Import(t.Context(), req))
+	if len(result.Errors) != 0 || result.Cities != 2 || result.Drivers != 1 || result.Cars != 1 {
+		t.Fatalf("unexpected dry run result %+v", result)
+	}
+	if cities := must(s.City().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10})); cities.Count != 0 {
+		t.Fatalf("a dry run created %d cities", cities.Count)
+	}
+
+	req.Cars = append(req.Cars, models.ImportCar{Line: 3, Model: "Spark", Brand: "Chevrolet", Number: "50A002", DriverPhone: "+998913999999"})
+	req.DryRun = false
+
+	result = must(s.Import().Import(t.Context(), req))
+	if len(result.Errors) != 1 || result.Errors[0].File != models.ImportCars || result.Errors[0].Line != 3 {
+		t.Fatalf("want an error of the unknown driver, got %+v", result.Errors)
+	}
+	if cities := must(s.City().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10})); cities.Count != 0 {
+		t.Fatalf("a failed import created %d cities", cities.Count)
+	}
+
+	req.Cars = req.Cars[:1]
+	must(s.Import().Import(t.Context(), req))
+
+	cars := must(s.Car().GetList(t.Context(), models.GetListRequest{Page: 1, Limit: 10}))
+	if cars.Count != 1 || cars.Cars[0].DriverData.Phone != "+998913000001" || cars.Cars[0].Seats != 4 {
+		t.Fatalf("unexpected imported cars %+v", cars)
+	}
+}
+
+func testAuth(t *testing.T, s storage.IStorage) {
+	id := must(s.Auth().CreateAdmin(t.Context(), "root", "hash", models.RoleDispatcher))
+
+	admin := must(s.Auth().GetAdminByLogin(t.Context(), "root"))
+	if admin.ID != id || admin.Role != models.RoleDispatcher || admin.PasswordHash != "hash" {
+		t.Fatalf("unexpected admin %+v", admin)
+	}
+
+	_, err := s.Auth().CreateAdmin(t.Context(), "root", "hash", models.RoleAdmin)
+	wantKind(t, err, storage.KindConflict)
+
+	_, err = s.Auth().GetAdminByLogin(t.Context(), "nobody")
+	wantKind(t, err, storage.KindNotFound)
+
+	if exists := must(s.Auth().UserExists(t.Context(), models.RoleDispatcher, id)); !exists {
+		t.Fatal("want the dispatcher to exist")
+	}
+	if exists := must(s.Auth().UserExists(t.Context(), models.RoleAdmin, id)); exists {
+		t.Fatal("want a dispatcher not to exist as an admin")
+	}
+
+	customer := newCustomer(t, s, "+998914000001")
+	if userID := must(s.Auth().UserIDByPhone(t.Context(), models.RoleCustomer, customer.Phone)); userID != customer.ID {
+		t.Fatalf("want customer %s, got %s", customer.ID, userID)
+	}
+
+	_, err = s.Auth().UserIDByPhone(t.Context(), models.RoleAdmin, customer.Phone)
+	wantKind(t, err, storage.KindValidation)
+
+	otp := models.OTP{Phone: customer.Phone, Role: models.RoleCustomer, CodeHash: "code", ExpiresAt: time.Now().Add(5 * time.Minute)}
+	if err := s.Auth().SaveOTP(t.Context(), otp); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Auth().AddOTPAttempt(t.Context(), otp.Phone, otp.Role); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := must(s.Auth().GetOTP(t.Context(), otp.Phone, otp.Role))
+	if saved.CodeHash != "code" || saved.Attempts != 1 {
+		t.Fatalf("unexpected otp %+v", saved)
+	}
+
+	// a new code resets the attempts
+	otp.CodeHash = "other"
+	if err := s.Auth().SaveOTP(t.Context(), otp); err != nil {
+		t.Fatal(err)
+	}
+	if saved := must(s.Auth().GetOTP(t.Context(), otp.Phone, otp.Role)); saved.CodeHash != "other" || saved.Attempts != 0 {
+		t.Fatalf("unexpected otp %+v", saved)
+	}
+
+	if err := s.Auth().DeleteOTP(t.Context(), otp.Phone, otp.Role); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Auth().GetOTP(t.Context(), otp.Phone, otp.Role)
+	wantKind(t, err, storage.KindNotFound)
+}
+
+func testContext(t *testing.T, s storage.IStorage) {
+	id := must(s.City().Create(t.Context(), models.CreateCity{Name: "Tashkent"}))
+
+	cancelled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := s.City().Get(cancelled, id)
+	wantErr(t, err, context.Canceled)
+
+	expired, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err = s.City().Get(expired, id)
+	wantKind(t, err, storage.KindTimeout)
+
+	_, err = s.Report().Routes(cancelled, models.ReportRequest{Period: "day", From: "2030-01-01", To: "2030-01-02"})
+	wantErr(t, err, context.Canceled)
+
+	_, err = s.Auth().GetAdminByLogin(cancelled, "root")
+	wantErr(t, err, context.Canceled)
+
+	// a stream stops once its context is done
+	for _, name := range []string{"Samarkand", "Bukhara"} {
+		must(s.City().Create(t.Context(), models.CreateCity{Name: name}))
+	}
+
+	streaming, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	streamed := 0
+	err = s.City().Stream(streaming, models.ListQuery{}, func(models.City) error {
+		streamed++
+		cancel()
+		return nil
+	})
+	wantErr(t, err, context.Canceled)
+	if streamed != 1 {
+		t.Fatalf("want the stream to stop after 1 city, got %d", streamed)
+	}
+}
+
+func testTx(t *testing.T, s storage.IStorage) {
+	var committed string
+	err := s.WithTx(t.Context(), func(tx storage.IStorage) error {
+		var err error
+		committed, err = tx.City().Create(t.Context(), models.CreateCity{Name: "Tashkent"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	must(s.City().Get(t.Context(), committed))
+
+	// a failing fn rolls back everything it did
+	var rolledBack string
+	failed := errors.New("failed")
+	err = s.WithTx(t.Context(), func(tx storage.IStorage) error {
+		rolledBack = must(tx.City().Create(t.Context(), models.CreateCity{Name: "Samarkand"}))
+		must(tx.City().Get(t.Context(), rolledBack))
+		return failed
+	})
+	wantErr(t, err, failed)
+
+	_, err = s.City().Get(t.Context(), rolledBack)
+	wantKind(t, err, storage.KindNotFound)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("want the panic of fn, got %v", r)
+			}
+		}()
+
+		s.WithTx(t.Context(), func(tx storage.IStorage) error {
+			rolledBack = must(tx.City().Create(t.Context(), models.CreateCity{Name: "Bukhara"}))
+			panic("boom")
+		})
+	}()
+
+	_, err = s.City().Get(t.Context(), rolledBack)
+	wantKind(t, err, storage.KindNotFound)
+
+	// a failed call of a repo rolls back only its own changes, the
+	// transaction goes on
+	f := newFixture(t, s, "+998901110101")
+	var tripID string
+	err = s.WithTx(t.Context(), func(tx storage.IStorage) error {
+		tripID = f.trip(t, tx, 24*time.Hour, 100).ID
+
+		_, err := tx.Trip().Create(t.Context(), models.CreateTrip{
+			FromCityID:  f.from.ID,
+			ToCityID:    f.to.ID,
+			DriverID:    f.driver.ID,
+			Price:       100,
+			DepartureAt: timestamp(time.Now().Add(25 * time.Hour)),
+			ArrivalAt:   timestamp(time.Now().Add(29 * time.Hour)),
+		})
+		wantErr(t, err, storage.ErrDriverBusy)
+
+		customer := newCustomer(t, tx, "+998901110102")
+		_, err = tx.TripCustomer().Create(t.Context(), models.CreateTripCustomer{TripID: tripID, CustomerID: customer.ID, Seats: 2})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	trip := must(s.Trip().Get(t.Context(), models.PrimaryKey{ID: tripID}))
+	if trip.FreeSeats != 1 {
+		t.Fatalf("want 1 free seat, got %d", trip.FreeSeats)
+	}
+
+	// a nested transaction rolls back on its own
+	err = s.WithTx(t.Context(), func(tx storage.IStorage) error {
+		err := tx.WithTx(t.Context(), func(nested storage.IStorage) error {
+			rolledBack = must(nested.City().Create(t.Context(), models.CreateCity{Name: "Khiva"}))
+			return failed
+		})
+		wantErr(t, err, failed)
+
+		committed, err = tx.City().Create(t.Context(), models.CreateCity{Name: "Termez"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	must(s.City().Get(t.Context(), committed))
+	_, err = s.City().Get(t.Context(), rolledBack)
+	wantKind(t, err, storage.KindNotFound)
+}
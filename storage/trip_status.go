@@ -0,0 +1,32 @@
+package storage
+
+import "city2city/api/models"
+
+// tripTransitions lists for every trip status the statuses it can move to
+var tripTransitions = map[string][]string{
+	models.TripStatusScheduled:  {models.TripStatusBoarding, models.TripStatusCancelled},
+	models.TripStatusBoarding:   {models.TripStatusInProgress, models.TripStatusCancelled},
+	models.TripStatusInProgress: {models.TripStatusCompleted},
+	models.TripStatusCompleted:  {},
+	models.TripStatusCancelled:  {},
+}
+
+func IsTripStatus(status string) bool {
+	_, ok := tripTransitions[status]
+	return ok
+}
+
+// CheckTripTransition returns an error if a trip can not move from one status to another
+func CheckTripTransition(from, to string) error {
+	if !IsTripStatus(to) {
+		return ErrUnknownTripStatus
+	}
+
+	for _, status := range tripTransitions[from] {
+		if status == to {
+			return nil
+		}
+	}
+
+	return ErrInvalidTripStatus
+}
@@ -0,0 +1,162 @@
+// Package validation checks request models by the rules in their validate
+// struct tags, e.g.
+//
+//	FromCityID string `json:"from_city_id" validate:"required,uuid"`
+//	ToCityID   string `json:"to_city_id" validate:"required,uuid,nefield=FromCityID"`
+//
+// Rules are separated by commas:
+//
+//	required      the field must not be empty
+//	uuid          the string is a uuid
+//	email         the string is an email address
+//	phone         the string is a phone number, see phone.Normalize
+//	min=N, max=N  the number is in range, for strings and slices the length is
+//	oneof=a b c   the string is one of the space separated values
+//	nefield=F     the field is not equal to the field F of the same struct
+//
+// Empty strings are only checked by required, so optional fields can use
+// the other rules too. Nested structs are not validated.
+package validation
+
+import (
+	"city2city/api/models"
+	"city2city/phone"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Errors are all the failed rules of a model
+type Errors []models.FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Field+" "+err.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+// Struct validates v, a struct or a pointer to one. It returns nil or
+// Errors with every field that failed, not only the first one.
+func Struct(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(rule, "=")
+
+			if message := checkRule(value, value.Field(i), name, param); message != "" {
+				errs = append(errs, models.FieldError{Field: jsonName(field), Message: message})
+				// later rules of the field would only repeat the problem
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkRule(parent, field reflect.Value, name, param string) string {
+	if name == "required" {
+		if field.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+
+	if field.Kind() == reflect.String && field.String() == "" {
+		return ""
+	}
+
+	switch name {
+	case "uuid":
+		if _, err := uuid.Parse(field.String()); err != nil || len(field.String()) != 36 {
+			return "must be a uuid"
+		}
+	case "email":
+		address, err := mail.ParseAddress(field.String())
+		if err != nil || address.Address != field.String() {
+			return "must be an email address"
+		}
+	case "phone":
+		if _, err := phone.Normalize(field.String()); err != nil {
+			return "is not valid: " + err.Error()
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			panic(fmt.Sprintf("validation: %s needs a number, got %q", name, param))
+		}
+
+		size, unit := measure(field)
+		if name == "min" && size < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "oneof":
+		for _, allowed := range strings.Fields(param) {
+			if field.String() == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "nefield":
+		other := parent.FieldByName(param)
+		if !other.IsValid() {
+			panic(fmt.Sprintf("validation: unknown field %q in nefield", param))
+		}
+
+		if reflect.DeepEqual(field.Interface(), other.Interface()) {
+			otherField, _ := parent.Type().FieldByName(param)
+			return "must not be equal to " + jsonName(otherField)
+		}
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", name))
+	}
+
+	return ""
+}
+
+// measure returns the number min and max compare, with the unit to show
+func measure(field reflect.Value) (int, string) {
+	switch field.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(field.String()), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return field.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(field.Int()), ""
+	}
+
+	panic(fmt.Sprintf("validation: min and max do not support %s", field.Kind()))
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}